@@ -63,16 +63,22 @@ func (i *item) notarize(ctx context.Context, opts *processOptions) error {
 		bundleId = opts.Config.BundleId
 	}
 
-	// Start notarization
-	_, _, err := notarize.Notarize(ctx, &notarize.Options{
+	notarizeOpts := &notarize.Options{
 		File:        i.Path,
+		BundleID:    bundleId,
 		DeveloperId: opts.Config.AppleId.Username,
 		Password:    opts.Config.AppleId.Password,
 		Provider:    opts.Config.AppleId.Provider,
 		Logger:      opts.Logger.Named("notarize"),
 		Status:      &statusHuman{Prefix: opts.Prefix, Lock: lock},
 		UploadLock:  opts.UploadLock,
-	})
+	}
+
+	// Start notarization, failing fast if the file can't be submitted
+	err := notarizeOpts.Validate()
+	if err == nil {
+		_, _, err = notarize.Notarize(ctx, notarizeOpts)
+	}
 
 	// Save the error state. We don't save the notarization result yet
 	// because we don't know it for sure until we retrieve the log information.
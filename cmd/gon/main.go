@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 
 	"github.com/asahasrabuddhe/gon/internal/config"
+	"github.com/asahasrabuddhe/gon/notarize"
 	"github.com/asahasrabuddhe/gon/package/dmg"
 	"github.com/asahasrabuddhe/gon/package/zip"
 	"github.com/asahasrabuddhe/gon/sign"
@@ -61,6 +63,11 @@ func realMain() int {
 		JSONFormat: logJSON,
 	})
 
+	// Stapling a file that was notarized elsewhere doesn't need a config
+	if len(args) > 0 && args[0] == "staple" {
+		return stapleMain(logger, args[1:])
+	}
+
 	// We expect a configuration file
 	if len(args) != 1 {
 		fmt.Fprintf(os.Stdout, color.RedString("❗️ Path to configuration expected.\n\n"))
@@ -98,6 +105,16 @@ func realMain() int {
 					"`sign` configuration to sign the input files.\n")
 			return 1
 		}
+
+		if cfg.Sign.NoTimestamp && (cfg.Zip != nil || cfg.Dmg != nil) {
+			color.New(color.Bold, color.FgRed).Fprintf(os.Stdout,
+				"❗️ `no_timestamp` can't be set when packaging for notarization\n")
+			color.New(color.FgRed).Fprintf(os.Stdout,
+				"Notarization requires signatures with a secure timestamp. The\n"+
+					"`no_timestamp` option is only meant for local development builds,\n"+
+					"remove the `zip` and `dmg` configuration to use it.\n")
+			return 1
+		}
 	} else {
 		if len(cfg.Notarize) == 0 {
 			color.New(color.Bold, color.FgRed).Fprintf(os.Stdout, "❗️ No source files specified\n")
@@ -181,12 +198,17 @@ func realMain() int {
 			// Perform codesigning
 			color.New(color.Bold).Fprintf(os.Stdout, "==> %s  Signing files...\n", iconSign)
 			err = sign.Sign(context.Background(), &sign.Options{
-				Files:        cfg.Source,
-				Identity:     cfg.Sign.ApplicationIdentity,
-				Entitlements: cfg.Sign.EntitlementsFile,
-				Deep:         cfg.Sign.Deep,
-				Logger:       logger.Named("sign"),
-				Requirements: cfg.Sign.Requirements,
+				Files:                cfg.Source,
+				Identity:             cfg.Sign.ApplicationIdentity,
+				Entitlements:         cfg.Sign.EntitlementsFile,
+				ValidateEntitlements: true,
+				Deep:                 cfg.Sign.Deep,
+				Logger:               logger.Named("sign"),
+				Requirements:         cfg.Sign.Requirements,
+				TimestampURL:         cfg.Sign.TimestampURL,
+				NoTimestamp:          cfg.Sign.NoTimestamp,
+				Keychain:             cfg.Sign.Keychain,
+				KeychainPassword:     cfg.Sign.KeychainPassword,
 			})
 			if err != nil {
 				fmt.Fprintf(os.Stdout, color.RedString("❗️ Error signing files:\n\n%s\n", err))
@@ -232,10 +254,12 @@ func realMain() int {
 			// Next we need to sign the actual DMG as well
 			color.New().Fprintf(os.Stdout, "    Signing dmg...\n")
 			err = sign.Sign(context.Background(), &sign.Options{
-				Files:    []string{cfg.Dmg.OutputPath},
-				Identity: cfg.Sign.ApplicationIdentity,
-				Deep:     cfg.Sign.Deep,
-				Logger:   logger.Named("dmg"),
+				Files:        []string{cfg.Dmg.OutputPath},
+				Identity:     cfg.Sign.ApplicationIdentity,
+				Deep:         cfg.Sign.Deep,
+				Logger:       logger.Named("dmg"),
+				TimestampURL: cfg.Sign.TimestampURL,
+				Keychain:     cfg.Sign.Keychain,
 			})
 			if err != nil {
 				fmt.Fprintf(os.Stdout, color.RedString("❗️ Error signing dmg:\n\n%s\n", err))
@@ -270,6 +294,20 @@ func realMain() int {
 	// Build our prefixes
 	prefixes := statusPrefixList(items)
 
+	// Interrupting stops waiting on the submissions, which continue at
+	// Apple. The errors tell the user the request UUIDs to check on.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Check that we can notarize at all before starting, since a missing
+	// notarytool would otherwise fail every item with the same error.
+	if err := notarize.CheckRequirements(ctx, &notarize.Options{
+		Logger: logger.Named("notarize"),
+	}); err != nil {
+		fmt.Fprintf(os.Stdout, color.RedString("❗️ %s\n", err))
+		return 1
+	}
+
 	// Start our notarizations
 	var wg sync.WaitGroup
 	var lock, uploadLock sync.Mutex
@@ -279,7 +317,7 @@ func realMain() int {
 		go func(idx int) {
 			defer wg.Done()
 
-			err := items[idx].notarize(context.Background(), &processOptions{
+			err := items[idx].notarize(ctx, &processOptions{
 				Config:     cfg,
 				Logger:     logger,
 				Prefix:     prefixes[idx],
@@ -322,12 +360,16 @@ const help = `
 gon signs, notarizes, and packages binaries for macOS.
 
 Usage: %[1]s [flags] CONFIG
+       %[1]s [flags] staple FILE...
 
 A configuration file is required to use gon. If a "-" is specified, gon
 will attempt to read the configuration from stdin. Configuration is in HCL
 or JSON format. The JSON format makes it particularly easy to machine-generate
 the configuration and pass it into gon.
 
+The "staple" form staples the notarization ticket to files that were already
+notarized, for example on another machine. This doesn't require credentials.
+
 For example configurations as well as full help text, see the README on GitHub:
 http://github.com/asahasrabuddhe/gon
 
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/staple"
+)
+
+// stapleMain staples the notarization ticket to each file in args. This
+// is the "gon staple" subcommand and needs no configuration file.
+func stapleMain(logger hclog.Logger, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stdout, color.RedString("❗️ Path to at least one file to staple expected.\n"))
+		return 1
+	}
+
+	failed := false
+	for _, f := range args {
+		color.New(color.Bold).Fprintf(os.Stdout, "    Stapling %s...\n", f)
+		err := staple.Staple(context.Background(), &staple.Options{
+			File:   f,
+			Logger: logger.Named("staple"),
+		})
+		if err != nil {
+			color.New(color.FgRed).Fprintf(os.Stdout, "❗️ Error stapling %q:\n\n%s\n", f, err)
+			failed = true
+			continue
+		}
+
+		color.New(color.FgGreen).Fprintf(os.Stdout, "    File %q stapled!\n", f)
+	}
+
+	if failed {
+		return 1
+	}
+
+	return 0
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/fatih/color"
 
@@ -18,8 +19,8 @@ type statusHuman struct {
 	Prefix string
 	Lock   *sync.Mutex
 
-	lastInfoStatus string
-	lastLogStatus  string
+	lastInfoStatus notarize.SubmissionStatus
+	lastLogStatus  notarize.SubmissionStatus
 }
 
 func (s *statusHuman) Submitting() {
@@ -38,6 +39,14 @@ func (s *statusHuman) Submitted(uuid string) {
 		os.Stdout, "    %sWaiting for results from Apple. This can take minutes to hours.\n", s.Prefix)
 }
 
+func (s *statusHuman) QueueCleared(uuid string, queueWait time.Duration) {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	color.New().Fprintf(os.Stdout, "    %sLeft Apple's queue after %s, now analyzing.\n",
+		s.Prefix, queueWait.Round(time.Second))
+}
+
 func (s *statusHuman) InfoStatus(info notarize.Info) {
 	s.Lock.Lock()
 	defer s.Lock.Unlock()
@@ -58,6 +67,26 @@ func (s *statusHuman) LogStatus(log notarize.Log) {
 	}
 }
 
+// Waiting is called on every request, which would flood the output, so
+// nothing is printed.
+func (s *statusHuman) Waiting(time.Duration, int) {}
+
+func (s *statusHuman) RateLimited(uuid string, wait time.Duration) {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	color.New(color.FgYellow).Fprintf(os.Stdout, "    %sRate limited by Apple, retrying in %s.\n",
+		s.Prefix, wait.Round(time.Second))
+}
+
+func (s *statusHuman) LargeUpload(size int64) {
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+
+	color.New(color.FgYellow).Fprintf(os.Stdout, "    %sFile is large (%.1f MB), uploading may take a while.\n",
+		s.Prefix, float64(size)/1e6)
+}
+
 // statusPrefixList takes a list of items and returns the prefixes to use
 // with status messages for each. The returned slice is guaranteed to be
 // allocated and the same length as items.
@@ -75,6 +75,16 @@ type Sign struct {
 	// Requirements is used to pass requirements to the codesign binary.
 	// See https://developer.apple.com/library/archive/technotes/tn2206/_index.html#//apple_ref/doc/uid/DTS40007919-CH1-TNTAG6
 	Requirements string `hcl:"requirements,optional"`
+	// TimestampURL is the URL of the timestamp server to use instead of Apple's default.
+	TimestampURL string `hcl:"timestamp_url,optional"`
+	// NoTimestamp disables the secure timestamp. Notarization requires a
+	// timestamp so this is only useful for local development builds.
+	NoTimestamp bool `hcl:"no_timestamp,optional"`
+	// Keychain is the path to the keychain to search for the identity.
+	Keychain string `hcl:"keychain,optional"`
+	// KeychainPassword unlocks the keychain before signing. This supports
+	// '@env:<name>' and '@file:<path>' to read it from the environment or a file.
+	KeychainPassword string `hcl:"keychain_password,optional"`
 }
 
 // Dmg are the options for a dmg file as output.
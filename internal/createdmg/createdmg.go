@@ -2,18 +2,93 @@ package createdmg
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"sync"
 
 	"github.com/asahasrabuddhe/gon/internal/createdmg/bindata"
 )
 
+// The SHA-256 hashes of the create-dmg script and of the dmg-license.py
+// helper that it runs, as embedded in this package. Cmd verifies the
+// extracted files against these so that files modified on disk are never
+// executed. These must be updated whenever vendor/create-dmg is.
+const (
+	CreateDMGSHA256  = "0be0d5b40d7eb60d8c184da97875ffc5ce5100f5b68dcebb9bfeeb290c81f61a"
+	DMGLicenseSHA256 = "9257017533671c68cd94b9c314011428c6856ca669324d38465079011ce09d04"
+)
+
+// verifiedFiles are the extracted files that are executed, relative to the
+// extracted directory, and their expected hashes.
+var verifiedFiles = map[string]string{
+	"create-dmg":             CreateDMGSHA256,
+	"support/dmg-license.py": DMGLicenseSHA256,
+}
+
+// extracted is a directory that the create-dmg project was extracted to,
+// which is shared by all the commands returned by Cmd until they are
+// closed.
+type extracted struct {
+	key  string
+	dir  string
+	refs int
+}
+
+var (
+	// cacheLock protects cache, which holds the extracted directories
+	// keyed by the hash of the assets and the temporary directory.
+	cacheLock sync.Mutex
+	cache     = map[string]*extracted{}
+
+	// cacheDirs is the same as cache but keyed on the directory.
+	cacheDirs = map[string]*extracted{}
+)
+
 // Cmd returns an *exec.Cmd that has the Path prepopulated to execute the
-// create-dmg script. You MUST call Close on this command when you're done.
-func Cmd(ctx context.Context) (*exec.Cmd, error) {
+// create-dmg script, which is extracted into tempDir or the OS temp
+// directory if it is empty. You MUST call Close on this command when
+// you're done.
+//
+// The extracted files that are executed are verified against
+// CreateDMGSHA256 and DMGLicenseSHA256 every time, and an error matching
+// ErrChecksumMismatch is returned if they don't match.
+//
+// The project is only extracted once for all the commands that are open
+// at the same time, so calling this repeatedly, such as for each dmg in a
+// build, is cheap. The extracted directory is removed once the last of
+// these commands is closed.
+func Cmd(ctx context.Context, tempDir string) (*exec.Cmd, error) {
+	key, err := cacheKey(tempDir)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	// Reuse the extracted project unless it was removed behind our back.
+	if e, ok := cache[key]; ok {
+		if _, err := os.Stat(filepath.Join(e.dir, "create-dmg")); err == nil {
+			if err := verify(e.dir); err != nil {
+				return nil, err
+			}
+
+			e.refs++
+			return exec.CommandContext(ctx, filepath.Join(e.dir, "create-dmg")), nil
+		}
+
+		delete(cache, key)
+		delete(cacheDirs, e.dir)
+	}
+
 	// Create a temporary directory where we'll extract the project
-	td, err := os.MkdirTemp("", "createdmg")
+	td, err := os.MkdirTemp(tempDir, "createdmg")
 	if err != nil {
 		return nil, err
 	}
@@ -23,6 +98,14 @@ func Cmd(ctx context.Context) (*exec.Cmd, error) {
 		os.RemoveAll(td)
 		return nil, err
 	}
+	if err := verify(td); err != nil {
+		os.RemoveAll(td)
+		return nil, err
+	}
+
+	e := &extracted{key: key, dir: td, refs: 1}
+	cache[key] = e
+	cacheDirs[td] = e
 
 	// Create a command
 	return exec.CommandContext(ctx, filepath.Join(td, "create-dmg")), nil
@@ -30,12 +113,86 @@ func Cmd(ctx context.Context) (*exec.Cmd, error) {
 
 // Close cleans up the temporary resources associated with the command.
 // This Cmd should've been returned by Cmd otherwise we may delete unrelated
-// data.
+// data. The extracted project is only removed once every command that
+// shares it was closed, so Close must be called once per command.
 func Close(cmd *exec.Cmd) error {
 	// Protect against unset commands
 	if cmd == nil || cmd.Path == "" || filepath.Base(cmd.Path) == cmd.Path {
 		return nil
 	}
 
-	return os.RemoveAll(filepath.Dir(cmd.Path))
+	dir := filepath.Dir(cmd.Path)
+
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	if e, ok := cacheDirs[dir]; ok {
+		e.refs--
+		if e.refs > 0 {
+			return nil
+		}
+
+		delete(cache, e.key)
+		delete(cacheDirs, dir)
+	}
+
+	return os.RemoveAll(dir)
+}
+
+// verify checks the hashes of the verifiedFiles extracted into dir.
+func verify(dir string) error {
+	for name, expected := range verifiedFiles {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		actual, err := fileSHA256(path)
+		if err != nil {
+			return err
+		}
+
+		if actual != expected {
+			return fmt.Errorf("%w for %s: expected %s, got %s",
+				ErrChecksumMismatch, path, expected, actual)
+		}
+	}
+
+	return nil
+}
+
+// fileSHA256 returns the hex encoded SHA-256 hash of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cacheKey returns the key of the project extracted into tempDir, which
+// changes if the embedded assets do.
+func cacheKey(tempDir string) (string, error) {
+	digests, err := bindata.Digests()
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(digests))
+	for name := range digests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		digest := digests[name]
+		h.Write([]byte(name))
+		h.Write(digest[:])
+	}
+
+	return hex.EncodeToString(h.Sum(nil)) + ":" + tempDir, nil
 }
@@ -2,16 +2,22 @@ package createdmg
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
+	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/asahasrabuddhe/gon/internal/createdmg/bindata"
 )
 
 func TestCmd(t *testing.T) {
 	req := require.New(t)
 
-	cmd, err := Cmd(context.Background())
+	cmd, err := Cmd(context.Background(), "")
 	defer Close(cmd)
 
 	req.NoError(err)
@@ -21,3 +27,68 @@ func TestCmd(t *testing.T) {
 	req.NoError(Close(cmd))
 	req.NoError(Close(cmd))
 }
+
+func TestCmd_checksums(t *testing.T) {
+	// The expected hashes must match the embedded files.
+	digests, err := bindata.Digests()
+	require.NoError(t, err)
+	for name, expected := range verifiedFiles {
+		digest := digests[name]
+		require.Equal(t, expected, hex.EncodeToString(digest[:]), name)
+	}
+}
+
+func TestCmd_checksumMismatch(t *testing.T) {
+	req := require.New(t)
+
+	cmd, err := Cmd(context.Background(), "")
+	req.NoError(err)
+	defer Close(cmd)
+
+	// Corrupt a byte of the shared script, which is detected when it is
+	// reused.
+	data, err := os.ReadFile(cmd.Path)
+	req.NoError(err)
+	data[0] ^= 0xff
+	req.NoError(os.WriteFile(cmd.Path, data, 0755))
+
+	_, err = Cmd(context.Background(), "")
+	req.ErrorIs(err, ErrChecksumMismatch)
+	req.ErrorContains(err, CreateDMGSHA256)
+}
+
+func TestCmd_shared(t *testing.T) {
+	req := require.New(t)
+
+	first, err := Cmd(context.Background(), "")
+	req.NoError(err)
+	second, err := Cmd(context.Background(), "")
+	req.NoError(err)
+
+	// Both commands use the same extracted project.
+	req.Equal(first.Path, second.Path)
+
+	// The project is kept until the last command is closed.
+	req.NoError(Close(first))
+	req.FileExists(second.Path)
+	req.NoError(Close(second))
+	req.NoFileExists(second.Path)
+
+	// Once it's removed, the next command extracts it again.
+	third, err := Cmd(context.Background(), "")
+	req.NoError(err)
+	defer Close(third)
+	req.FileExists(third.Path)
+}
+
+func TestRun_error(t *testing.T) {
+	req := require.New(t)
+
+	_, err := Run(exec.Command("sh", "-c", "echo license failed >&2; exit 3"))
+	req.Error(err)
+
+	var dmgErr *CreateDMGError
+	req.True(errors.As(err, &dmgErr))
+	req.Equal(3, dmgErr.ExitCode)
+	req.Equal("license failed\n", dmgErr.Stderr)
+}
@@ -0,0 +1,62 @@
+package createdmg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// ErrChecksumMismatch is matched by the error returned by Cmd when an
+// extracted file doesn't have the expected hash, such as if it was
+// modified on disk.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// CreateDMGError is returned when create-dmg exits with an error. The
+// output of create-dmg is the most useful information to troubleshoot
+// failures such as licensing or background image issues.
+type CreateDMGError struct {
+	// ExitCode is the exit code of create-dmg, or -1 if it didn't exit
+	// normally (for example if it couldn't be started).
+	ExitCode int
+
+	// Stderr is the combined stdout and stderr output of create-dmg.
+	Stderr string
+
+	// Err is the underlying error from executing create-dmg.
+	Err error
+}
+
+// Error implements error
+func (e *CreateDMGError) Error() string {
+	return fmt.Sprintf("error creating dmg (exit code %d):\n\n%s", e.ExitCode, e.Stderr)
+}
+
+// Unwrap returns the underlying execution error.
+func (e *CreateDMGError) Unwrap() error {
+	return e.Err
+}
+
+// Run executes the given create-dmg command, capturing the combined output
+// which is returned. If the command fails, the error is a *CreateDMGError.
+func Run(cmd *exec.Cmd) (string, error) {
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Run(); err != nil {
+		code := -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		}
+
+		return out.String(), &CreateDMGError{
+			ExitCode: code,
+			Stderr:   out.String(),
+			Err:      err,
+		}
+	}
+
+	return out.String(), nil
+}
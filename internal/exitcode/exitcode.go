@@ -0,0 +1,136 @@
+// Package exitcode classifies the exit codes of the Apple tools that gon
+// wraps. Not every non-zero exit of these tools is a failure, so the
+// table here decides which ones are.
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ErrFailed is matched by all errors returned by Classify.
+var ErrFailed = errors.New("tool failed")
+
+// Error is returned by Classify when a tool failed.
+type Error struct {
+	// Tool is the name of the tool, such as "codesign".
+	Tool string
+
+	// Code is the exit code of the tool, or -1 if it didn't exit normally.
+	Code int
+
+	// Output is the output of the tool, which usually explains the failure.
+	Output string
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s exited with code %d", e.Tool, e.Code)
+	if desc := describe(e.Tool, e.Code); desc != "" {
+		msg = fmt.Sprintf("%s, %s", msg, desc)
+	}
+
+	return fmt.Sprintf("%s:\n\n%s", msg, e.Output)
+}
+
+// Is returns true for ErrFailed.
+func (e *Error) Is(target error) bool {
+	return target == ErrFailed
+}
+
+// rule describes output of a tool that is informational, so a non-zero
+// exit with only this output isn't a failure.
+type rule struct {
+	// Tool is the name of the tool the rule applies to.
+	Tool string
+
+	// Code is the exit code the rule applies to, or zero for any code.
+	Code int
+
+	// Message is matched against each line of the output.
+	Message string
+}
+
+// rules are the known informational exits. A non-zero exit is only
+// ignored if every line of output matches a rule, so a real error printed
+// alongside an informational message is still a failure.
+var rules = []rule{
+	{Tool: "codesign", Code: 1, Message: "replacing existing signature"},
+	{Tool: "stapler", Message: "already has a ticket stapled"},
+}
+
+// descriptions explains known exit codes of the tools.
+var descriptions = map[string]map[int]string{
+	"codesign": {
+		2: "invalid arguments were passed",
+		3: "the file doesn't satisfy the requirement",
+	},
+}
+
+// Classify returns nil if tool exiting with code and output isn't a
+// failure, and an *Error otherwise.
+func Classify(tool string, code int, output string) error {
+	if code == 0 || informational(tool, code, output) {
+		return nil
+	}
+
+	return &Error{Tool: tool, Code: code, Output: output}
+}
+
+// Run classifies the result of running a command, where err and output are
+// what running it returned. Errors that aren't an exit, such as failing to
+// start the tool, are returned as-is.
+func Run(tool string, err error, output string) error {
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+
+	return Classify(tool, exitErr.ExitCode(), output)
+}
+
+// informational returns true if all of output matches the rules for tool
+// and code.
+func informational(tool string, code int, output string) bool {
+	matched := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if !matchesRule(tool, code, line) {
+			return false
+		}
+		matched = true
+	}
+
+	return matched
+}
+
+// matchesRule returns true if line matches any rule for tool and code.
+func matchesRule(tool string, code int, line string) bool {
+	for _, r := range rules {
+		if r.Tool != tool || (r.Code != 0 && r.Code != code) {
+			continue
+		}
+
+		if strings.Contains(line, r.Message) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// describe returns the description of a known exit code of tool, or an
+// empty string.
+func describe(tool string, code int) string {
+	return descriptions[tool][code]
+}
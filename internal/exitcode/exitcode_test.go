@@ -0,0 +1,81 @@
+package exitcode
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		Name   string
+		Tool   string
+		Code   int
+		Output string
+		Err    bool
+	}{
+		{
+			"success",
+			"codesign", 0, "foo: signed app bundle", false,
+		},
+
+		{
+			"replacing signature only",
+			"codesign", 1, "Foo.app: replacing existing signature\n", false,
+		},
+
+		{
+			"replacing signature with error",
+			"codesign", 1, "Foo.app: replacing existing signature\nFoo.app: no identity found\n", true,
+		},
+
+		{
+			"replacing signature other code",
+			"codesign", 3, "Foo.app: replacing existing signature\n", true,
+		},
+
+		{
+			"rule for other tool",
+			"stapler", 1, "Foo.app: replacing existing signature\n", true,
+		},
+
+		{
+			"already stapled",
+			"stapler", 65, "Foo.app already has a ticket stapled\n", false,
+		},
+
+		{
+			"no output",
+			"codesign", 1, "", true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := Classify(tc.Tool, tc.Code, tc.Output)
+			if !tc.Err {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, ErrFailed)
+
+			var exitErr *Error
+			require.True(t, errors.As(err, &exitErr))
+			require.Equal(t, tc.Code, exitErr.Code)
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	err := &Error{Tool: "codesign", Code: 3, Output: "out"}
+	require.Equal(t, "codesign exited with code 3, the file doesn't satisfy the requirement:\n\nout", err.Error())
+}
+
+func TestRun(t *testing.T) {
+	require.NoError(t, Run("codesign", nil, ""))
+
+	err := errors.New("not found")
+	require.Equal(t, err, Run("codesign", err, ""))
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package tempdir
+
+// availableSpace isn't supported on this platform.
+func availableSpace(dir string) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package tempdir
+
+import "syscall"
+
+// availableSpace returns the bytes available to unprivileged users on the
+// volume of dir.
+func availableSpace(dir string) (uint64, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, false
+	}
+
+	return uint64(st.Bavail) * uint64(st.Bsize), true
+}
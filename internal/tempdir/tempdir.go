@@ -0,0 +1,65 @@
+// Package tempdir contains helpers for the TempDir option that the gon
+// packages use to control where temporary files are created.
+package tempdir
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Validate returns an error if temporary files can't be created in dir,
+// or the OS temp directory if dir is empty. If size is positive, it also
+// returns an error if the volume is known to have less than size bytes
+// available. Checking the available space is best effort and is skipped
+// where it isn't supported.
+func Validate(dir string, size int64) error {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	f, err := os.CreateTemp(dir, ".gon-check")
+	if err != nil {
+		return fmt.Errorf("temporary directory %q isn't writable, "+
+			"set TempDir to a writable directory: %w", dir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	if size <= 0 {
+		return nil
+	}
+
+	if available, ok := availableSpace(dir); ok && available < uint64(size) {
+		return fmt.Errorf("temporary directory %q has %d bytes available "+
+			"but %d bytes are needed, set TempDir to a larger volume",
+			dir, available, size)
+	}
+
+	return nil
+}
+
+// Size returns the total size of the regular files at paths, including
+// those in directories. Files that can't be read are skipped, so this is
+// only an estimate.
+func Size(paths ...string) int64 {
+	var total int64
+	for _, path := range paths {
+		filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+
+			if d.Type().IsRegular() {
+				if fi, err := d.Info(); err == nil {
+					total += fi.Size()
+				}
+			}
+
+			return nil
+		})
+	}
+
+	return total
+}
@@ -0,0 +1,33 @@
+package tempdir
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	td := t.TempDir()
+
+	req := require.New(t)
+	req.NoError(Validate("", 0))
+	req.NoError(Validate(td, 1))
+	req.Error(Validate(filepath.Join(td, "missing"), 0))
+
+	if _, ok := availableSpace(td); ok {
+		req.Error(Validate(td, math.MaxInt64))
+	}
+}
+
+func TestSize(t *testing.T) {
+	td := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(td, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(td, "a"), make([]byte, 3), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(td, "sub", "b"), make([]byte, 4), 0644))
+
+	require.Equal(t, int64(7), Size(td))
+	require.Equal(t, int64(3), Size(filepath.Join(td, "a"), filepath.Join(td, "missing")))
+}
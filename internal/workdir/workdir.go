@@ -0,0 +1,47 @@
+// Package workdir contains helpers for the WorkDir option that the gon
+// packages use to set the working directory of the commands they execute.
+package workdir
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Validate returns an error if dir is set and isn't an existing directory.
+func Validate(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("working directory can't be used: %w", err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("working directory %q is not a directory", dir)
+	}
+
+	return nil
+}
+
+// Apply sets the working directory of cmd to dir if dir is set. Otherwise
+// cmd runs in the working directory of the current process, unless its
+// Dir was already set.
+func Apply(cmd *exec.Cmd, dir string) {
+	if dir != "" {
+		cmd.Dir = dir
+	}
+}
+
+// Path returns path as it is resolved by commands running in dir. This is
+// used for the paths that gon accesses itself so they match what the
+// executed commands see.
+func Path(dir, path string) string {
+	if dir == "" || path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,40 @@
+package workdir
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	td := t.TempDir()
+	file := filepath.Join(td, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+
+	req := require.New(t)
+	req.NoError(Validate(""))
+	req.NoError(Validate(td))
+	req.Error(Validate(file))
+	req.Error(Validate(filepath.Join(td, "missing")))
+}
+
+func TestApply(t *testing.T) {
+	cmd := exec.Command("true")
+	cmd.Dir = "/base"
+	Apply(cmd, "")
+	require.Equal(t, "/base", cmd.Dir)
+
+	Apply(cmd, "/work")
+	require.Equal(t, "/work", cmd.Dir)
+}
+
+func TestPath(t *testing.T) {
+	req := require.New(t)
+	req.Equal("foo.zip", Path("", "foo.zip"))
+	req.Equal(filepath.Join("/work", "foo.zip"), Path("/work", "foo.zip"))
+	req.Equal("/abs/foo.zip", Path("/work", "/abs/foo.zip"))
+	req.Equal("", Path("/work", ""))
+}
@@ -0,0 +1,99 @@
+package notarize
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+)
+
+// artifactTimeFormat is the format of the timestamp that prefixes the
+// names of the files written to Options.ArtifactDir. It sorts in the order
+// the files were written.
+const artifactTimeFormat = "20060102T150405.000000000Z"
+
+// redacted replaces secrets in the files written to Options.ArtifactDir.
+const redacted = "<redacted>"
+
+// artifactSummary is the summary written to Options.ArtifactDir once
+// notarization completes.
+type artifactSummary struct {
+	File        string           `json:"file"`
+	BuildID     string           `json:"buildId,omitempty"`
+	RequestUUID string           `json:"requestUUID,omitempty"`
+	Status      SubmissionStatus `json:"status,omitempty"`
+	LogStatus   SubmissionStatus `json:"logStatus,omitempty"`
+	Error       string           `json:"error,omitempty"`
+	Started     time.Time        `json:"started"`
+	Finished    time.Time        `json:"finished"`
+}
+
+// writeArtifact writes the output of a notarytool command to a new
+// timestamped file named name in opts.ArtifactDir, if it is set. Password
+// and any other secrets are redacted. Errors are only logged since the
+// artifacts shouldn't interrupt notarization.
+func writeArtifact(logger hclog.Logger, opts *Options, name string, data []byte, secrets ...string) {
+	if opts.ArtifactDir == "" || len(data) == 0 {
+		return
+	}
+
+	dir := workdir.Path(opts.WorkDir, opts.ArtifactDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logger.Warn("error creating artifact directory", "dir", dir, "err", err)
+		return
+	}
+
+	content := string(data)
+	for _, secret := range append(secrets, opts.Password) {
+		if secret != "" {
+			content = strings.ReplaceAll(content, secret, redacted)
+		}
+	}
+
+	path := filepath.Join(dir, time.Now().UTC().Format(artifactTimeFormat)+"-"+name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		logger.Warn("error writing artifact", "path", path, "err", err)
+		return
+	}
+
+	logger.Debug("wrote artifact", "path", path)
+}
+
+// writeSummary writes the summary of a notarization to opts.ArtifactDir,
+// if it is set.
+func writeSummary(ctx context.Context, logger hclog.Logger, opts *Options, started time.Time, info *Info, log *Log, err error) {
+	if opts.ArtifactDir == "" {
+		return
+	}
+
+	summary := artifactSummary{
+		File:     opts.File,
+		Started:  started.UTC(),
+		Finished: time.Now().UTC(),
+	}
+	summary.BuildID, _ = BuildID(ctx)
+	if info != nil {
+		summary.RequestUUID = info.RequestUUID
+		summary.Status = info.Status
+	}
+	if log != nil {
+		summary.LogStatus = log.Status
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+
+	data, jerr := json.MarshalIndent(summary, "", "  ")
+	if jerr != nil {
+		logger.Warn("error encoding summary artifact", "err", jerr)
+		return
+	}
+
+	writeArtifact(logger, opts, "summary.json", data)
+}
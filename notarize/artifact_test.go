@@ -0,0 +1,55 @@
+package notarize
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotarize_artifactDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "artifacts")
+	_, _, err := Notarize(context.Background(), &Options{
+		Logger:      hclog.L(),
+		BaseCmd:     childCmd(t, "notarize-accepted"),
+		Intervals:   testIntervals,
+		Password:    "hunter2",
+		ArtifactDir: dir,
+	})
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	// Files are timestamped, so we compare the names without it.
+	var names []string
+	for _, e := range entries {
+		_, name, ok := strings.Cut(e.Name(), "Z-")
+		require.True(t, ok, e.Name())
+		names = append(names, name)
+	}
+
+	req := require.New(t)
+	req.Contains(names, "submit.plist")
+	req.Contains(names, "log-cfd69166-8e2f-1397-8636-ec06f98e3597.json")
+	req.Contains(names, "info-cfd69166-8e2f-1397-8636-ec06f98e3597.plist")
+	req.Equal("summary.json", names[len(names)-1])
+}
+
+func TestWriteArtifact_redacted(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{ArtifactDir: dir, Password: "hunter2"}
+	writeArtifact(hclog.L(), opts, "out.txt", []byte("password hunter2 and token s3cret"), "s3cret")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	data, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+	require.Equal(t, "password <redacted> and token <redacted>", string(data))
+}
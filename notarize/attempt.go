@@ -0,0 +1,79 @@
+package notarize
+
+import (
+	"errors"
+	"time"
+)
+
+// Phase is a phase of the notarization process.
+type Phase string
+
+// The phases of notarization, in the order they happen.
+const (
+	PhaseSubmit Phase = "submit"
+	PhaseQueue  Phase = "queue"
+	PhaseInfo   Phase = "info"
+	PhaseLog    Phase = "log"
+)
+
+// AttemptAction is what was done after a failed request.
+type AttemptAction string
+
+const (
+	// AttemptRetried means the request was retried.
+	AttemptRetried AttemptAction = "retried"
+
+	// AttemptAborted means notarization was aborted with the error.
+	AttemptAborted AttemptAction = "aborted"
+
+	// AttemptResubmitted means the submission was abandoned and the file
+	// was submitted again.
+	AttemptResubmitted AttemptAction = "resubmitted"
+)
+
+// Attempt records a failed request made during notarization and what was
+// done about it. These explain delays when notarization eventually
+// succeeds after retrying, which is useful for debugging and support.
+type Attempt struct {
+	// Phase is the phase the request was made in.
+	Phase Phase
+
+	// RequestUUID is the UUID of the submission the request was for. This
+	// is empty if the file wasn't submitted yet.
+	RequestUUID string
+
+	// Time is when the request failed.
+	Time time.Time
+
+	// Code is the Apple error code of the failure, or zero if the error
+	// didn't have one.
+	Code int64
+
+	// Message is the error message.
+	Message string
+
+	// Action is what was done after the failure.
+	Action AttemptAction
+}
+
+// recordAttempt appends an attempt for err to attempts, if it is non-nil.
+func recordAttempt(attempts *[]Attempt, uuid string, phase Phase, err error, action AttemptAction) {
+	if attempts == nil {
+		return
+	}
+
+	a := Attempt{
+		Phase:       phase,
+		RequestUUID: uuid,
+		Time:        time.Now(),
+		Message:     err.Error(),
+		Action:      action,
+	}
+
+	var e Errors
+	if errors.As(err, &e) && len(e) > 0 {
+		a.Code = e[0].Code
+	}
+
+	*attempts = append(*attempts, a)
+}
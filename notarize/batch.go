@@ -0,0 +1,324 @@
+package notarize
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-multierror"
+	"howett.net/plist"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+)
+
+// NotarizeGlob notarizes all the files that match the glob pattern, such
+// as "dist/*.dmg", concurrently. The pattern syntax is that of
+// filepath.Glob. The File and ContentHash fields of opts are ignored. If
+// WorkDir is set, a relative pattern is relative to it and the files in
+// the result are absolute paths.
+//
+// Matches that can't be notarized are skipped with a warning. These are
+// files that aren't zip, dmg, or pkg files or bundle directories. Uploads
+// of files with the same bundle ID are serialized as described for
+// Options.UploadLock, and files with identical contents are submitted
+// only once. The bundle ID is BundleID if it is set and otherwise read
+// from the Info.plist of app bundles. Files without a known bundle ID
+// upload concurrently.
+//
+// The result has an entry for each file that was notarized. The error is
+// non-nil if the pattern is malformed or any notarization failed, in
+// which case the Err of the corresponding Result is also set.
+func NotarizeGlob(ctx context.Context, pattern string, opts *Options) (map[string]*Result, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	logger = withBuildID(ctx, logger)
+
+	matches, err := filepath.Glob(workdir.Path(opts.WorkDir, pattern))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, m := range matches {
+		// The matches include WorkDir, so we make them absolute so that
+		// they aren't resolved relative to WorkDir again.
+		if opts.WorkDir != "" {
+			if abs, err := filepath.Abs(m); err == nil {
+				m = abs
+			}
+		}
+
+		if !canNotarize(m) {
+			logger.Warn("skipping file that can't be notarized", "file", m)
+			continue
+		}
+
+		files = append(files, m)
+	}
+
+	return notarizeBatch(ctx, files, opts)
+}
+
+// notarizeBatch notarizes files concurrently with the given options. If
+// opts doesn't set an UploadLock, uploads are serialized per bundle ID as
+// described for uploadLockKey.
+//
+// Files with identical contents, including a path that is listed twice,
+// are only submitted once and share the result. The SHA-256 of the
+// contents is used as the ContentHash of each file.
+func notarizeBatch(ctx context.Context, files []string, opts *Options) (map[string]*Result, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	logger = withBuildID(ctx, logger)
+
+	// Group the files by contents, keeping the order they were given in.
+	var keys []string
+	filesByKey := map[string][]string{}
+	for _, f := range files {
+		key := batchKey(workdir.Path(opts.WorkDir, f))
+		if _, ok := filesByKey[key]; !ok {
+			keys = append(keys, key)
+		} else {
+			logger.Info("file has the same contents as another in the batch, "+
+				"it will share that submission", "file", f, "other", filesByKey[key][0])
+		}
+		filesByKey[key] = append(filesByKey[key], f)
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var resultErr error
+	results := make(map[string]*Result, len(files))
+	uploadLocks := map[string]*sync.Mutex{}
+
+	for _, key := range keys {
+		group := filesByKey[key]
+		fileOpts := copyOptions(opts)
+		fileOpts.File = group[0]
+
+		// The content hash is per file, so we use the hash we computed for
+		// the result cache. Files keyed by path can't be looked up.
+		fileOpts.ContentHash = ""
+		if hash, ok := strings.CutPrefix(key, "sha256:"); ok {
+			fileOpts.ContentHash = hash
+		}
+		shareUploadLock(uploadLocks, fileOpts)
+		if fileOpts.StatePath != "" {
+			fileOpts.StatePath = batchStatePath(opts.StatePath, key)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result := &Result{File: fileOpts.File}
+			start := time.Now()
+			result.Info, result.Log, result.Err = notarize(ctx, fileOpts, &result.Attempts)
+			result.Duration = time.Since(start)
+
+			lock.Lock()
+			defer lock.Unlock()
+			for _, f := range group {
+				r := *result
+				r.File = f
+				results[f] = &r
+			}
+			if result.Err != nil {
+				resultErr = multierror.Append(resultErr,
+					fmt.Errorf("error notarizing %s: %w", strings.Join(group, ", "), result.Err))
+			}
+		}()
+	}
+
+	wg.Wait()
+	return results, resultErr
+}
+
+// NotarizeBatch notarizes the files described by each of the options
+// concurrently, with at most parallel notarizations running at once. If
+// parallel is zero or negative, all the files are notarized at once.
+// Uploads of files with the same bundle ID are serialized as described for
+// Options.UploadLock and NotarizeGlob, unless the options set their own
+// UploadLock.
+//
+// The results are in the same order as files. A failure doesn't stop the
+// other files from being notarized. The error is non-nil if any
+// notarization failed, in which case the Err of the corresponding Result
+// is also set.
+func NotarizeBatch(ctx context.Context, files []*Options, parallel int) ([]*Result, error) {
+	if parallel <= 0 {
+		parallel = len(files)
+	}
+
+	uploadLocks := map[string]*sync.Mutex{}
+	sem := make(chan struct{}, parallel)
+
+	// Files that share a state path would overwrite each other's state.
+	statePaths := map[string]int{}
+	for _, opts := range files {
+		if opts.StatePath != "" {
+			statePaths[opts.StatePath]++
+		}
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var resultErr error
+	results := make([]*Result, len(files))
+	for i, opts := range files {
+		fileOpts := copyOptions(opts)
+		shareUploadLock(uploadLocks, fileOpts)
+		if statePaths[fileOpts.StatePath] > 1 {
+			key := batchKey(workdir.Path(fileOpts.WorkDir, fileOpts.File))
+			fileOpts.StatePath = batchStatePath(fileOpts.StatePath, key)
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result := &Result{File: fileOpts.File}
+			start := time.Now()
+			result.Info, result.Log, result.Err = notarize(ctx, fileOpts, &result.Attempts)
+			result.Duration = time.Since(start)
+			results[i] = result
+
+			if result.Err != nil {
+				lock.Lock()
+				defer lock.Unlock()
+				resultErr = multierror.Append(resultErr,
+					fmt.Errorf("error notarizing %s: %w", fileOpts.File, result.Err))
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	return results, resultErr
+}
+
+// shareUploadLock sets the UploadLock of opts to the lock for its bundle
+// ID in locks, unless it already has one. See uploadLockKey for how files
+// are keyed.
+func shareUploadLock(locks map[string]*sync.Mutex, opts *Options) {
+	if opts.UploadLock != nil {
+		return
+	}
+
+	key := uploadLockKey(opts)
+	if _, ok := locks[key]; !ok {
+		locks[key] = &sync.Mutex{}
+	}
+	opts.UploadLock = locks[key]
+}
+
+// uploadLockKey returns the key of the upload lock shared by files with
+// the same bundle ID. If opts doesn't set BundleID, it is read from the
+// Info.plist of app bundles. Other files, such as dmg and pkg files, don't
+// have a bundle ID we can detect, so they are keyed by their path and
+// upload concurrently with everything else.
+func uploadLockKey(opts *Options) string {
+	if opts.BundleID != "" {
+		return "bundle:" + opts.BundleID
+	}
+
+	path := workdir.Path(opts.WorkDir, opts.File)
+	if id := bundleID(path); id != "" {
+		return "bundle:" + id
+	}
+
+	return "file:" + path
+}
+
+// bundleID returns the CFBundleIdentifier of the bundle at path, or an
+// empty string if path isn't a bundle with an Info.plist.
+func bundleID(path string) string {
+	data, err := os.ReadFile(filepath.Join(path, "Contents", "Info.plist"))
+	if err != nil {
+		return ""
+	}
+
+	var info struct {
+		ID string `plist:"CFBundleIdentifier"`
+	}
+	if _, err := plist.Unmarshal(data, &info); err != nil {
+		return ""
+	}
+
+	return info.ID
+}
+
+// batchStatePath returns the state path for the file with the batch key
+// within a batch whose files share statePath. The suffix only depends on
+// the key, so a restarted batch finds the same state again.
+func batchStatePath(statePath, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return statePath + "." + hex.EncodeToString(sum[:6])
+}
+
+// batchKey returns the key used to detect duplicate files in a batch. This
+// is the SHA-256 of the contents of regular files. Other files, such as
+// bundle directories, or files that can't be read are keyed by their
+// absolute path so that they are only coalesced if listed twice.
+func batchKey(file string) string {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		path = file
+	}
+
+	hash, err := fileSHA256(file)
+	if err != nil {
+		return "path:" + path
+	}
+
+	return "sha256:" + hash
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 of the contents of file, which
+// must be a regular file.
+func fileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !fi.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", file)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// canNotarize returns true if file is of a type that can be submitted.
+func canNotarize(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".zip", ".dmg", ".pkg":
+		return true
+	default:
+		return isBundle(file)
+	}
+}
@@ -0,0 +1,187 @@
+package notarize
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotarizeGlob(t *testing.T) {
+	td := t.TempDir()
+	for _, name := range []string{"a.dmg", "b.zip", "README.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(td, name), []byte("hello"), 0644))
+	}
+
+	results, err := NotarizeGlob(context.Background(), filepath.Join(td, "*"), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Len(results, 2)
+	for _, name := range []string{"a.dmg", "b.zip"} {
+		r := results[filepath.Join(td, name)]
+		req.NotNil(r)
+		req.NoError(r.Err)
+		req.Equal(StatusAccepted, r.Info.Status)
+	}
+}
+
+func TestNotarizeGlob_error(t *testing.T) {
+	td := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(td, "a.pkg"), []byte("hello"), 0644))
+
+	results, err := NotarizeGlob(context.Background(), filepath.Join(td, "*.pkg"), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-info-error"),
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	req.Error(err)
+	req.Error(results[filepath.Join(td, "a.pkg")].Err)
+}
+
+func TestNotarizeBatch_dedup(t *testing.T) {
+	td := t.TempDir()
+	a := filepath.Join(td, "a.zip")
+	b := filepath.Join(td, "b.zip")
+	c := filepath.Join(td, "c.zip")
+	require.NoError(t, os.WriteFile(a, []byte("same"), 0644))
+	require.NoError(t, os.WriteFile(b, []byte("same"), 0644))
+	require.NoError(t, os.WriteFile(c, []byte("different"), 0644))
+
+	// The files are uploaded concurrently.
+	var lock sync.Mutex
+	var uploads []string
+	uploadFunc = func(ctx context.Context, opts *Options) (string, error) {
+		lock.Lock()
+		uploads = append(uploads, opts.File)
+		lock.Unlock()
+		return upload(ctx, opts)
+	}
+	defer func() { uploadFunc = upload }()
+
+	results, err := notarizeBatch(context.Background(), []string{a, b, a, c}, &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Len(uploads, 2)
+	req.Len(results, 3)
+	req.Equal(b, results[b].File)
+	req.Equal(StatusAccepted, results[b].Info.Status)
+}
+
+func TestNotarizeGlob_badPattern(t *testing.T) {
+	_, err := NotarizeGlob(context.Background(), "[", &Options{})
+	require.Error(t, err)
+}
+
+func TestNotarizeBatch(t *testing.T) {
+	results, err := NotarizeBatch(context.Background(), []*Options{
+		{
+			File:      "a.zip",
+			Logger:    hclog.L(),
+			BaseCmd:   childCmd(t, "notarize-accepted"),
+			Intervals: testIntervals,
+		},
+		{
+			File:      "b.zip",
+			Logger:    hclog.L(),
+			BaseCmd:   childCmd(t, "notarize-info-error"),
+			Intervals: testIntervals,
+		},
+		{
+			File:      "c.zip",
+			Logger:    hclog.L(),
+			BaseCmd:   childCmd(t, "notarize-accepted"),
+			Intervals: testIntervals,
+		},
+	}, 2)
+
+	// The failure doesn't stop the other files.
+	req := require.New(t)
+	req.ErrorContains(err, "error notarizing b.zip")
+	req.Len(results, 3)
+	req.Equal("a.zip", results[0].File)
+	req.NoError(results[0].Err)
+	req.Equal(StatusAccepted, results[0].Info.Status)
+	req.Equal("b.zip", results[1].File)
+	req.Error(results[1].Err)
+	req.Equal("c.zip", results[2].File)
+	req.NoError(results[2].Err)
+}
+
+func TestNotarizeBatch_sharedUploadLock(t *testing.T) {
+	var lock sync.Mutex
+	var active, maxActive int
+	uploadFunc = func(ctx context.Context, opts *Options) (string, error) {
+		lock.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		lock.Unlock()
+
+		defer func() {
+			lock.Lock()
+			defer lock.Unlock()
+			active--
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		return upload(ctx, opts)
+	}
+	defer func() { uploadFunc = upload }()
+
+	var files []*Options
+	for _, name := range []string{"a.zip", "b.zip", "c.zip"} {
+		files = append(files, &Options{
+			File:      name,
+			BundleID:  "com.example.gon",
+			Logger:    hclog.L(),
+			BaseCmd:   childCmd(t, "notarize-accepted"),
+			Intervals: testIntervals,
+		})
+	}
+
+	_, err := NotarizeBatch(context.Background(), files, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, maxActive)
+}
+
+func TestUploadLockKey(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.app", "b.app"} {
+		contents := filepath.Join(dir, name, "Contents")
+		require.NoError(t, os.MkdirAll(contents, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(contents, "Info.plist"), []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>CFBundleIdentifier</key>
+	<string>com.example.gon</string>
+</dict>
+</plist>`), 0644))
+	}
+
+	req := require.New(t)
+
+	// Bundles with the same bundle ID in their Info.plist share a lock.
+	req.Equal("bundle:com.example.gon", uploadLockKey(&Options{File: "a.app", WorkDir: dir}))
+	req.Equal("bundle:com.example.gon", uploadLockKey(&Options{File: "b.app", WorkDir: dir}))
+	req.Equal("bundle:com.example.gon", uploadLockKey(&Options{File: "a.dmg", BundleID: "com.example.gon"}))
+
+	// Files without a bundle ID don't share a lock.
+	req.NotEqual(uploadLockKey(&Options{File: "a.dmg"}), uploadLockKey(&Options{File: "b.dmg"}))
+	req.NotEqual(uploadLockKey(&Options{File: "c.app", WorkDir: dir}), uploadLockKey(&Options{File: "d.app", WorkDir: dir}))
+}
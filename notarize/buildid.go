@@ -0,0 +1,34 @@
+package notarize
+
+import (
+	"context"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// buildIDKey is the context key for the build ID.
+type buildIDKey struct{}
+
+// WithBuildID returns a context that carries the given build ID, such as
+// the ID of the CI build that gon runs in. All log lines written while
+// notarizing with this context include it as "build_id", which makes
+// gon's output traceable in aggregated logging systems.
+func WithBuildID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, buildIDKey{}, id)
+}
+
+// BuildID returns the build ID set with WithBuildID. The boolean is false
+// if no build ID was set.
+func BuildID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(buildIDKey{}).(string)
+	return id, ok
+}
+
+// withBuildID returns logger with the build ID of ctx, if it has one.
+func withBuildID(ctx context.Context, logger hclog.Logger) hclog.Logger {
+	if id, ok := BuildID(ctx); ok {
+		return logger.With("build_id", id)
+	}
+
+	return logger
+}
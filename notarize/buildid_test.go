@@ -0,0 +1,38 @@
+package notarize
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildID(t *testing.T) {
+	_, ok := BuildID(context.Background())
+	require.False(t, ok)
+
+	id, ok := BuildID(WithBuildID(context.Background(), "ci-1234"))
+	require.True(t, ok)
+	require.Equal(t, "ci-1234", id)
+}
+
+func TestNotarize_buildID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := hclog.New(&hclog.LoggerOptions{Output: &buf, Level: hclog.Info})
+
+	ctx := WithBuildID(context.Background(), "ci-1234")
+	_, _, err := Notarize(ctx, &Options{
+		Logger:    logger,
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+	})
+
+	require.NoError(t, err)
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("20")) {
+			require.Contains(t, string(line), "build_id=ci-1234")
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package notarize
+
+import "context"
+
+// ResultCache stores notarization results keyed by a content hash so that
+// identical content doesn't have to be notarized again. Implementations
+// can be backed by anything, such as local disk or S3, and must be safe for
+// concurrent use if shared between concurrent notarizations.
+type ResultCache interface {
+	// Get returns the cached result for the given content hash. If there
+	// is no cached result, this returns a nil result and nil error.
+	Get(ctx context.Context, hash string) (*Result, error)
+
+	// Put stores the result for the given content hash. This is only
+	// called for accepted submissions.
+	Put(ctx context.Context, hash string, result *Result) error
+}
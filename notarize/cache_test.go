@@ -0,0 +1,90 @@
+package notarize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	childCommands["staple-only"] = testCmdStapleOnly
+}
+
+// testResultCache is a ResultCache backed by a map.
+type testResultCache map[string]*Result
+
+func (c testResultCache) Get(_ context.Context, hash string) (*Result, error) {
+	return c[hash], nil
+}
+
+func (c testResultCache) Put(_ context.Context, hash string, result *Result) error {
+	c[hash] = result
+	return nil
+}
+
+func TestNotarize_resultCache(t *testing.T) {
+	cache := testResultCache{}
+	opts := &Options{
+		Logger:      hclog.L(),
+		BaseCmd:     childCmd(t, "notarize-accepted"),
+		Intervals:   testIntervals,
+		ContentHash: "abc123",
+		ResultCache: cache,
+	}
+
+	req := require.New(t)
+	_, _, err := Notarize(context.Background(), opts)
+	req.NoError(err)
+	req.Contains(cache, "abc123")
+
+	// The second notarization must come from the cache, the command
+	// would fail if it was executed.
+	opts.BaseCmd = childCmd(t, "upload-exit-status")
+	info, log, err := Notarize(context.Background(), opts)
+	req.NoError(err)
+	req.Equal(StatusAccepted, info.Status)
+	req.Equal(StatusAccepted, log.Status)
+}
+
+func TestNotarize_resultCacheStaple(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.dmg")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+
+	// The result is cached for the contents of the file, but this file
+	// wasn't stapled yet.
+	cache := testResultCache{"abc123": &Result{
+		File: "other/app.dmg",
+		Info: &Info{RequestUUID: "cfd69166-8e2f-1397-8636-ec06f98e3597", Status: StatusAccepted},
+	}}
+
+	info, _, err := Notarize(context.Background(), &Options{
+		File:        file,
+		Logger:      hclog.L(),
+		BaseCmd:     childCmd(t, "staple-only"),
+		Intervals:   testIntervals,
+		ContentHash: "abc123",
+		ResultCache: cache,
+		Staple:      true,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal(StatusAccepted, info.Status)
+	req.Equal("hello+ticket", testReadFile(t, file))
+}
+
+// testCmdStapleOnly mimicks stapler stapling a ticket and fails any other
+// command, so that notarization must come from the cache.
+func testCmdStapleOnly() int {
+	if len(os.Args) > 1 && os.Args[1] == "stapler" {
+		return testCmdStapleAccepted()
+	}
+
+	fmt.Fprintln(os.Stderr, "unexpected command")
+	return 1
+}
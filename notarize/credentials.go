@@ -0,0 +1,92 @@
+package notarize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// authArgs returns the notarytool arguments that authenticate with Apple,
+// either with an App Store Connect API key or with an Apple ID. The
+// password in the arguments, if any, is also returned so that it can be
+// redacted.
+func authArgs(ctx context.Context, opts *Options) ([]string, string, error) {
+	if opts.APIKeyPath == "" && opts.APIKeyID == "" && opts.APIKeyIssuerID == "" {
+		password, err := resolvePassword(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return []string{
+			"--apple-id", opts.DeveloperId,
+			"--password", password,
+			"--team-id", opts.Provider,
+		}, password, nil
+	}
+
+	if opts.DeveloperId != "" || opts.Password != "" || opts.PasswordFunc != nil {
+		return nil, "", errors.New(
+			"an App Store Connect API key and an Apple ID can't both be used to authenticate")
+	}
+	if opts.APIKeyPath == "" || opts.APIKeyID == "" || opts.APIKeyIssuerID == "" {
+		return nil, "", errors.New(
+			"APIKeyPath, APIKeyID, and APIKeyIssuerID must all be set to authenticate with an API key")
+	}
+
+	return []string{
+		"--key", opts.APIKeyPath,
+		"--key-id", opts.APIKeyID,
+		"--issuer", opts.APIKeyIssuerID,
+	}, "", nil
+}
+
+// copyOptions returns a copy of opts for internal changes, such as setting
+// the Provider. ReauthFunc refreshes the credentials of the Options it was
+// set on, which are the caller's, so the copy picks them up again with
+// refreshCredentials.
+func copyOptions(opts *Options) *Options {
+	c := *opts
+	c.credentialsFrom = opts
+	return &c
+}
+
+// refreshCredentials copies the credentials that are set on the Options
+// that opts was copied from, after ReauthFunc may have changed them.
+func refreshCredentials(opts *Options) {
+	src := opts.credentialsFrom
+	if src == nil {
+		return
+	}
+	refreshCredentials(src)
+
+	for _, f := range []struct{ dst, src *string }{
+		{&opts.DeveloperId, &src.DeveloperId},
+		{&opts.Password, &src.Password},
+		{&opts.APIKeyPath, &src.APIKeyPath},
+		{&opts.APIKeyID, &src.APIKeyID},
+		{&opts.APIKeyIssuerID, &src.APIKeyIssuerID},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	if src.PasswordFunc != nil {
+		opts.PasswordFunc = src.PasswordFunc
+	}
+}
+
+// resolvePassword returns the password to pass to notarytool. PasswordFunc
+// is preferred over Password if both are set. This is called right before
+// each command that needs it so that the result doesn't need to be kept.
+func resolvePassword(ctx context.Context, opts *Options) (string, error) {
+	if opts.PasswordFunc == nil {
+		return opts.Password, nil
+	}
+
+	password, err := opts.PasswordFunc(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error getting password: %w", err)
+	}
+
+	return password, nil
+}
@@ -0,0 +1,66 @@
+package notarize
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePassword(t *testing.T) {
+	req := require.New(t)
+
+	password, err := resolvePassword(context.Background(), &Options{Password: "static"})
+	req.NoError(err)
+	req.Equal("static", password)
+
+	password, err = resolvePassword(context.Background(), &Options{
+		Password:     "static",
+		PasswordFunc: func(context.Context) (string, error) { return "dynamic", nil },
+	})
+	req.NoError(err)
+	req.Equal("dynamic", password)
+
+	_, err = resolvePassword(context.Background(), &Options{
+		PasswordFunc: func(context.Context) (string, error) { return "", errors.New("vault sealed") },
+	})
+	req.ErrorContains(err, "vault sealed")
+}
+
+func TestAuthArgs(t *testing.T) {
+	req := require.New(t)
+
+	args, password, err := authArgs(context.Background(), &Options{
+		DeveloperId: "foo@example.com",
+		Password:    "hunter2",
+		Provider:    "TEAM",
+	})
+	req.NoError(err)
+	req.Equal("hunter2", password)
+	req.Equal([]string{
+		"--apple-id", "foo@example.com", "--password", "hunter2", "--team-id", "TEAM",
+	}, args)
+
+	args, password, err = authArgs(context.Background(), &Options{
+		APIKeyPath:     "AuthKey_ABC.p8",
+		APIKeyID:       "ABC",
+		APIKeyIssuerID: "issuer",
+	})
+	req.NoError(err)
+	req.Empty(password)
+	req.Equal([]string{
+		"--key", "AuthKey_ABC.p8", "--key-id", "ABC", "--issuer", "issuer",
+	}, args)
+
+	_, _, err = authArgs(context.Background(), &Options{
+		APIKeyPath:     "AuthKey_ABC.p8",
+		APIKeyID:       "ABC",
+		APIKeyIssuerID: "issuer",
+		Password:       "hunter2",
+	})
+	req.ErrorContains(err, "can't both be used")
+
+	_, _, err = authArgs(context.Background(), &Options{APIKeyID: "ABC"})
+	req.ErrorContains(err, "must all be set")
+}
@@ -0,0 +1,82 @@
+package notarize
+
+import "sort"
+
+// IssueDelta is an issue that is present in only one of two logs.
+type IssueDelta struct {
+	// Added is true if the issue is only in the newer log and false if
+	// it was only in the older log, meaning it was resolved.
+	Added bool
+
+	// Issue is the issue that was added or removed.
+	Issue LogIssue
+}
+
+// DiffLogs returns the issues that were added or removed going from
+// log a to log b. Issues are matched by code and path. Since notarytool
+// frequently reports issues without a code, the message is also used to
+// match issues that don't have one. An issue that is reported more times
+// in one log than in the other is returned once for each extra time.
+//
+// The result is sorted with removed issues first, then by path, code, and
+// message, so it is stable for comparison in CI. Either log may be nil.
+func DiffLogs(a, b *Log) []IssueDelta {
+	before := issueSet(a)
+	after := issueSet(b)
+
+	var result []IssueDelta
+	for k, issues := range before {
+		for _, issue := range issues[min(len(issues), len(after[k])):] {
+			result = append(result, IssueDelta{Added: false, Issue: issue})
+		}
+	}
+	for k, issues := range after {
+		for _, issue := range issues[min(len(issues), len(before[k])):] {
+			result = append(result, IssueDelta{Added: true, Issue: issue})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		x, y := result[i], result[j]
+		if x.Added != y.Added {
+			return !x.Added
+		}
+		if x.Issue.Path != y.Issue.Path {
+			return x.Issue.Path < y.Issue.Path
+		}
+		if x.Issue.Code != y.Issue.Code {
+			return x.Issue.Code < y.Issue.Code
+		}
+		return x.Issue.Message < y.Issue.Message
+	})
+
+	return result
+}
+
+// issueKey is the key used to match issues between logs.
+type issueKey struct {
+	Code    int
+	Path    string
+	Message string
+}
+
+// issueSet returns the issues in the log keyed by how they're matched.
+// Each key has all the issues that match it in the order they're in the
+// log, so that the number of times an issue is reported can be compared.
+func issueSet(l *Log) map[issueKey][]LogIssue {
+	result := map[issueKey][]LogIssue{}
+	if l == nil {
+		return result
+	}
+
+	for _, issue := range l.Issues {
+		k := issueKey{Code: issue.Code, Path: issue.Path}
+		if issue.Code == 0 {
+			k.Message = issue.Message
+		}
+
+		result[k] = append(result[k], issue)
+	}
+
+	return result
+}
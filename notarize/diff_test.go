@@ -0,0 +1,45 @@
+package notarize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffLogs(t *testing.T) {
+	a := &Log{Issues: []LogIssue{
+		{Path: "gon.zip/foo", Message: "The binary is not signed."},
+		{Path: "gon.zip/foo", Code: 7, Message: "old message"},
+		{Path: "gon.zip/bar", Message: "The signature does not include a secure timestamp."},
+	}}
+	b := &Log{Issues: []LogIssue{
+		{Path: "gon.zip/foo", Code: 7, Message: "new message"},
+		{Path: "gon.zip/foo", Message: "The executable does not have the hardened runtime enabled."},
+	}}
+
+	req := require.New(t)
+	req.Equal([]IssueDelta{
+		{Added: false, Issue: a.Issues[2]},
+		{Added: false, Issue: a.Issues[0]},
+		{Added: true, Issue: b.Issues[1]},
+	}, DiffLogs(a, b))
+
+	req.Empty(DiffLogs(a, a))
+	req.Len(DiffLogs(nil, b), 2)
+}
+
+func TestDiffLogs_count(t *testing.T) {
+	issue := LogIssue{Path: "gon.zip/foo", Message: "The binary is not signed."}
+	a := &Log{Issues: []LogIssue{issue}}
+	b := &Log{Issues: []LogIssue{issue, issue, issue}}
+
+	req := require.New(t)
+	req.Equal([]IssueDelta{
+		{Added: true, Issue: issue},
+		{Added: true, Issue: issue},
+	}, DiffLogs(a, b))
+	req.Equal([]IssueDelta{
+		{Added: false, Issue: issue},
+		{Added: false, Issue: issue},
+	}, DiffLogs(b, a))
+}
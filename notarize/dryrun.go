@@ -0,0 +1,41 @@
+package notarize
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+)
+
+// dryRun checks the file and credentials for Options.DryRun and returns the
+// synthetic info of the submission that would have been made.
+func dryRun(ctx context.Context, opts *Options) (*Info, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	logger = withBuildID(ctx, logger)
+
+	if err := workdir.Validate(opts.WorkDir); err != nil {
+		return nil, err
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	// The history is the cheapest request that needs valid credentials.
+	if _, err := history(ctx, opts); err != nil {
+		return nil, fmt.Errorf("error checking credentials: %w", err)
+	}
+
+	logger.Info("dry run complete, file was not submitted", "file", opts.File)
+	return &Info{
+		Name:          filepath.Base(opts.File),
+		Status:        StatusDryRun,
+		StatusMessage: "Dry run, the file was not submitted",
+	}, nil
+}
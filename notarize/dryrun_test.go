@@ -0,0 +1,102 @@
+package notarize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	childCommands["dry-run"] = testCmdDryRun
+	childCommands["dry-run-auth"] = testCmdDryRunAuth
+}
+
+func TestNotarize_dryRun(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "gon.zip")
+	require.NoError(t, os.WriteFile(file, []byte("PK\x03\x04"), 0644))
+
+	info, log, err := Notarize(context.Background(), &Options{
+		File:    file,
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "dry-run"),
+		DryRun:  true,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Nil(log)
+	req.Equal(StatusDryRun, info.Status)
+	req.Equal("gon.zip", info.Name)
+}
+
+func TestSubmit_dryRun(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "gon.zip")
+	require.NoError(t, os.WriteFile(file, []byte("PK\x03\x04"), 0644))
+
+	// The child fails anything but the history, so this fails if the file
+	// is uploaded.
+	uuid, err := Submit(context.Background(), &Options{
+		File:    file,
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "dry-run"),
+		DryRun:  true,
+	})
+	require.NoError(t, err)
+	require.Empty(t, uuid)
+
+	_, err = Submit(context.Background(), &Options{
+		File:    file,
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "dry-run-auth"),
+		DryRun:  true,
+	})
+	require.ErrorContains(t, err, "error checking credentials")
+}
+
+func TestNotarize_dryRunInvalidFile(t *testing.T) {
+	_, _, err := Notarize(context.Background(), &Options{
+		File:    filepath.Join(t.TempDir(), "missing.zip"),
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "dry-run"),
+		DryRun:  true,
+	})
+	require.ErrorContains(t, err, "can't be read")
+}
+
+func TestNotarize_dryRunCredentials(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "gon.zip")
+	require.NoError(t, os.WriteFile(file, []byte("PK\x03\x04"), 0644))
+
+	_, _, err := Notarize(context.Background(), &Options{
+		File:    file,
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "dry-run-auth"),
+		DryRun:  true,
+	})
+	require.ErrorContains(t, err, "error checking credentials")
+}
+
+// testCmdDryRun mimicks notarytool history for an account without any
+// submissions and fails anything else, which a dry run must not run.
+func testCmdDryRun() int {
+	if len(os.Args) > 2 && os.Args[2] == "history" {
+		fmt.Println(strings.TrimSpace(testHistoryPlist("")))
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "unexpected command in dry run: %v\n", os.Args)
+	return 1
+}
+
+// testCmdDryRunAuth mimicks notarytool history with invalid credentials.
+func testCmdDryRunAuth() int {
+	fmt.Fprintln(os.Stderr, "Error: HTTP status code: 401. Invalid credentials. "+
+		"Username or password is incorrect.")
+	return 1
+}
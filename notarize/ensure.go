@@ -0,0 +1,167 @@
+package notarize
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+	"github.com/asahasrabuddhe/gon/staple"
+)
+
+// ensureHistoryLimit is the number of recent submissions that
+// EnsureNotarized looks through for an accepted submission of the file.
+const ensureHistoryLimit = 10
+
+// EnsureNotarized notarizes and staples file unless that was already done,
+// which makes it safe to call repeatedly from incremental builds. The File
+// field of opts is ignored in favor of file.
+//
+// A file is already done if either:
+//
+//   - `stapler validate` reports a valid ticket stapled to it, in which
+//     case nothing is done.
+//   - One of the 10 most recent submissions of the account, as reported by
+//     `notarytool history`, was accepted, has the same name, and its log
+//     reports the same SHA-256 as the file. In that case the existing
+//     ticket is stapled. Bundles are zipped before uploading, which doesn't
+//     reproduce the same hash, so only dmg and pkg files can be matched.
+//
+// Otherwise the file is notarized with Notarize and stapled afterwards.
+// Only app bundles, dmg, and pkg files support stapling. Other files, such
+// as zip archives, can't be detected as done and are always notarized.
+//
+// With Options.DryRun, nothing is stapled, so the file isn't changed.
+//
+// Once the file is stapled it is assessed by Gatekeeper with `spctl`, and
+// the outcome is set in the GatekeeperAccepted and Assessment fields of the
+// result. A rejection isn't returned as an error since notarization itself
+// succeeded, and neither is failing to assess the file, which is set in the
+// AssessErr field instead. Files that aren't stapled aren't assessed.
+func EnsureNotarized(ctx context.Context, file string, opts *Options) (*Result, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	logger = withBuildID(ctx, logger)
+
+	result := &Result{File: file}
+
+	stapleable := canStaple(file)
+	if stapleable {
+		if err := staple.Validate(ctx, stapleOptions(logger, opts, file, "validate")); err == nil {
+			logger.Info("file is already notarized and stapled", "file", file)
+			return stapled(ctx, logger, opts, result)
+		}
+
+		if uuid := findAccepted(ctx, logger, opts, file); uuid != "" {
+			if opts.DryRun {
+				logger.Info("dry run, not stapling ticket of previous submission",
+					"file", file, "uuid", uuid)
+				result.Info = &Info{RequestUUID: uuid, Name: filepath.Base(file), Status: StatusAccepted}
+				return result, nil
+			}
+
+			stapleStart := time.Now()
+			err := staple.Staple(ctx, stapleOptions(logger, opts, file, "staple"))
+			if err == nil {
+				result.StapleDuration = time.Since(stapleStart)
+				logger.Info("file was already notarized, stapled existing ticket",
+					"file", file, "uuid", uuid)
+				result.Info = &Info{RequestUUID: uuid, Name: filepath.Base(file), Status: StatusAccepted}
+				return stapled(ctx, logger, opts, result)
+			}
+
+			logger.Warn("error stapling ticket of previous submission, will notarize",
+				"file", file, "uuid", uuid, "err", err)
+		}
+	}
+
+	notarizeOpts := copyOptions(opts)
+	notarizeOpts.File = file
+	notarizeOpts.Staple = false
+
+	start := time.Now()
+	result.Info, result.Log, result.Err = notarize(ctx, notarizeOpts, &result.Attempts)
+	result.Duration = time.Since(start)
+	if result.Err != nil || !stapleable || opts.DryRun {
+		return result, result.Err
+	}
+
+	stapleStart := time.Now()
+	if err := staple.Staple(ctx, stapleOptions(logger, opts, file, "staple")); err != nil {
+		result.Err = err
+		return result, err
+	}
+	result.StapleDuration = time.Since(stapleStart)
+
+	return stapled(ctx, logger, opts, result)
+}
+
+// findAccepted returns the UUID of a recent accepted submission of a file
+// with the same name and contents as file, or an empty string if there is
+// none. Errors are only logged since the file can always be notarized.
+func findAccepted(ctx context.Context, logger hclog.Logger, opts *Options, file string) string {
+	hash, err := fileSHA256(workdir.Path(opts.WorkDir, file))
+	if err != nil {
+		logger.Debug("not looking for previous submissions of file", "file", file, "err", err)
+		return ""
+	}
+
+	submissions, err := History(ctx, opts, ensureHistoryLimit)
+	if err != nil {
+		logger.Warn("error requesting submission history, will notarize", "file", file, "err", err)
+		return ""
+	}
+
+	name := filepath.Base(file)
+	for _, s := range submissions {
+		if s.Status != StatusAccepted || s.Name != name {
+			continue
+		}
+
+		l, err := log(ctx, s.RequestUUID, opts)
+		if err != nil {
+			logger.Debug("error requesting log of previous submission",
+				"uuid", s.RequestUUID, "err", err)
+			continue
+		}
+
+		if strings.EqualFold(l.SHA256, hash) {
+			return s.RequestUUID
+		}
+	}
+
+	return ""
+}
+
+// stapled marks result as stapled and assesses the file with Gatekeeper.
+// Failing to assess the file is only set in the result since the file is
+// notarized and stapled regardless.
+func stapled(ctx context.Context, logger hclog.Logger, opts *Options, result *Result) (*Result, error) {
+	result.Stapled = true
+
+	accepted, assessment, err := assess(ctx, logger, opts, result.File)
+	if err != nil {
+		logger.Warn("error assessing file with gatekeeper", "file", result.File, "err", err)
+		result.AssessErr = err
+		return result, nil
+	}
+
+	result.GatekeeperAccepted = accepted
+	result.Assessment = assessment
+	return result, nil
+}
+
+// canStaple returns true if file is of a type that supports stapling.
+func canStaple(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".app", ".dmg", ".pkg":
+		return true
+	default:
+		return false
+	}
+}
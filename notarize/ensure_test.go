@@ -0,0 +1,227 @@
+package notarize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	childCommands["ensure"] = testCmdEnsure
+}
+
+func TestEnsureNotarized_zip(t *testing.T) {
+	// Zip files can't be stapled so they are always notarized.
+	result, err := EnsureNotarized(context.Background(), "gon.zip", &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal("gon.zip", result.File)
+	req.Equal(StatusAccepted, result.Info.Status)
+	req.False(result.Stapled)
+	req.Empty(result.Attempts)
+}
+
+func TestEnsureNotarized_alreadyStapled(t *testing.T) {
+	file, cmd := testEnsureFile(t, "stapled")
+
+	result, err := EnsureNotarized(context.Background(), file, &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   cmd,
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.True(result.Stapled)
+	req.True(result.GatekeeperAccepted)
+	req.Nil(result.Info)
+	req.Equal("hello", testReadFile(t, file))
+}
+
+func TestEnsureNotarized_history(t *testing.T) {
+	file, cmd := testEnsureFile(t, "history")
+
+	result, err := EnsureNotarized(context.Background(), file, &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   cmd,
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.True(result.Stapled)
+	req.Equal("previous", result.Info.RequestUUID)
+	req.Equal(StatusAccepted, result.Info.Status)
+	req.Nil(result.Log)
+	req.Equal("hello+ticket", testReadFile(t, file))
+}
+
+func TestEnsureNotarized_notarize(t *testing.T) {
+	// The history has a submission of the same name with other contents.
+	file, cmd := testEnsureFile(t, "notarize")
+
+	result, err := EnsureNotarized(context.Background(), file, &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   cmd,
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.True(result.Stapled)
+	req.Equal(StatusAccepted, result.Info.Status)
+	req.Equal(StatusAccepted, result.Log.Status)
+	req.NotZero(result.StapleDuration)
+	req.Equal("hello+ticket", testReadFile(t, file))
+}
+
+func TestEnsureNotarized_assessFailed(t *testing.T) {
+	// spctl can't be run, but the file is stapled so the result is kept.
+	result, err := stapled(context.Background(), hclog.L(), &Options{
+		BaseCmd: &exec.Cmd{Path: filepath.Join(t.TempDir(), "missing")},
+	}, &Result{File: "app.dmg"})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.True(result.Stapled)
+	req.False(result.GatekeeperAccepted)
+	req.Error(result.AssessErr)
+	req.NoError(result.Err)
+}
+
+func TestEnsureNotarized_dryRun(t *testing.T) {
+	// Neither the ticket of a previous submission nor a new one is stapled.
+	for _, mode := range []string{"history", "notarize"} {
+		file, cmd := testEnsureFile(t, mode)
+		contents := "hello"
+		if mode == "notarize" {
+			// A dry run validates the file, which needs a dmg trailer.
+			contents = "koly" + strings.Repeat("\x00", 508)
+			require.NoError(t, os.WriteFile(file, []byte(contents), 0644))
+		}
+
+		result, err := EnsureNotarized(context.Background(), file, &Options{
+			Logger:    hclog.L(),
+			BaseCmd:   cmd,
+			Intervals: testIntervals,
+			DryRun:    true,
+		})
+
+		req := require.New(t)
+		req.NoError(err, mode)
+		req.False(result.Stapled, mode)
+		req.NotNil(result.Info, mode)
+		req.Equal(contents, testReadFile(t, file), mode)
+	}
+}
+
+func TestCanStaple(t *testing.T) {
+	req := require.New(t)
+	req.True(canStaple("Foo.app"))
+	req.True(canStaple("foo.DMG"))
+	req.True(canStaple("foo.pkg"))
+	req.False(canStaple("foo.zip"))
+}
+
+// testEnsureFile creates a dmg to ensure is notarized and returns it along
+// with the command that mimicks the tools in the given mode of
+// testCmdEnsure.
+func testEnsureFile(t *testing.T, mode string) (string, *exec.Cmd) {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "app.dmg")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+	hash, err := fileSHA256(file)
+	require.NoError(t, err)
+
+	cmd := childCmd(t, "ensure")
+	cmd.Env = append(cmd.Env, childEnv+"_MODE="+mode, childEnv+"_SHA256="+hash)
+	return file, cmd
+}
+
+// testReadFile returns the contents of file.
+func testReadFile(t *testing.T, file string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	return string(data)
+}
+
+// testCmdEnsure mimicks every tool used by EnsureNotarized. The mode is
+// one of "stapled", where the file already has a ticket, "history", where
+// the file was accepted before, and "notarize", where it must be
+// submitted. SHA256 is the hash of the file.
+func testCmdEnsure() int {
+	mode := os.Getenv(childEnv + "_MODE")
+	hash := os.Getenv(childEnv + "_SHA256")
+	if mode == "notarize" {
+		hash = "0000000000000000000000000000000000000000000000000000000000000000"
+	}
+
+	if len(os.Args) < 3 {
+		return 1
+	}
+
+	switch os.Args[1] + " " + os.Args[2] {
+	case "stapler validate":
+		if mode != "stapled" {
+			fmt.Fprintln(os.Stderr, "The validate action failed! Error 65.")
+			return 65
+		}
+		fmt.Println("The validate action worked!")
+		return 0
+
+	case "stapler staple":
+		if mode == "stapled" {
+			fmt.Fprintln(os.Stderr, "unexpected staple")
+			return 1
+		}
+		return testCmdStapleAccepted()
+
+	case "notarytool history":
+		fmt.Println(testHistoryPlist(`
+		<dict>
+			<key>id</key><string>other</string>
+			<key>name</key><string>other.dmg</string>
+			<key>status</key><string>Accepted</string>
+		</dict>
+		<dict>
+			<key>id</key><string>previous</string>
+			<key>name</key><string>app.dmg</string>
+			<key>status</key><string>Accepted</string>
+		</dict>`))
+		return 0
+
+	case "notarytool log":
+		fmt.Printf(`{"jobId": %q, "status": "Accepted", "archiveFilename": "app.dmg", "sha256": %q}`+"\n",
+			os.Args[3], hash)
+		return 0
+
+	case "notarytool submit":
+		if mode != "notarize" {
+			fmt.Fprintln(os.Stderr, "unexpected submit")
+			return 1
+		}
+		return testCmdUploadSuccess()
+	}
+
+	if os.Args[1] == "spctl" {
+		fmt.Fprintf(os.Stderr, "%s: accepted\nsource=Notarized Developer ID\n", os.Args[len(os.Args)-1])
+		return 0
+	}
+
+	return testCmdNotarizeAccepted()
+}
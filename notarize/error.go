@@ -1,11 +1,283 @@
 package notarize
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
 )
 
+// Known error codes returned by the Apple notarization tooling.
+const (
+	// codeUUIDNotFound is returned while a submission is still waiting in
+	// Apple's queue and its request UUID can't be looked up yet.
+	codeUUIDNotFound = 1519
+
+	// codeNetworkUnavailable is returned when the network connection to
+	// Apple's notarization service was lost.
+	codeNetworkUnavailable = -19000
+
+	// codeAuthIncorrect, codeAuthSession, and codeAuthAppPassword are
+	// returned when authentication with Apple fails.
+	codeAuthIncorrect   = -20101
+	codeAuthSession     = -22016
+	codeAuthAppPassword = -22938
+)
+
+// ErrAuthExpired is matched by the error returned when authentication with
+// Apple failed while waiting for notarization to complete. Use errors.As
+// with *AuthExpiredError to get the request UUID to resume with.
+var ErrAuthExpired = errors.New("authentication with Apple expired")
+
+// AuthExpiredError is returned when authentication failed after the file
+// was already submitted. The submission continues at Apple, so it can be
+// resumed with the RequestUUID once the credentials are fixed.
+type AuthExpiredError struct {
+	// RequestUUID is the UUID of the submission.
+	RequestUUID string
+
+	// Err is the error that caused authentication to fail.
+	Err error
+}
+
+// Error implements error
+func (e *AuthExpiredError) Error() string {
+	return fmt.Sprintf("%s for request %s: %s", ErrAuthExpired, e.RequestUUID, e.Err)
+}
+
+// Is returns true for ErrAuthExpired.
+func (e *AuthExpiredError) Is(target error) bool {
+	return target == ErrAuthExpired
+}
+
+// Unwrap returns the underlying error.
+func (e *AuthExpiredError) Unwrap() error {
+	return e.Err
+}
+
+// ErrInvalid is matched by the error returned when Apple rejected the
+// file. Use errors.As with *InvalidError to get the final info and log.
+var ErrInvalid = errors.New("package is invalid")
+
+// InvalidError is returned when notarization completed but Apple rejected
+// the file. This is distinct from failing to complete notarization, such
+// as due to network or authentication errors, since resubmitting the same
+// file won't help. The issues in the Log explain what must be fixed.
+type InvalidError struct {
+	// Info and Log are the final info and log of the submission. Log is
+	// nil if the submission was rejected before a log was available.
+	Info *Info
+	Log  *Log
+
+	// Err is a more specific reason, such as an error matching
+	// ErrHashMismatch. This may be nil.
+	Err error
+}
+
+// Error implements error
+func (e *InvalidError) Error() string {
+	if e.Err == nil {
+		return ErrInvalid.Error()
+	}
+
+	return fmt.Sprintf("%s: %s", ErrInvalid, e.Err)
+}
+
+// Is returns true for ErrInvalid.
+func (e *InvalidError) Is(target error) bool {
+	return target == ErrInvalid
+}
+
+// Unwrap returns the more specific reason, if any.
+func (e *InvalidError) Unwrap() error {
+	return e.Err
+}
+
+// ErrInterrupted is matched by the error returned when the context was
+// cancelled after the file was submitted. Use errors.As with
+// *InterruptedError to get the request UUID to check on.
+var ErrInterrupted = errors.New("notarization was interrupted")
+
+// InterruptedError is returned when the context was cancelled, such as by
+// an interrupt signal, while waiting on a submission. Apple continues
+// processing the submission, so it isn't lost and can be checked on with
+// the RequestUUID.
+type InterruptedError struct {
+	// RequestUUID is the UUID of the submission.
+	RequestUUID string
+
+	// Token can be passed to WaitForCompletion to continue waiting on the
+	// submission.
+	Token ResumeToken
+
+	// Err is the cause of the cancellation.
+	Err error
+}
+
+// Error implements error
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("%s: %s\n\nThe submission continues at Apple as request %s. "+
+		"Check on it with `xcrun notarytool info %s`.",
+		ErrInterrupted, e.Err, e.RequestUUID, e.RequestUUID)
+}
+
+// Is returns true for ErrInterrupted.
+func (e *InterruptedError) Is(target error) bool {
+	return target == ErrInterrupted
+}
+
+// Unwrap returns the cause of the cancellation.
+func (e *InterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// interrupted returns err as an *InterruptedError for the submission uuid
+// if ctx was cancelled, and err unchanged otherwise. Exceeding
+// Options.MaxTotalDuration isn't an interruption, so err then only wraps
+// ErrTotalTimeout.
+func interrupted(ctx context.Context, opts *Options, uuid string, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	if errors.Is(context.Cause(ctx), ErrTotalTimeout) {
+		return cancelled(ctx, err)
+	}
+
+	return &InterruptedError{
+		RequestUUID: uuid,
+		Token:       newResumeToken(opts, uuid),
+		Err:         cancelled(ctx, err),
+	}
+}
+
+// cancelled returns err wrapping the cause of ctx being done, if it is, so
+// that callers can tell a cancellation apart from a failure.
+func cancelled(ctx context.Context, err error) error {
+	cause := context.Cause(ctx)
+	if err == nil || cause == nil || errors.Is(err, cause) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", cause, err)
+}
+
+// commandError is returned when a notarytool command failed. The message
+// includes the output of the command, and the errors that the command
+// reported are unwrapped so they can be matched with errors.As and
+// errors.Is.
+type commandError struct {
+	msg  string
+	errs Errors
+}
+
+// commandFailed returns the error for a command that failed with output.
+// msg describes what the command was doing.
+func commandFailed(msg string, output string) error {
+	return &commandError{
+		msg:  fmt.Sprintf("%s:\n\n%s", msg, output),
+		errs: parseErrors(output),
+	}
+}
+
+// Error implements error
+func (e *commandError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the errors reported by the command, if any.
+func (e *commandError) Unwrap() error {
+	if len(e.errs) == 0 {
+		return nil
+	}
+
+	return e.errs
+}
+
+// errorLineRe matches a line of output that reports an error, such as
+// "Error: Submission not found (1519)". The code in parentheses is only
+// present for errors with an Apple error code. altool prefixes the line
+// with "*** ".
+var errorLineRe = regexp.MustCompile(`^(?:\*\*\* )?Error: (.+?)(?: \((-?\d+)\))?$`)
+
+// parseErrors returns the errors reported in the output of a command, in
+// the order they were reported. Errors without a code have a zero Code.
+func parseErrors(output string) Errors {
+	var result Errors
+	for _, line := range strings.Split(output, "\n") {
+		m := errorLineRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+
+		e := Error{Message: m[1]}
+		if m[2] != "" {
+			e.Code, _ = strconv.ParseInt(m[2], 10, 64)
+		}
+		result = append(result, e)
+	}
+
+	return result
+}
+
+// errorCode returns the code of the first error in err reported by Apple,
+// or zero if there is none.
+func errorCode(err error) int64 {
+	var e Errors
+	if errors.As(err, &e) && len(e) > 0 {
+		return e[0].Code
+	}
+
+	return 0
+}
+
+// isAuthError returns true if err is an authentication failure. notarytool
+// reports these as HTTP status codes rather than Apple error codes.
+func isAuthError(err error) bool {
+	var e Errors
+	if errors.As(err, &e) {
+		for _, code := range []int64{codeAuthIncorrect, codeAuthSession, codeAuthAppPassword} {
+			if e.ContainsCode(code) {
+				return true
+			}
+		}
+	}
+
+	msg := err.Error()
+	return strings.Contains(msg, "HTTP status code: 401") ||
+		strings.Contains(msg, "HTTP status code: 403")
+}
+
+// isRateLimitError returns true if err means that Apple is throttling our
+// requests. notarytool reports these as HTTP status codes.
+func isRateLimitError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "HTTP status code: 429") ||
+		strings.Contains(msg, "Too Many Requests")
+}
+
+// codeDescriptions maps known Apple notary and altool error codes to
+// explanations that are more actionable than the raw number.
+var codeDescriptions = map[int]string{
+	codeUUIDNotFound:       "the request UUID was not found, the submission is likely still queued at Apple",
+	codeNetworkUnavailable: "the network connection to Apple's notarization service became unavailable",
+	-18000:                 "Apple rejected the upload, check the message for the specific ITMS error",
+	-1001:                  "the request to Apple's notarization service timed out",
+	-1009:                  "the machine appears to be offline",
+	codeAuthIncorrect:      "the Apple ID or password was entered incorrectly",
+	codeAuthSession:        "unable to create an authentication session, an app-specific password may be required",
+	codeAuthAppPassword:    "an app-specific password is required to sign in with this Apple ID",
+}
+
+// CodeDescription returns a human-readable explanation of a known Apple
+// notarization error code. An empty string is returned for unknown codes.
+func CodeDescription(code int) string {
+	return codeDescriptions[code]
+}
+
 // Error is the error structure generated by the notarization tool.
 type Error struct {
 	Code     int64             `plist:"code"`
@@ -13,7 +285,8 @@ type Error struct {
 	UserInfo map[string]string `plist:"userInfo"`
 }
 
-// Errors is a list of error and also implements error.
+// Errors is a list of error and also implements error. errors.Is and
+// errors.As match the individual errors.
 type Errors []Error
 
 // Error implements error
@@ -21,6 +294,13 @@ func (err Error) Error() string {
 	return fmt.Sprintf("%s (%d)", err.Message, err.Code)
 }
 
+// Is returns true if target is an Error with the same code, so that a
+// specific code can be checked with errors.Is(err, Error{Code: 1519}).
+func (err Error) Is(target error) bool {
+	t, ok := target.(Error)
+	return ok && t.Code == err.Code
+}
+
 // Error implements error
 func (err Errors) Error() string {
 	if len(err) == 0 {
@@ -29,12 +309,47 @@ func (err Errors) Error() string {
 
 	var result error
 	for _, e := range err {
+		if desc := CodeDescription(int(e.Code)); desc != "" {
+			result = multierror.Append(result, fmt.Errorf("%w: %s", e, desc))
+			continue
+		}
+
 		result = multierror.Append(result, e)
 	}
 
 	return result.Error()
 }
 
+// Unwrap returns the individual errors.
+func (err Errors) Unwrap() []error {
+	result := make([]error, len(err))
+	for i, e := range err {
+		result[i] = e
+	}
+
+	return result
+}
+
+// Codes returns the codes of the errors in order.
+func (err Errors) Codes() []int {
+	result := make([]int, len(err))
+	for i, e := range err {
+		result[i] = int(e.Code)
+	}
+
+	return result
+}
+
+// Messages returns the messages of the errors in order.
+func (err Errors) Messages() []string {
+	result := make([]string, len(err))
+	for i, e := range err {
+		result[i] = e.Message
+	}
+
+	return result
+}
+
 // ContainsCode returns true if the errors list has an error with the given code.
 func (err Errors) ContainsCode(code int64) bool {
 	for _, e := range err {
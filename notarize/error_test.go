@@ -0,0 +1,107 @@
+package notarize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	childCommands["errors-multiple"] = testCmdErrorsMultiple
+}
+
+func TestCodeDescription(t *testing.T) {
+	req := require.New(t)
+	req.NotEmpty(CodeDescription(1519))
+	req.NotEmpty(CodeDescription(-19000))
+	req.Empty(CodeDescription(42))
+}
+
+func TestErrorCode(t *testing.T) {
+	req := require.New(t)
+	req.Equal(int64(-19000), errorCode(fmt.Errorf("wrapped: %w", Errors{{Code: -19000}, {Code: 1519}})))
+	req.Zero(errorCode(errors.New("no code")))
+}
+
+func TestErrors_Error(t *testing.T) {
+	err := Errors{
+		{Code: -19000, Message: "network lost"},
+		{Code: 42, Message: "something else"},
+	}
+
+	req := require.New(t)
+	req.Contains(err.Error(), "network lost (-19000): "+CodeDescription(-19000))
+	req.Contains(err.Error(), "something else (42)\n")
+	req.Equal("no errors", Errors{}.Error())
+}
+
+func TestErrors_multiple(t *testing.T) {
+	opts := &Options{
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "errors-multiple"),
+	}
+
+	cases := map[string]func() error{
+		"info": func() error {
+			_, err := info(context.Background(), "foo", opts)
+			return err
+		},
+		"log": func() error {
+			_, err := log(context.Background(), "foo", opts)
+			return err
+		},
+		"upload": func() error {
+			_, err := upload(context.Background(), opts)
+			return err
+		},
+	}
+
+	for name, run := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := run()
+
+			var errs Errors
+			req := require.New(t)
+			req.True(errors.As(err, &errs))
+			req.Equal([]int{-19000, 1519}, errs.Codes())
+			req.Equal([]string{"The network connection was lost.", "Could not find the RequestUUID."}, errs.Messages())
+			req.Len(errs.Unwrap(), 2)
+
+			req.ErrorIs(err, Error{Code: 1519})
+			req.NotErrorIs(err, Error{Code: 42})
+			req.Equal(int64(-19000), errorCode(err))
+
+			var e Error
+			req.True(errors.As(err, &e))
+			req.Equal(int64(-19000), e.Code)
+
+			// The full output is still part of the message.
+			req.Contains(err.Error(), "Error: The network connection was lost. (-19000)")
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	errs := parseErrors("Conducting pre-submission checks...\n" +
+		"Error: HTTP status code: 401. Invalid credentials.\n" +
+		"*** Error: Your Apple ID or password was entered incorrectly. (-20101)\n")
+
+	require.Equal(t, Errors{
+		{Message: "HTTP status code: 401. Invalid credentials."},
+		{Code: -20101, Message: "Your Apple ID or password was entered incorrectly."},
+	}, errs)
+	require.Empty(t, parseErrors("Processing complete\n"))
+}
+
+// testCmdErrorsMultiple mimicks a notarytool command that fails with more
+// than one error.
+func testCmdErrorsMultiple() int {
+	fmt.Fprintln(os.Stderr, "Error: The network connection was lost. (-19000)")
+	fmt.Fprintln(os.Stderr, "Error: Could not find the RequestUUID. (1519)")
+	return 1
+}
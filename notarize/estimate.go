@@ -0,0 +1,57 @@
+package notarize
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// estimateSamples is the number of recent completed submissions that
+// EstimateQueueTime samples.
+const estimateSamples = 5
+
+// ErrNoEstimate is returned by EstimateQueueTime when there is no recent
+// submission with processing dates to estimate from.
+var ErrNoEstimate = errors.New("no recent completed submissions to estimate from")
+
+// EstimateQueueTime estimates how long Apple currently takes to process a
+// submission, from submission until processing completed. This is the
+// median over the account's most recent completed submissions, so it is a
+// rough "typical wait right now" rather than a guarantee.
+//
+// This requests the info of each sampled submission, and requires a
+// notarytool version that reports processingCompleteDate. ErrNoEstimate
+// is returned if no sampled submission had the dates needed.
+func EstimateQueueTime(ctx context.Context, opts *Options) (time.Duration, error) {
+	submissions, err := history(ctx, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	var durations []time.Duration
+	for _, s := range submissions {
+		if len(durations) >= estimateSamples {
+			break
+		}
+		if !s.Status.Terminal() {
+			continue
+		}
+
+		i, err := info(ctx, s.RequestUUID, opts)
+		if err != nil {
+			return 0, err
+		}
+
+		if d, ok := i.ProcessingDuration(); ok {
+			durations = append(durations, d)
+		}
+	}
+
+	if len(durations) == 0 {
+		return 0, ErrNoEstimate
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations[len(durations)/2], nil
+}
@@ -0,0 +1,109 @@
+package notarize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	childCommands["estimate"] = testCmdEstimate
+}
+
+func TestEstimateQueueTime(t *testing.T) {
+	d, err := EstimateQueueTime(context.Background(), &Options{
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "estimate"),
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Minute, d)
+}
+
+func TestEstimateQueueTime_noHistory(t *testing.T) {
+	cmd := childCmd(t, "estimate")
+	cmd.Env = append(cmd.Env, childEnv+"_EMPTY=1")
+
+	_, err := EstimateQueueTime(context.Background(), &Options{
+		Logger:  hclog.L(),
+		BaseCmd: cmd,
+	})
+
+	require.ErrorIs(t, err, ErrNoEstimate)
+}
+
+// testCmdEstimate mimicks notarytool history and info for an account with
+// three completed submissions and one in progress. The arguments are
+// replaced by the command, so the history is instead made empty with an
+// environment variable.
+func testCmdEstimate() int {
+	n := len(os.Args)
+	switch {
+	case n > 2 && os.Args[2] == "history":
+		if os.Getenv(childEnv+"_EMPTY") != "" {
+			fmt.Println(strings.TrimSpace(testHistoryPlist("")))
+			return 0
+		}
+
+		fmt.Println(strings.TrimSpace(testHistoryPlist(`
+		<dict>
+			<key>id</key><string>in-progress</string>
+			<key>status</key><string>In Progress</string>
+		</dict>
+		<dict>
+			<key>id</key><string>two-minutes</string>
+			<key>status</key><string>Accepted</string>
+		</dict>
+		<dict>
+			<key>id</key><string>five-minutes</string>
+			<key>status</key><string>Invalid</string>
+		</dict>
+		<dict>
+			<key>id</key><string>ten-minutes</string>
+			<key>status</key><string>Accepted</string>
+		</dict>
+`)))
+		return 0
+
+	case n > 3 && os.Args[2] == "info":
+		minutes := map[string]int{"two-minutes": 2, "five-minutes": 5, "ten-minutes": 10}[os.Args[3]]
+		fmt.Printf(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+		<key>createdDate</key>
+		<string>2023-08-01T08:00:00Z</string>
+		<key>processingCompleteDate</key>
+		<string>2023-08-01T08:%02d:00Z</string>
+		<key>id</key>
+		<string>%s</string>
+		<key>status</key>
+		<string>Accepted</string>
+</dict>
+</plist>
+`)+"\n", minutes, os.Args[3])
+		return 0
+	}
+
+	return 1
+}
+
+// testHistoryPlist returns history output with the given entries.
+func testHistoryPlist(entries string) string {
+	return `
+<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>history</key>
+	<array>` + entries + `</array>
+</dict>
+</plist>`
+}
@@ -0,0 +1,79 @@
+package notarize
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+)
+
+// assess runs the Gatekeeper assessment `spctl --assess` on file and
+// returns whether it was accepted along with spctl's output. A rejection
+// isn't an error, only failing to run spctl is.
+func assess(ctx context.Context, logger hclog.Logger, opts *Options, file string) (bool, string, error) {
+	tool := []string{"spctl", "--assess", "-vv"}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".pkg":
+		tool = append(tool, "--type", "install")
+
+	case ".dmg":
+		tool = append(tool, "--type", "open", "--context", "context:primary-signature")
+
+	default:
+		tool = append(tool, "--type", "execute")
+	}
+	tool = append(tool, file)
+
+	// Build our command
+	var cmd exec.Cmd
+	if opts.BaseCmd != nil {
+		cmd = *opts.BaseCmd
+		cmd.Args = append([]string{filepath.Base(cmd.Path)}, tool...)
+	}
+
+	// We only set the path if it isn't set. spctl isn't part of the
+	// developer tools so it is run directly rather than through xcrun.
+	if cmd.Path == "" {
+		path, err := exec.LookPath("spctl")
+		if err != nil {
+			return false, "", err
+		}
+
+		cmd = *(exec.CommandContext(ctx, path, tool[1:]...))
+	}
+
+	workdir.Apply(&cmd, opts.WorkDir)
+
+	// spctl writes its assessment to stderr
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	// Log what we're going to execute
+	logger.Info("assessing file with gatekeeper",
+		"file", file,
+		"command_path", cmd.Path,
+		"command_args", cmd.Args,
+	)
+
+	err := cmd.Run()
+	assessment := strings.TrimSpace(out.String())
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		logger.Warn("gatekeeper rejected file", "file", file, "output", assessment)
+		return false, assessment, nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+
+	logger.Info("gatekeeper accepted file", "file", file, "output", assessment)
+	return true, assessment, nil
+}
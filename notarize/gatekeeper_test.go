@@ -0,0 +1,60 @@
+package notarize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	childCommands["spctl-accepted"] = testCmdSpctlAccepted
+	childCommands["spctl-rejected"] = testCmdSpctlRejected
+}
+
+func TestAssess_accepted(t *testing.T) {
+	accepted, assessment, err := assess(context.Background(), hclog.L(), &Options{
+		BaseCmd: childCmd(t, "spctl-accepted"),
+	}, "foo.pkg")
+
+	req := require.New(t)
+	req.NoError(err)
+	req.True(accepted)
+	req.Equal("foo.pkg: accepted\nsource=Notarized Developer ID", assessment)
+}
+
+func TestAssess_rejected(t *testing.T) {
+	accepted, assessment, err := assess(context.Background(), hclog.L(), &Options{
+		BaseCmd: childCmd(t, "spctl-rejected"),
+	}, "Foo.app")
+
+	req := require.New(t)
+	req.NoError(err)
+	req.False(accepted)
+	req.Equal("Foo.app: rejected\nsource=Unnotarized Developer ID", assessment)
+}
+
+// testCmdSpctlAccepted accepts installer packages.
+func testCmdSpctlAccepted() int {
+	if os.Args[1] != "spctl" || os.Args[len(os.Args)-2] != "install" {
+		fmt.Fprintf(os.Stderr, "unexpected args: %v\n", os.Args)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: accepted\nsource=Notarized Developer ID\n", os.Args[len(os.Args)-1])
+	return 0
+}
+
+// testCmdSpctlRejected rejects applications.
+func testCmdSpctlRejected() int {
+	if os.Args[1] != "spctl" || os.Args[len(os.Args)-2] != "execute" {
+		fmt.Fprintf(os.Stderr, "unexpected args: %v\n", os.Args)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: rejected\nsource=Unnotarized Developer ID\n", os.Args[len(os.Args)-1])
+	return 3
+}
@@ -0,0 +1,106 @@
+package notarize
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/go-hclog"
+	"howett.net/plist"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+)
+
+// Submission is a single past submission reported by notarytool history.
+type Submission struct {
+	// RequestUUID is the UUID of the submission.
+	RequestUUID string `plist:"id"`
+
+	// Date is the date and time of submission.
+	Date string `plist:"createdDate"`
+
+	// Name is the file that was uploaded for submission.
+	Name string `plist:"name"`
+
+	// Status is the status of the submission.
+	Status SubmissionStatus `plist:"status"`
+}
+
+// History returns up to limit of the most recent submissions for the
+// account, most recent first. A limit of zero or less returns all of the
+// submissions that notarytool reports. This uses the same credentials as
+// Notarize, and is useful to find the request UUID of a submission that
+// wasn't recorded, such as after a crash.
+func History(ctx context.Context, opts *Options, limit int) ([]Submission, error) {
+	submissions, err := history(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && len(submissions) > limit {
+		submissions = submissions[:limit]
+	}
+
+	return submissions, nil
+}
+
+// history requests the recent submissions for the account, most recent
+// first.
+func history(ctx context.Context, opts *Options) ([]Submission, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	logger = withBuildID(ctx, logger)
+
+	auth, _, err := authArgs(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build our command
+	args := append([]string{"history"}, auth...)
+	cmd, err := notarytoolCmd(ctx, opts, append(args, outputFormatArgs(opts)...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	workdir.Apply(cmd, opts.WorkDir)
+
+	// We store all output in out for logging and in case there is an error
+	var out, combined bytes.Buffer
+	teeOutput(cmd, opts, io.MultiWriter(&out, &combined), &combined)
+
+	// Log what we're going to execute
+	logger.Info("requesting submission history",
+		"command_path", cmd.Path,
+		"command_args", cmd.Args,
+	)
+
+	// Execute
+	err = cmd.Run()
+
+	// Log the result
+	logger.Info("submission history command finished",
+		"output", out.String(),
+		"err", err,
+	)
+
+	// Now we check the error for actually running the process
+	if err != nil {
+		return nil, commandFailed("error requesting submission history", combined.String())
+	}
+
+	var result historyResult
+	if _, perr := plist.Unmarshal(out.Bytes(), &result); perr != nil {
+		return nil, fmt.Errorf("failed to decode submission history output: %w", perr)
+	}
+
+	return result.History, nil
+}
+
+// historyResult is the plist structure of the history output.
+type historyResult struct {
+	History []Submission `plist:"history"`
+}
@@ -0,0 +1,36 @@
+package notarize
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	opts := &Options{
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "estimate"),
+	}
+
+	submissions, err := History(context.Background(), opts, 0)
+	require.NoError(t, err)
+	require.Len(t, submissions, 4)
+	require.Equal(t, "in-progress", submissions[0].RequestUUID)
+	require.Equal(t, StatusInProgress, submissions[0].Status)
+
+	submissions, err = History(context.Background(), opts, 2)
+	require.NoError(t, err)
+	require.Len(t, submissions, 2)
+	require.Equal(t, "two-minutes", submissions[1].RequestUUID)
+}
+
+func TestHistory_error(t *testing.T) {
+	_, err := History(context.Background(), &Options{
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "dry-run-auth"),
+	}, 0)
+
+	require.Error(t, err)
+}
@@ -0,0 +1,20 @@
+package notarize
+
+import (
+	"net/http"
+	"time"
+)
+
+// defaultHTTPClient is used for HTTP requests made directly by this package
+// when Options.HTTPClient isn't set. Unlike http.DefaultClient, it has a
+// timeout so a hung request can't stall notarization.
+var defaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// httpClient returns the HTTP client to use for the given options.
+func httpClient(opts *Options) *http.Client {
+	if opts.HTTPClient != nil {
+		return opts.HTTPClient
+	}
+
+	return defaultHTTPClient
+}
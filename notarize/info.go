@@ -5,14 +5,18 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os/exec"
-	"path/filepath"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"howett.net/plist"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
 )
 
 // Info is the information structure for the state of a notarization request.
+// The JSON field names are those of `notarytool info --output-format json`,
+// so the info can be stored and loaded again, such as between a Submit and
+// a Wait in different steps of a build.
 //
 // All fields should be checked against their zero value since certain values
 // only become available at different states of the notarization process. If
@@ -22,60 +26,102 @@ type Info struct {
 	// RequestUUID is the UUID provided by Apple after submitting the
 	// notarization request. This can be used to look up notarization information
 	// using the Apple tooling.
-	RequestUUID string `plist:"id"`
+	RequestUUID string `plist:"id" json:"id"`
 
 	// Date is the date and time of submission
-	Date string `plist:"createdDate"`
+	Date string `plist:"createdDate" json:"createdDate"`
 
 	// Name is th file uploaded for submission.
-	Name string `plist:"name"`
+	Name string `plist:"name" json:"name"`
 
 	// Status the status of the notarization.
-	Status string `plist:"status"`
+	Status SubmissionStatus `plist:"status" json:"status"`
 
 	// StatusMessage is a human-friendly message associated with a status.
-	StatusMessage string `plist:"message"`
+	StatusMessage string `plist:"message" json:"message"`
+
+	// ProcessingCompleteDate is the date and time Apple finished processing
+	// the submission. Older versions of notarytool don't report this, in
+	// which case it is empty.
+	ProcessingCompleteDate string `plist:"processingCompleteDate" json:"processingCompleteDate,omitempty"`
+
+	// SubmittedAt is when the upload of the file completed, and
+	// UploadDuration is how long the upload took. QueueDuration is how
+	// long the submission then waited in Apple's queue, and
+	// AnalysisDuration is how long Apple took to analyze it once it left
+	// the queue. These are measured by Notarize as it observes each phase,
+	// so they are zero if a phase wasn't observed, such as when waiting
+	// with WaitForCompletion or Wait. Apple doesn't report these.
+	SubmittedAt      time.Time     `plist:"-" json:"submittedAt,omitempty"`
+	UploadDuration   time.Duration `plist:"-" json:"uploadDuration,omitempty"`
+	QueueDuration    time.Duration `plist:"-" json:"queueDuration,omitempty"`
+	AnalysisDuration time.Duration `plist:"-" json:"analysisDuration,omitempty"`
+
+	// Raw is the exact output of `notarytool info` that the other fields
+	// were decoded from. This is useful to inspect fields or statuses that
+	// aren't decoded. This is nil if the info wasn't requested. This isn't
+	// included when the info is marshaled to JSON.
+	Raw []byte `plist:"-" json:"-"`
+}
+
+// String returns a summary of the info for logging, such as
+// "<uuid>: Accepted (Successfully received submission info)".
+func (i Info) String() string {
+	result := fmt.Sprintf("%s: %s", i.RequestUUID, i.Status)
+	if i.Status == "" {
+		result = fmt.Sprintf("%s: unknown status", i.RequestUUID)
+	}
+	if i.StatusMessage != "" {
+		result += fmt.Sprintf(" (%s)", i.StatusMessage)
+	}
+
+	return result
+}
+
+// ProcessingDuration returns how long Apple took to process the submission,
+// from its creation until processing completed. This is measured by Apple
+// so it is independent of how often the status is polled. The boolean is
+// false if either date is missing or couldn't be parsed.
+func (i *Info) ProcessingDuration() (time.Duration, bool) {
+	created, err := time.Parse(time.RFC3339, i.Date)
+	if err != nil {
+		return 0, false
+	}
+
+	completed, err := time.Parse(time.RFC3339, i.ProcessingCompleteDate)
+	if err != nil {
+		return 0, false
+	}
+
+	return completed.Sub(created), true
 }
 
 // info requests the information about a notarization and returns
 // the updated information.
-func info(_ context.Context, uuid string, opts *Options) (*Info, error) {
+func info(ctx context.Context, uuid string, opts *Options) (*Info, error) {
 	logger := opts.Logger
 	if logger == nil {
 		logger = hclog.NewNullLogger()
 	}
+	logger = withBuildID(ctx, logger)
 
-	// Build our command
-	var cmd exec.Cmd
-	if opts.BaseCmd != nil {
-		cmd = *opts.BaseCmd
+	auth, password, err := authArgs(ctx, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	// We only set the path if it isn't set. This lets the options set the
-	// path to the codesigning binary that we use.
-	if cmd.Path == "" {
-		path, err := exec.LookPath("xcrun")
-		if err != nil {
-			return nil, err
-		}
-		cmd.Path = path
+	// Build our command
+	args := append([]string{"info", uuid}, auth...)
+	cmd, err := notarytoolCmd(ctx, opts, append(args, outputFormatArgs(opts)...)...)
+	if err != nil {
+		return nil, err
 	}
 
-	cmd.Args = []string{
-		filepath.Base(cmd.Path),
-		"notarytool",
-		"info",
-		uuid,
-		"--apple-id", opts.DeveloperId,
-		"--password", opts.Password,
-		"--team-id", opts.Provider,
-		"--output-format", "plist",
-	}
+	workdir.Apply(cmd, opts.WorkDir)
 
 	// We store all output in out for logging and in case there is an error
 	var out, combined bytes.Buffer
-	cmd.Stdout = io.MultiWriter(&out, &combined)
-	cmd.Stderr = &combined
+	teeOutput(cmd, opts, io.MultiWriter(&out, &combined), &combined)
 
 	// Log what we're going to execute
 	logger.Info("requesting notarization info",
@@ -85,28 +131,43 @@ func info(_ context.Context, uuid string, opts *Options) (*Info, error) {
 	)
 
 	// Execute
-	err := cmd.Run()
+	err = cmd.Run()
 
 	// Log the result
 	logger.Info("notarization info command finished",
 		"output", out.String(),
 		"err", err,
 	)
+	writeArtifact(logger, opts, "info-"+uuid+".plist", out.Bytes(), password)
 
 	// If we have any output, try to decode that since even in the case of
 	// an error it will output some information.
-	var result Info
+	result := &Info{}
 	if out.Len() > 0 {
-		if _, perr := plist.Unmarshal(out.Bytes(), &result); perr != nil {
-			return nil, fmt.Errorf("failed to decode notarization submission output: %w", perr)
+		var perr error
+		if result, perr = parseInfo(out.Bytes()); perr != nil {
+			return nil, perr
 		}
 	}
 
 	// Now we check the error for actually running the process
 	if err != nil {
-		return nil, fmt.Errorf("error checking on notarization status:\n\n%s", combined.String())
+		return nil, commandFailed("error checking on notarization status", combined.String())
 	}
 
 	logger.Info("notarization info", "uuid", uuid, "info", result)
+
+	// This is set after logging since the output was already logged.
+	result.Raw = out.Bytes()
+	return result, nil
+}
+
+// parseInfo decodes the plist output of `notarytool info`.
+func parseInfo(data []byte) (*Info, error) {
+	var result Info
+	if _, err := plist.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode notarization submission output: %w", err)
+	}
+
 	return &result, nil
 }
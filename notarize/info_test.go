@@ -2,9 +2,15 @@ package notarize
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/require"
@@ -13,6 +19,10 @@ import (
 func init() {
 	childCommands["info-accepted"] = testCmdInfoAcceptedSubmission
 	childCommands["info-invalid"] = testCmdInfoInvalidSubmission
+	childCommands["info-processed"] = testCmdInfoProcessedSubmission
+	childCommands["info-format"] = testCmdInfoFormat
+	childCommands["info-locator"] = testCmdInfoLocator
+	childCommands["info-queued"] = testCmdNotarizeResubmit
 }
 
 func TestInfo_accepted(t *testing.T) {
@@ -24,8 +34,9 @@ func TestInfo_accepted(t *testing.T) {
 	req := require.New(t)
 	req.NoError(err)
 	req.Equal(info.RequestUUID, "32684f68-d63e-49ba-9234-25eeec84b369")
-	req.Equal(info.Status, "Accepted")
+	req.Equal(info.Status, StatusAccepted)
 	req.Equal(info.StatusMessage, "Successfully received submission info")
+	req.Contains(string(info.Raw), "<string>Accepted</string>")
 }
 
 func TestInfo_invalid(t *testing.T) {
@@ -37,7 +48,81 @@ func TestInfo_invalid(t *testing.T) {
 	req := require.New(t)
 	req.NoError(err)
 	req.Equal(info.RequestUUID, "cfd69166-8e2f-1397-8636-ec06f98e3597")
-	req.Equal(info.Status, "Invalid")
+	req.Equal(info.Status, StatusInvalid)
+}
+
+func TestInfo_queued(t *testing.T) {
+	_, err := info(context.Background(), "stuck", &Options{
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "info-queued"),
+	})
+
+	req := require.New(t)
+	req.Error(err)
+	req.True(isQueuedError(err))
+	req.ErrorIs(err, Error{Code: codeUUIDNotFound})
+	req.Contains(err.Error(), "Submission not found (1519)")
+}
+
+func TestInfo_json(t *testing.T) {
+	info, err := info(context.Background(), "foo", &Options{
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "info-accepted"),
+	})
+	require.NoError(t, err)
+	info.SubmittedAt = time.Date(2023, 8, 1, 8, 22, 0, 0, time.UTC)
+	info.QueueDuration = time.Minute
+
+	data, err := json.Marshal(info)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"id":"32684f68-d63e-49ba-9234-25eeec84b369"`)
+	require.NotContains(t, string(data), "Raw")
+
+	var result Info
+	require.NoError(t, json.Unmarshal(data, &result))
+	info.Raw = nil
+	require.Equal(t, *info, result)
+}
+
+func TestInfo_String(t *testing.T) {
+	info := Info{
+		RequestUUID:   "foo",
+		Status:        StatusAccepted,
+		StatusMessage: "Successfully received submission info",
+	}
+	require.Equal(t, "foo: Accepted (Successfully received submission info)", info.String())
+	require.Equal(t, "foo: unknown status", fmt.Sprint(&Info{RequestUUID: "foo"}))
+}
+
+func TestInfo_processingDuration(t *testing.T) {
+	info, err := info(context.Background(), "foo", &Options{
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "info-processed"),
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal("2023-08-01T08:25:49.939Z", info.ProcessingCompleteDate)
+
+	d, ok := info.ProcessingDuration()
+	req.True(ok)
+	req.Equal(210*time.Second, d)
+}
+
+func TestInfo_outputFormatArgs(t *testing.T) {
+	info, err := info(context.Background(), "foo", &Options{
+		Logger:           hclog.L(),
+		BaseCmd:          childCmd(t, "info-format"),
+		OutputFormatArgs: []string{"-f", "plist"},
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, StatusAccepted, info.Status)
+}
+
+func TestInfo_processingDurationMissing(t *testing.T) {
+	_, ok := (&Info{Date: "2023-08-01T08:22:19.939Z"}).ProcessingDuration()
+	require.False(t, ok)
 }
 
 // testCmdInfoAcceptedSubmission mimicks an accepted submission.
@@ -85,3 +170,103 @@ func testCmdInfoInvalidSubmission() int {
 `))
 	return 0
 }
+
+// testCmdInfoProcessedSubmission mimicks an accepted submission from a
+// notarytool version that reports when processing completed.
+func testCmdInfoProcessedSubmission() int {
+	fmt.Println(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+		<key>createdDate</key>
+		<string>2023-08-01T08:22:19.939Z</string>
+		<key>processingCompleteDate</key>
+		<string>2023-08-01T08:25:49.939Z</string>
+		<key>id</key>
+		<string>32684f68-d63e-49ba-9234-25eeec84b369</string>
+		<key>message</key>
+		<string>Successfully received submission info</string>
+		<key>name</key>
+		<string>binary.zip</string>
+		<key>status</key>
+		<string>Accepted</string>
+</dict>
+</plist>
+`))
+	return 0
+}
+
+// testCmdInfoFormat mimicks a notarytool version that spells the output
+// format flag as -f.
+func testCmdInfoFormat() int {
+	if os.Args[len(os.Args)-2] != "-f" {
+		fmt.Fprintln(os.Stderr, "Error: Unknown option '--output-format'")
+		return 64
+	}
+
+	return testCmdInfoAcceptedSubmission()
+}
+
+func TestInfo_notarytoolLocator(t *testing.T) {
+	info, err := info(context.Background(), "foo", &Options{
+		Logger: hclog.L(),
+		NotarytoolLocator: func() (*exec.Cmd, error) {
+			// Mimic a wrapper script that takes notarytool as an argument.
+			return childCmd(t, "info-locator", "notarytool"), nil
+		},
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, StatusAccepted, info.Status)
+}
+
+func TestInfo_notarytoolLocatorShared(t *testing.T) {
+	// A single located command is shared by concurrent commands and must
+	// not be modified by them.
+	located := childCmd(t, "info-locator", "notarytool")
+	args := append([]string(nil), located.Args...)
+	opts := &Options{
+		Logger: hclog.L(),
+		NotarytoolLocator: func() (*exec.Cmd, error) {
+			return located, nil
+		},
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = info(context.Background(), "foo", opts)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	require.Equal(t, args, located.Args)
+}
+
+func TestInfo_notarytoolLocatorError(t *testing.T) {
+	_, err := info(context.Background(), "foo", &Options{
+		Logger: hclog.L(),
+		NotarytoolLocator: func() (*exec.Cmd, error) {
+			return nil, errors.New("notarytool not found")
+		},
+	})
+	require.ErrorContains(t, err, "notarytool not found")
+}
+
+// testCmdInfoLocator checks that the arguments of the located command come
+// before those of the info command.
+func testCmdInfoLocator() int {
+	if len(os.Args) < 3 || os.Args[1] != "notarytool" || os.Args[2] != "info" {
+		fmt.Fprintf(os.Stderr, "unexpected args: %v\n", os.Args)
+		return 1
+	}
+
+	return testCmdInfoAcceptedSubmission()
+}
@@ -0,0 +1,93 @@
+package notarize
+
+import "time"
+
+// Intervals are the durations Notarize waits between the requests it makes
+// to Apple. Any field left at its zero value uses the documented default.
+type Intervals struct {
+	// QueuePoll is the initial interval between info requests while the
+	// submission is still waiting in Apple's queue. This defaults to 10
+	// seconds.
+	QueuePoll time.Duration
+
+	// QueuePollMax is the maximum interval between info requests while the
+	// submission is still waiting in Apple's queue. The interval starts at
+	// QueuePoll and doubles after each request that finds the submission
+	// still queued, up to this. This defaults to 5 minutes. Set it to
+	// QueuePoll to poll at a fixed interval.
+	QueuePollMax time.Duration
+
+	// StatusPoll is the interval between info requests while the submission
+	// is being analyzed. This defaults to 5 seconds.
+	StatusPoll time.Duration
+
+	// NetworkRetry is how long to wait before retrying a request that failed
+	// because the network became unavailable. This defaults to 5 seconds.
+	NetworkRetry time.Duration
+
+	// LogPoll is the interval between log requests until the log reaches
+	// a terminal state. This defaults to 5 seconds.
+	LogPoll time.Duration
+
+	// RateLimitRetry is how long to wait before retrying a request that
+	// Apple rejected because of rate limiting. This doubles for each
+	// consecutive rate limited request, up to RateLimitRetryMax. These
+	// default to 1 minute and 15 minutes.
+	RateLimitRetry    time.Duration
+	RateLimitRetryMax time.Duration
+}
+
+// withDefaults returns a copy of the intervals with zero values replaced
+// by their defaults.
+func (i Intervals) withDefaults() Intervals {
+	if i.QueuePoll == 0 {
+		i.QueuePoll = 10 * time.Second
+	}
+	if i.QueuePollMax == 0 {
+		i.QueuePollMax = 5 * time.Minute
+	}
+	if i.QueuePollMax < i.QueuePoll {
+		i.QueuePollMax = i.QueuePoll
+	}
+	if i.StatusPoll == 0 {
+		i.StatusPoll = 5 * time.Second
+	}
+	if i.NetworkRetry == 0 {
+		i.NetworkRetry = 5 * time.Second
+	}
+	if i.LogPoll == 0 {
+		i.LogPoll = 5 * time.Second
+	}
+	if i.RateLimitRetry == 0 {
+		i.RateLimitRetry = time.Minute
+	}
+	if i.RateLimitRetryMax == 0 {
+		i.RateLimitRetryMax = 15 * time.Minute
+	}
+	if i.RateLimitRetryMax < i.RateLimitRetry {
+		i.RateLimitRetryMax = i.RateLimitRetry
+	}
+
+	return i
+}
+
+// nextQueuePoll returns the queue interval that follows d, which doubles
+// up to QueuePollMax.
+func (i Intervals) nextQueuePoll(d time.Duration) time.Duration {
+	return doubled(d, i.QueuePollMax)
+}
+
+// nextRateLimitRetry returns the rate limit wait that follows d, which
+// doubles up to RateLimitRetryMax.
+func (i Intervals) nextRateLimitRetry(d time.Duration) time.Duration {
+	return doubled(d, i.RateLimitRetryMax)
+}
+
+// doubled returns twice d, but at most max.
+func doubled(d, max time.Duration) time.Duration {
+	if d >= max/2 {
+		return max
+	}
+
+	return 2 * d
+}
@@ -0,0 +1,44 @@
+package notarize
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntervals_nextQueuePoll(t *testing.T) {
+	i := Intervals{}.withDefaults()
+
+	var seen []time.Duration
+	for d := i.QueuePoll; len(seen) < 8; d = i.nextQueuePoll(d) {
+		seen = append(seen, d)
+	}
+
+	require.Equal(t, []time.Duration{
+		10 * time.Second,
+		20 * time.Second,
+		40 * time.Second,
+		80 * time.Second,
+		160 * time.Second,
+		5 * time.Minute,
+		5 * time.Minute,
+		5 * time.Minute,
+	}, seen)
+}
+
+func TestIntervals_nextRateLimitRetry(t *testing.T) {
+	i := Intervals{RateLimitRetry: 5 * time.Minute}.withDefaults()
+	require.Equal(t, 10*time.Minute, i.nextRateLimitRetry(i.RateLimitRetry))
+	require.Equal(t, 15*time.Minute, i.nextRateLimitRetry(10*time.Minute))
+	require.Equal(t, 15*time.Minute, i.nextRateLimitRetry(15*time.Minute))
+}
+
+func TestIntervals_nextQueuePollFixed(t *testing.T) {
+	i := Intervals{QueuePoll: time.Minute, QueuePollMax: time.Minute}.withDefaults()
+	require.Equal(t, time.Minute, i.nextQueuePoll(i.QueuePoll))
+
+	// A maximum below the initial interval also keeps it fixed.
+	i = Intervals{QueuePoll: time.Hour}.withDefaults()
+	require.Equal(t, time.Hour, i.nextQueuePoll(i.QueuePoll))
+}
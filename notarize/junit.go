@@ -0,0 +1,153 @@
+package notarize
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteJUnit writes the result as a JUnit XML report to w so that CI
+// systems can display notarization alongside other test results.
+//
+// The notarization is reported as a single test case named after the
+// file. It passes if the submission was accepted, fails with the log
+// issues as the failure body if it was invalid, and is reported as an
+// error for anything else. The request UUID and timings are included
+// as properties of the test suite.
+//
+// Each phase that was timed is also reported as a passing test case named
+// after the file and the phase, "upload", "wait", or "staple", with the
+// time that the phase took. The wait is the time spent in Apple's queue
+// and analyzing the file.
+func WriteJUnit(w io.Writer, r *Result) error {
+	tc := junitTestCase{
+		ClassName: "notarize",
+		Name:      r.File,
+		Time:      r.Duration.Seconds(),
+	}
+
+	suite := junitTestSuite{
+		Name: "notarize",
+		Time: tc.Time,
+	}
+
+	var uuid string
+	var status SubmissionStatus
+	if r.Info != nil {
+		uuid = r.Info.RequestUUID
+		status = r.Info.Status
+		suite.Properties = append(suite.Properties,
+			junitProperty{Name: "request_uuid", Value: uuid},
+			junitProperty{Name: "created_date", Value: r.Info.Date},
+		)
+	}
+	suite.Properties = append(suite.Properties,
+		junitProperty{Name: "duration", Value: r.Duration.String()})
+
+	switch {
+	case status == StatusInvalid || (r.Log != nil && r.Log.Status == StatusInvalid):
+		var body strings.Builder
+		if r.Log != nil {
+			for _, issue := range r.Log.Issues {
+				fmt.Fprintf(&body, "%s: %s: %s\n", issue.Severity, issue.Path, issue.Message)
+			}
+		}
+
+		suite.Failures = 1
+		tc.Failure = &junitMessage{
+			Message: "package is invalid",
+			Type:    "Invalid",
+			Body:    body.String(),
+		}
+
+	case r.Err != nil:
+		suite.Errors = 1
+		tc.Error = &junitMessage{Message: r.Err.Error(), Type: "error"}
+
+	case status != StatusAccepted:
+		suite.Errors = 1
+		tc.Error = &junitMessage{
+			Message: fmt.Sprintf("notarization did not complete, status %q", status),
+			Type:    "error",
+		}
+	}
+
+	suite.TestCases = []junitTestCase{tc}
+
+	var phases []junitPhase
+	if r.Info != nil {
+		phases = append(phases,
+			junitPhase{"upload", r.Info.UploadDuration},
+			junitPhase{"wait", r.Info.QueueDuration + r.Info.AnalysisDuration},
+		)
+	}
+	phases = append(phases, junitPhase{"staple", r.StapleDuration})
+	for _, phase := range phases {
+		if phase.duration <= 0 {
+			continue
+		}
+
+		suite.TestCases = append(suite.TestCases, junitTestCase{
+			ClassName: "notarize",
+			Name:      r.File + " " + phase.name,
+			Time:      phase.duration.Seconds(),
+		})
+	}
+	suite.Tests = len(suite.TestCases)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// junitPhase is a phase of notarization that is reported as a test case.
+type junitPhase struct {
+	name     string
+	duration time.Duration
+}
+
+// junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Time       float64         `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
@@ -0,0 +1,80 @@
+package notarize
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJUnit_accepted(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteJUnit(&buf, &Result{
+		File:     "gon.zip",
+		Info:     &Info{RequestUUID: "foo", Status: "Accepted"},
+		Log:      &Log{Status: "Accepted"},
+		Duration: 90 * time.Second,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Contains(buf.String(), `<testsuite name="notarize" tests="1" failures="0" errors="0" time="90">`)
+	req.Contains(buf.String(), `<property name="request_uuid" value="foo"></property>`)
+	req.Contains(buf.String(), `<testcase classname="notarize" name="gon.zip" time="90"></testcase>`)
+}
+
+func TestWriteJUnit_phases(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteJUnit(&buf, &Result{
+		File: "gon.dmg",
+		Info: &Info{
+			RequestUUID:      "foo",
+			Status:           "Accepted",
+			UploadDuration:   10 * time.Second,
+			QueueDuration:    30 * time.Second,
+			AnalysisDuration: 60 * time.Second,
+		},
+		Log:            &Log{Status: "Accepted"},
+		Duration:       105 * time.Second,
+		StapleDuration: 5 * time.Second,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Contains(buf.String(), `<testsuite name="notarize" tests="4" failures="0" errors="0" time="105">`)
+	req.Contains(buf.String(), `<testcase classname="notarize" name="gon.dmg upload" time="10"></testcase>`)
+	req.Contains(buf.String(), `<testcase classname="notarize" name="gon.dmg wait" time="90"></testcase>`)
+	req.Contains(buf.String(), `<testcase classname="notarize" name="gon.dmg staple" time="5"></testcase>`)
+}
+
+func TestWriteJUnit_invalid(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteJUnit(&buf, &Result{
+		File: "gon.zip",
+		Info: &Info{RequestUUID: "foo", Status: "Invalid"},
+		Log: &Log{Status: "Invalid", Issues: []LogIssue{
+			{Severity: "error", Path: "gon.zip/foo", Message: "The binary is not signed."},
+		}},
+		Err: errors.New("package is invalid"),
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Contains(buf.String(), `failures="1" errors="0"`)
+	req.Contains(buf.String(), `<failure message="package is invalid" type="Invalid">error: gon.zip/foo: The binary is not signed.`)
+}
+
+func TestWriteJUnit_error(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteJUnit(&buf, &Result{
+		File: "gon.zip",
+		Err:  errors.New("error submitting"),
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Contains(buf.String(), `failures="0" errors="1"`)
+	req.Contains(buf.String(), `<error message="error submitting" type="error"></error>`)
+}
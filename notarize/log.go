@@ -6,16 +6,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
 )
 
 // Log Retrieves notarization log for a single completed submission
 type Log struct {
 	JobId           string             `json:"jobId"`
-	Status          string             `json:"status"`
+	Status          SubmissionStatus   `json:"status"`
 	StatusSummary   string             `json:"statusSummary"`
 	StatusCode      int                `json:"statusCode"`
 	ArchiveFilename string             `json:"archiveFilename"`
@@ -23,13 +26,126 @@ type Log struct {
 	SHA256          string             `json:"sha256"`
 	Issues          []LogIssue         `json:"issues"`
 	TicketContents  []LogTicketContent `json:"ticketContents"`
+
+	// IssuesTruncated is true if Apple reported more issues than
+	// Options.MaxLogIssues, in which case only the first are in Issues.
+	// Apple never reports this, it is only set by us.
+	IssuesTruncated bool `json:"issuesTruncated,omitempty"`
+
+	// Raw is the exact output of `notarytool log` that the other fields
+	// were decoded from. This includes all the issues even if they were
+	// truncated. This isn't included when the log is marshaled to JSON.
+	Raw []byte `json:"-"`
+}
+
+// String returns a summary of the log for logging, including the codes of
+// any errors, such as "<job id>: Invalid (Archive contains critical
+// validation errors), 2 issues, error codes: 4000".
+func (l Log) String() string {
+	result := fmt.Sprintf("%s: %s", l.JobId, l.Status)
+	if l.StatusSummary != "" {
+		result += fmt.Sprintf(" (%s)", l.StatusSummary)
+	}
+	if len(l.Issues) == 0 {
+		return result
+	}
+
+	result += fmt.Sprintf(", %d issues", len(l.Issues))
+
+	var codes []string
+	seen := map[int]struct{}{}
+	for _, issue := range l.Issues {
+		if _, ok := seen[issue.Code]; ok || issue.Code == 0 || issue.Severity != "error" {
+			continue
+		}
+
+		seen[issue.Code] = struct{}{}
+		codes = append(codes, strconv.Itoa(issue.Code))
+	}
+	if len(codes) > 0 {
+		result += ", error codes: " + strings.Join(codes, ", ")
+	}
+
+	return result
+}
+
+// defaultMaxLogIssues is the default for Options.MaxLogIssues.
+const defaultMaxLogIssues = 1000
+
+// IssuesByBundle groups the issues by the top-level bundle they are in,
+// which is useful for archives that contain multiple apps. The keys are
+// the issue path up to and including the outermost bundle, such as
+// "gon.zip/Foo.app". Issues that aren't within a bundle are keyed by
+// their own path.
+func (l *Log) IssuesByBundle() map[string][]LogIssue {
+	result := map[string][]LogIssue{}
+	for _, issue := range l.Issues {
+		key := bundlePath(issue.Path)
+		result[key] = append(result[key], issue)
+	}
+
+	return result
+}
+
+// bundleExts are the extensions of bundle directories.
+var bundleExts = map[string]struct{}{
+	".app":       {},
+	".appex":     {},
+	".bundle":    {},
+	".framework": {},
+	".kext":      {},
+	".plugin":    {},
+	".xpc":       {},
+}
+
+// bundlePath returns path up to and including its outermost bundle, or
+// path itself if it isn't within a bundle.
+func bundlePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if _, ok := bundleExts[strings.ToLower(filepath.Ext(part))]; ok {
+			return strings.Join(parts[:i+1], "/")
+		}
+	}
+
+	return path
 }
 
 // LogIssue is a single issue that may have occurred during notarization.
 type LogIssue struct {
+	Code     int    `json:"code"`
 	Severity string `json:"severity"`
 	Path     string `json:"path"`
 	Message  string `json:"message"`
+
+	// Architecture is the architecture of the binary at Path that the
+	// issue applies to, such as "x86_64" or "arm64". This is empty for
+	// issues that don't apply to a binary.
+	Architecture string `json:"architecture"`
+
+	// DocumentationURL is the link to Apple's documentation for the issue,
+	// which Apple only includes for some issues. Use DocURL to always get
+	// a link for errors.
+	DocumentationURL string `json:"docUrl"`
+}
+
+// commonIssuesURL is Apple's documentation for resolving common
+// notarization issues.
+const commonIssuesURL = "https://developer.apple.com/documentation/security/resolving-common-notarization-issues"
+
+// DocURL returns the link to Apple's documentation for the issue. If Apple
+// didn't include one, errors link to the documentation for resolving
+// common notarization issues and an empty string is returned otherwise.
+func (i *LogIssue) DocURL() string {
+	if i.DocumentationURL != "" {
+		return i.DocumentationURL
+	}
+
+	if i.Severity == "error" {
+		return commonIssuesURL
+	}
+
+	return ""
 }
 
 // LogTicketContent is an entry that was noted as being within the archive.
@@ -47,43 +163,24 @@ func log(ctx context.Context, uuid string, opts *Options) (*Log, error) {
 	if logger == nil {
 		logger = hclog.NewNullLogger()
 	}
+	logger = withBuildID(ctx, logger)
 
-	// Build our command
-	var cmd exec.Cmd
-	if opts.BaseCmd != nil {
-		cmd = *opts.BaseCmd
+	auth, password, err := authArgs(ctx, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	// We only set the path if it isn't set. This lets the options set the
-	// path to the codesigning binary that we use.
-	if cmd.Path == "" {
-		path, err := exec.LookPath("xcrun")
-		if err != nil {
-			return nil, err
-		}
-		cmd.Path = path
-
-		cmd = *(exec.CommandContext(
-			ctx, path,
-			filepath.Base(cmd.Path), "notarytool", "log", uuid,
-			"--apple-id", opts.DeveloperId, "--password", opts.Password, "--team-id", opts.Provider,
-		))
+	// Build our command
+	cmd, err := notarytoolCmd(ctx, opts, append([]string{"log", uuid}, auth...)...)
+	if err != nil {
+		return nil, err
 	}
 
-	cmd.Args = []string{
-		filepath.Base(cmd.Path),
-		"notarytool",
-		"log",
-		uuid,
-		"--apple-id", opts.DeveloperId,
-		"--password", opts.Password,
-		"--team-id", opts.Provider,
-	}
+	workdir.Apply(cmd, opts.WorkDir)
 
 	// We store all output in out for logging and in case there is an error
 	var out, combined bytes.Buffer
-	cmd.Stdout = io.MultiWriter(&out, &combined)
-	cmd.Stderr = &combined
+	teeOutput(cmd, opts, io.MultiWriter(&out, &combined), &combined)
 
 	// Log what we're going to execute
 	logger.Info("requesting notarization log",
@@ -93,30 +190,97 @@ func log(ctx context.Context, uuid string, opts *Options) (*Log, error) {
 	)
 
 	// Execute
-	err := cmd.Run()
+	err = cmd.Run()
 
 	// Log the result
 	logger.Info("notarization log command finished",
 		"output", out.String(),
 		"err", err,
 	)
+	writeArtifact(logger, opts, "log-"+uuid+".json", out.Bytes(), password)
 
 	// If we have any output, try to decode that since even in the case of
 	// an error it will output some information.
-	var result Log
-	// return &result, json.NewDecoder().Decode(&result)
+	result := &Log{}
 	if out.Len() > 0 {
-		if derr := json.Unmarshal(out.Bytes(), &result); derr != nil {
-			return nil, fmt.Errorf("failed to decode notarization submission output: %w", derr)
-
+		var perr error
+		if result, perr = parseLog(out.Bytes(), opts.MaxLogIssues); perr != nil {
+			return nil, perr
 		}
 	}
 
 	// Now we check the error for actually running the process
 	if err != nil {
-		return nil, fmt.Errorf("error checking on notarization status:\n\n%s", combined.String())
+		return nil, commandFailed("error checking on notarization status", combined.String())
 	}
 
 	logger.Info("notarization log", "uuid", uuid, "info", result)
-	return &result, nil
+
+	// This is set after logging since the output was already logged.
+	result.Raw = out.Bytes()
+	return result, nil
+}
+
+// parseLog decodes the JSON output of `notarytool log`, keeping at most
+// maxIssues issues as described for Options.MaxLogIssues. The issues are
+// decoded one at a time so that the dropped ones are never allocated.
+func parseLog(data []byte, maxIssues int) (*Log, error) {
+	// The issues are kept raw here, which shadows the field of Log, and
+	// decoded below.
+	var result struct {
+		Log
+		Issues json.RawMessage `json:"issues"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode notarization submission output: %w", err)
+	}
+
+	if maxIssues == 0 {
+		maxIssues = defaultMaxLogIssues
+	}
+
+	log := result.Log
+	if err := parseLogIssues(&log, result.Issues, maxIssues); err != nil {
+		return nil, fmt.Errorf("failed to decode notarization submission output: %w", err)
+	}
+
+	return &log, nil
+}
+
+// parseLogIssues decodes the JSON array of issues in data into l, keeping
+// at most maxIssues of them unless maxIssues is negative.
+func parseLogIssues(l *Log, data json.RawMessage, maxIssues int) error {
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("issues must be an array, got %v", tok)
+	}
+
+	for dec.More() {
+		if maxIssues >= 0 && len(l.Issues) >= maxIssues {
+			// Decoding into an empty struct skips the issue.
+			var skip struct{}
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+
+			l.IssuesTruncated = true
+			continue
+		}
+
+		var issue LogIssue
+		if err := dec.Decode(&issue); err != nil {
+			return err
+		}
+		l.Issues = append(l.Issues, issue)
+	}
+
+	return nil
 }
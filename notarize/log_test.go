@@ -2,7 +2,10 @@ package notarize
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -24,10 +27,11 @@ func TestLog_accepted(t *testing.T) {
 	req := require.New(t)
 	req.NoError(err)
 	req.Equal(log.JobId, "3382aa04-e417-46a0-b1b4-42eebf85906c")
-	req.Equal(log.Status, "Accepted")
+	req.Equal(log.Status, StatusAccepted)
 	req.Equal(log.StatusSummary, "Ready for distribution")
 	req.Equal(len(log.Issues), 0)
 	req.Equal(len(log.TicketContents), 1)
+	req.Contains(string(log.Raw), `"jobId": "3382aa04-e417-46a0-b1b4-42eebf85906c"`)
 }
 
 func TestLog_invalid(t *testing.T) {
@@ -39,10 +43,45 @@ func TestLog_invalid(t *testing.T) {
 	req := require.New(t)
 	req.NoError(err)
 	req.Equal(log.JobId, "4ba7c420-7444-44bc-a190-1bd4bad97b13")
-	req.Equal(log.Status, "Invalid")
+	req.Equal(log.Status, StatusInvalid)
 	req.Equal(log.StatusSummary, "Archive contains critical validation errors")
 	req.Equal(len(log.TicketContents), 0)
 	req.Equal(len(log.Issues), 3)
+	req.Equal("x86_64", log.Issues[0].Architecture)
+}
+
+func TestLog_json(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "log", "invalid.json"))
+	require.NoError(t, err)
+	log, err := parseLog(data, 1)
+	require.NoError(t, err)
+	require.True(t, log.IssuesTruncated)
+
+	data, err = json.Marshal(log)
+	require.NoError(t, err)
+
+	var result Log
+	require.NoError(t, json.Unmarshal(data, &result))
+	log.Raw = nil
+	require.Equal(t, *log, result)
+}
+
+func TestLog_String(t *testing.T) {
+	log := Log{
+		JobId:         "foo",
+		Status:        StatusInvalid,
+		StatusSummary: "Archive contains critical validation errors",
+		Issues: []LogIssue{
+			{Code: 4000, Severity: "error"},
+			{Code: 4000, Severity: "error"},
+			{Code: 4001, Severity: "warning"},
+			{Severity: "error"},
+		},
+	}
+	require.Equal(t,
+		"foo: Invalid (Archive contains critical validation errors), 4 issues, error codes: 4000",
+		log.String())
+	require.Equal(t, "foo: Accepted", fmt.Sprint(&Log{JobId: "foo", Status: StatusAccepted}))
 }
 
 // testCmdLogValidSubmission mimicks an accepted submission.
@@ -71,6 +110,38 @@ func testCmdLogValidSubmission() int {
 	return 0
 }
 
+func TestLogIssue_DocURL(t *testing.T) {
+	req := require.New(t)
+
+	issue := LogIssue{Severity: "error", DocumentationURL: "https://example.com/doc"}
+	req.Equal("https://example.com/doc", issue.DocURL())
+
+	issue = LogIssue{Severity: "error"}
+	req.Equal(commonIssuesURL, issue.DocURL())
+
+	issue = LogIssue{Severity: "warning"}
+	req.Empty(issue.DocURL())
+}
+
+func TestLog_IssuesByBundle(t *testing.T) {
+	log := &Log{
+		Issues: []LogIssue{
+			{Path: "tools.zip/Foo.app/Contents/MacOS/foo", Message: "The binary is not signed."},
+			{Path: "tools.zip/Bar.app/Contents/Frameworks/Baz.framework/Baz", Message: "The binary is not signed."},
+			{Path: "tools.zip/Foo.app/Contents/MacOS/helper", Message: "The binary is not signed."},
+			{Path: "tools.zip/README", Message: "Unexpected file."},
+		},
+	}
+
+	groups := log.IssuesByBundle()
+
+	req := require.New(t)
+	req.Len(groups, 3)
+	req.Len(groups["tools.zip/Foo.app"], 2)
+	req.Len(groups["tools.zip/Bar.app"], 1)
+	req.Len(groups["tools.zip/README"], 1)
+}
+
 // testCmdLogInvalidSubmission mimicks an invalid submission.
 func testCmdLogInvalidSubmission() int {
 	fmt.Println(strings.TrimSpace(`
@@ -0,0 +1,23 @@
+package notarize
+
+import "context"
+
+// SubmissionInfo returns the current info of the submission with the
+// given request UUID, without waiting for it to change. The submission
+// may have been made by a different process, so this is useful to check
+// on a submission whose UUID was stored earlier. This authenticates the
+// same way as Notarize.
+//
+// While the submission is still in Apple's queue, this returns an error
+// with the code 1519 since Apple doesn't know the UUID yet.
+func SubmissionInfo(ctx context.Context, uuid string, opts *Options) (*Info, error) {
+	return info(ctx, uuid, opts)
+}
+
+// LogFor returns the log of the submission with the given request UUID,
+// which is the same as its job ID in the log. Like SubmissionInfo, this
+// doesn't wait, and the log is only available once the submission was
+// processed. Options.MaxLogIssues applies.
+func LogFor(ctx context.Context, uuid string, opts *Options) (*Log, error) {
+	return log(ctx, uuid, opts)
+}
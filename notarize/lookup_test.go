@@ -0,0 +1,30 @@
+package notarize
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmissionInfo(t *testing.T) {
+	info, err := SubmissionInfo(context.Background(), "32684f68-d63e-49ba-9234-25eeec84b369", &Options{
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "info-accepted"),
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, StatusAccepted, info.Status)
+}
+
+func TestLogFor(t *testing.T) {
+	log, err := LogFor(context.Background(), "3382aa04-e417-46a0-b1b4-42eebf85906c", &Options{
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "log-accepted"),
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, StatusAccepted, log.Status)
+	require.Equal(t, "3382aa04-e417-46a0-b1b4-42eebf85906c", log.JobId)
+}
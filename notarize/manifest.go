@@ -0,0 +1,71 @@
+package notarize
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+)
+
+// ManifestSuffix is appended to the path of a file to get the path of the
+// manifest written by Options.WriteManifest.
+const ManifestSuffix = ".notary.json"
+
+// Manifest is the JSON manifest written next to a notarized file when
+// Options.WriteManifest is set.
+type Manifest struct {
+	// File is the base name of the notarized file.
+	File string `json:"file"`
+
+	// RequestUUID is the UUID of the submission.
+	RequestUUID string `json:"requestUUID"`
+
+	// Status is the status of the submission.
+	Status SubmissionStatus `json:"status"`
+
+	// Timestamp is when the manifest was written, in UTC.
+	Timestamp time.Time `json:"timestamp"`
+
+	// SHA256 is the hex-encoded SHA-256 of the file. This is empty for
+	// files that aren't regular files, such as app bundles, unless
+	// Options.ContentHash is set.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// writeManifest writes the manifest of the submission info to the path of
+// opts.File with ManifestSuffix.
+func writeManifest(logger hclog.Logger, opts *Options, info *Info) error {
+	path := workdir.Path(opts.WorkDir, opts.File)
+	manifest := Manifest{
+		File:        filepath.Base(path),
+		RequestUUID: info.RequestUUID,
+		Status:      info.Status,
+		Timestamp:   time.Now().UTC(),
+		SHA256:      opts.ContentHash,
+	}
+	if manifest.SHA256 == "" {
+		if hash, err := fileSHA256(path); err == nil {
+			manifest.SHA256 = hash
+		} else {
+			logger.Debug("not hashing file for manifest", "file", path, "err", err)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding notarization manifest: %w", err)
+	}
+
+	manifestPath := path + ManifestSuffix
+	if err := os.WriteFile(manifestPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing notarization manifest: %w", err)
+	}
+
+	logger.Info("wrote notarization manifest", "path", manifestPath)
+	return nil
+}
@@ -0,0 +1,54 @@
+package notarize
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotarize_writeManifest(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.dmg")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+
+	info, _, err := Notarize(context.Background(), &Options{
+		File:          file,
+		Logger:        hclog.L(),
+		BaseCmd:       childCmd(t, "notarize-accepted"),
+		Intervals:     testIntervals,
+		WriteManifest: true,
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(file + ManifestSuffix)
+	require.NoError(t, err)
+
+	var manifest Manifest
+	req := require.New(t)
+	req.NoError(json.Unmarshal(data, &manifest))
+	req.Equal("app.dmg", manifest.File)
+	req.Equal(info.RequestUUID, manifest.RequestUUID)
+	req.Equal(StatusAccepted, manifest.Status)
+	req.False(manifest.Timestamp.IsZero())
+	req.Equal("2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", manifest.SHA256)
+}
+
+func TestNotarize_noManifest(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.dmg")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+
+	_, _, err := Notarize(context.Background(), &Options{
+		File:      file,
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+	})
+	require.NoError(t, err)
+	require.NoFileExists(t, file+ManifestSuffix)
+}
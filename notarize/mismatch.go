@@ -0,0 +1,72 @@
+package notarize
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrHashMismatch is matched by the error returned when a submission was
+// rejected because files were modified after they were signed. Use
+// errors.As with *HashMismatchError to get the offending paths.
+var ErrHashMismatch = errors.New("signed files were modified after signing")
+
+// HashMismatchError is returned when the notarization log reports that the
+// hash of signed code doesn't match its signature. This is almost always
+// caused by modifying a binary, such as stripping or patching it, after it
+// was signed. The fix is to sign as the last step before packaging.
+type HashMismatchError struct {
+	// Paths are the paths reported by Apple with a mismatched hash.
+	Paths []string
+}
+
+// Error implements error
+func (e *HashMismatchError) Error() string {
+	return fmt.Sprintf("%s, re-sign after any modification: %s",
+		ErrHashMismatch, strings.Join(e.Paths, ", "))
+}
+
+// Is returns true for ErrHashMismatch.
+func (e *HashMismatchError) Is(target error) bool {
+	return target == ErrHashMismatch
+}
+
+// hashMismatchMessages are the lowercased fragments of log issue messages
+// that indicate signed code was modified after signing.
+var hashMismatchMessages = []string{
+	"signature of the binary is invalid",
+	"sealed resource is missing or invalid",
+	"hash does not match",
+	"hashes do not match",
+}
+
+// hashMismatch returns a *HashMismatchError if the log has any issues that
+// indicate a hash mismatch, or nil otherwise.
+func hashMismatch(log *Log) *HashMismatchError {
+	if log == nil {
+		return nil
+	}
+
+	var paths []string
+	seen := map[string]struct{}{}
+	for _, issue := range log.Issues {
+		msg := strings.ToLower(issue.Message)
+		for _, m := range hashMismatchMessages {
+			if !strings.Contains(msg, m) {
+				continue
+			}
+
+			if _, ok := seen[issue.Path]; !ok {
+				seen[issue.Path] = struct{}{}
+				paths = append(paths, issue.Path)
+			}
+			break
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil
+	}
+
+	return &HashMismatchError{Paths: paths}
+}
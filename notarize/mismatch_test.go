@@ -0,0 +1,39 @@
+package notarize
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashMismatch(t *testing.T) {
+	log := &Log{
+		Issues: []LogIssue{
+			{Path: "gon.zip/foo", Message: "The binary is not signed."},
+			{Path: "gon.zip/bar", Message: "The signature of the binary is invalid."},
+			{Path: "gon.zip/bar", Message: "The signature of the binary is invalid."},
+			{Path: "gon.zip/baz", Message: "a sealed resource is missing or invalid"},
+		},
+	}
+
+	req := require.New(t)
+	err := hashMismatch(log)
+	req.NotNil(err)
+	req.Equal([]string{"gon.zip/bar", "gon.zip/baz"}, err.Paths)
+
+	wrapped := fmt.Errorf("package is invalid: %w", err)
+	req.ErrorIs(wrapped, ErrHashMismatch)
+
+	var target *HashMismatchError
+	req.True(errors.As(wrapped, &target))
+}
+
+func TestHashMismatch_none(t *testing.T) {
+	req := require.New(t)
+	req.Nil(hashMismatch(nil))
+	req.Nil(hashMismatch(&Log{
+		Issues: []LogIssue{{Path: "gon.zip/foo", Message: "The binary is not signed."}},
+	}))
+}
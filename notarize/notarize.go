@@ -5,18 +5,51 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/tempdir"
+	"github.com/asahasrabuddhe/gon/internal/workdir"
 )
 
 // Options are the options for notarization.
 type Options struct {
 	// File is the file to notarize. This must be in zip, dmg, or pkg format.
+	// This may also be a bundle directory such as an .app, in which case it
+	// is zipped using ZipTool before it is uploaded.
 	File string
 
+	// ZipTool is the tool used to zip File if it is a bundle directory.
+	// This defaults to ZipToolDitto, which is what Apple recommends.
+	ZipTool ZipTool
+
+	// ZipFlags, if non-nil, replaces the default flags passed to ZipTool.
+	// The source and destination paths are always appended after these.
+	ZipFlags []string
+
+	// SkipSignatureCheck disables checking that File is signed before it
+	// is uploaded. By default, bundles and pkg files without a signature
+	// return ErrUnsignedArtifact since Apple always rejects them. Unsigned
+	// dmg files are only warned about since Apple accepts them if their
+	// contents are signed. This is useful to intentionally test rejections.
+	SkipSignatureCheck bool
+
+	// PreUpload, if set, is called with the path of the file that is about
+	// to be uploaded, which is the zip archive for bundle directories. The
+	// path is resolved against WorkDir if it is set. It
+	// may transform the file, such as stripping extended attributes, and
+	// returns the path to upload instead, which may be the same path. Files
+	// created by PreUpload aren't cleaned up by gon. See StripXattrs for a
+	// helper that can be used here.
+	PreUpload func(ctx context.Context, path string) (string, error)
+
 	// DeveloperId is your Apple Developer Apple ID.
 	DeveloperId string
 
@@ -25,11 +58,39 @@ type Options struct {
 	// read from the keychain and environment variables, respectively.
 	Password string
 
-	// Provider is the Apple Connect provider to use. This is optional
-	// and is only used for Apple Connect accounts that support multiple
-	// providers.
+	// PasswordFunc, if set, is called to get the password right before each
+	// notarytool command that needs it, rather than keeping the password in
+	// these Options. This is useful for integrations that fetch secrets
+	// from a vault. This is preferred over Password if both are set. The
+	// password is only kept for as long as the command runs.
+	PasswordFunc func(ctx context.Context) (string, error)
+
+	// Provider is the Apple Connect provider to use, which is its team ID.
+	// This is optional and is only used for Apple Connect accounts that
+	// support multiple providers. If it isn't set, Notarize and Submit
+	// use the only provider of the Apple ID, or return an error matching
+	// ErrProviderRequired that lists them if there are multiple. See
+	// Providers.
 	Provider string
 
+	// APIKeyPath, APIKeyID, and APIKeyIssuerID authenticate with an App
+	// Store Connect API key instead of an Apple ID, which Apple recommends
+	// for CI since the key doesn't expire like an Apple ID password.
+	// APIKeyPath is the path to the .p8 private key file, which is
+	// relative to WorkDir if it is a relative path. Either all or none of
+	// these must be set, and DeveloperId, Password, and PasswordFunc must
+	// not be set if they are.
+	APIKeyPath     string
+	APIKeyID       string
+	APIKeyIssuerID string
+
+	// BundleID is the bundle ID of the file being notarized. notarytool
+	// doesn't need the bundle ID to submit, but it identifies submissions
+	// that must not be uploaded concurrently. This is useful to set
+	// explicitly for archives where the bundle ID can't be detected, such
+	// as a zip of multiple bundles.
+	BundleID string
+
 	// UploadLock, if specified, will limit concurrency when uploading
 	// packages. The notary submission process does not allow concurrent
 	// uploads of packages with the same bundle ID, it appears. If you set
@@ -40,140 +101,593 @@ type Options struct {
 	// the notarization process.
 	Status Status
 
+	// WebhookURL, if set, is posted a JSON document on each status change
+	// with the fields "build_id", "uuid", "event", "status", and
+	// "timestamp". The event is one of "submitting", "submitted",
+	// "queueCleared", "info", "log", "rateLimited", and "largeUpload", where
+	// "info" and "log" include the new status. The build ID is the one set
+	// with WithBuildID, if any. Requests are made with HTTPClient and each
+	// is given at most 5 seconds. Failing to deliver an event is logged but
+	// doesn't interrupt notarization.
+	WebhookURL string
+
+	// QueuedPredicate, if set, replaces the check for whether an error
+	// requesting info means the submission is still waiting in Apple's
+	// queue. By default only the 1519 (UUID not found) error code is
+	// treated this way. This allows adapting if Apple changes the code.
+	QueuedPredicate func(err error) bool
+
+	// OutputFormatArgs, if non-nil, replaces the `--output-format plist`
+	// arguments that are passed to the notarytool commands whose output is
+	// parsed. This allows adapting to notarytool versions that spell the
+	// flag differently. The output must still be a plist.
+	OutputFormatArgs []string
+
+	// Intervals configures how long Notarize waits between the requests it
+	// makes while waiting for notarization to complete.
+	Intervals Intervals
+
+	// MaxPollAttempts, if non-zero, is the maximum number of status and
+	// log requests made while waiting on a submission. Once exceeded, the
+	// best-known Info is returned along with ErrMaxPollAttempts. This is
+	// an alternative to MaxTotalDuration that doesn't depend on how long
+	// each request takes.
+	MaxPollAttempts int
+
+	// ExtraTerminalStatuses are statuses, in addition to the known ones,
+	// that mean Apple finished processing the submission. This lets you
+	// adapt to a status that Apple introduces before gon knows it. Polling
+	// stops once one is reported, and Notarize then returns an error that
+	// includes the status since it can't tell whether the file was
+	// notarized. Without this, polling stops with ErrUnknownStatus after
+	// an unknown status was reported by many consecutive requests.
+	ExtraTerminalStatuses []SubmissionStatus
+
+	// ResubmitAfterQueueTimeout, if non-zero, is how long a submission may
+	// wait in Apple's queue before it is abandoned and the file is submitted
+	// again with a new request UUID. Queued submissions occasionally never
+	// clear, so this avoids re-running the whole build. The file is
+	// resubmitted at most 3 times, after which the last submission is
+	// waited on indefinitely. Abandoned submissions are recorded in the
+	// Attempts of a Result.
+	ResubmitAfterQueueTimeout time.Duration
+
+	// ReauthFunc, if set, is called when a request made while waiting for
+	// notarization fails because authentication expired, for example because
+	// a keychain password was rotated during a long queue wait. It should
+	// refresh the credentials, such as by updating the Password of these
+	// Options, after which the request is retried once. If this is nil or
+	// the retry fails too, an error matching ErrAuthExpired is returned.
+	ReauthFunc func() error
+
+	// UploadRetries is the number of times a submission that failed due to
+	// a transient network error, such as a lost connection, is retried.
+	// Authentication and validation failures aren't retried. This defaults
+	// to 3, set it to a negative value to disable retries.
+	UploadRetries int
+
+	// WarnUploadSize, if non-zero, is the size in bytes above which the
+	// file to upload is considered large. Before uploading a larger file,
+	// Status.LargeUpload is called and a warning is logged so that users
+	// know to expect a slow upload. For bundle directories this is the
+	// size of the zip archive.
+	WarnUploadSize int64
+
+	// SubmitTimeout, if non-zero, limits how long the upload may take. If
+	// the installed notarytool supports `submit --timeout`, that is used
+	// so the tool aborts the upload itself. Otherwise the submit command is
+	// cancelled once the timeout elapses. Which mechanism is active is
+	// logged at the info level.
+	SubmitTimeout time.Duration
+
+	// ServerWait, if true, waits with `notarytool wait` after uploading,
+	// which only returns once Apple finished processing the submission,
+	// rather than polling for it to leave the queue. Only the final info
+	// and the log are requested afterwards, so far fewer commands are
+	// executed. The UploadLock is released before waiting. SubmitTimeout
+	// then also limits the wait. A submission stuck in the queue can't be
+	// resubmitted with ResubmitAfterQueueTimeout. This is ignored by
+	// Submit, which never waits.
+	ServerWait bool
+
+	// MaxTotalDuration, if non-zero, is the maximum wall-clock time that
+	// Notarize may take in total. Once it is exceeded, Notarize returns the
+	// best-known Info and Log along with ErrTotalTimeout. The error doesn't
+	// match ErrInterrupted, which is only for cancellations of ctx.
+	MaxTotalDuration time.Duration
+
+	// HTTPClient is the client used for any HTTP requests this package
+	// makes directly, rather than through notarytool. This lets you control
+	// timeouts, proxies, and TLS configuration. If this is nil, a client with
+	// a 30 second timeout is used. No HTTP requests are made directly unless
+	// a feature documented as using this client is enabled.
+	HTTPClient *http.Client
+
+	// ContentHash is an externally computed hash of the contents of File.
+	// If this and ResultCache are set, the cache is consulted before
+	// submitting and notarization is skipped if a result is cached.
+	ContentHash string
+
+	// ResultCache, if set along with ContentHash, is used to look up and
+	// store the results of accepted submissions.
+	ResultCache ResultCache
+
+	// Endpoint is reserved for overriding the App Store Connect endpoint
+	// that notarytool talks to, such as a staging endpoint for integration
+	// testing. notarytool doesn't currently support an endpoint override
+	// by flag or environment variable, so this is ignored and a warning is
+	// logged if it is set.
+	Endpoint string
+
+	// SkipLog, if true, doesn't request the log of accepted submissions,
+	// which shortens notarization by the time it takes for the log to be
+	// available. The returned Log is nil in this case, so warnings about
+	// the submission aren't available. The log of rejected submissions is
+	// always requested since the issues explain the rejection.
+	SkipLog bool
+
+	// MaxLogIssues is the maximum number of issues kept in the Log, which
+	// protects long-running processes from submissions with thousands of
+	// issues. Log.IssuesTruncated is set if issues were dropped. This
+	// defaults to 1000 and a negative value keeps all issues. The full log
+	// is still written to ArtifactDir if it is set.
+	MaxLogIssues int
+
+	// ArtifactDir, if set, is a directory that the output of notarytool is
+	// written to as evidence of the notarization. This includes the submit
+	// response, every info poll, the log, and a summary.json written once
+	// notarization completes. Each file name starts with a UTC timestamp
+	// and the password is redacted. The directory is created if it doesn't
+	// exist. A relative path is relative to WorkDir.
+	ArtifactDir string
+
+	// DryRun, if true, checks the configuration without submitting File.
+	// File is checked with Validate and the credentials by requesting the
+	// submission history, which is quick and doesn't count against any
+	// notarization quota. If both succeed, Notarize returns an Info with
+	// StatusDryRun and a nil Log.
+	DryRun bool
+
+	// Staple, if true, staples the notarization ticket to File once it was
+	// accepted, using `xcrun stapler staple`. Only app bundles, dmg, and
+	// pkg files can be stapled, so Notarize returns an error before
+	// submitting any other file. The output of stapler is included in the
+	// error if stapling fails. The file is also stapled if its result came
+	// from ResultCache, since the cached acceptance is for its contents.
+	// EnsureNotarized ignores this since it always staples.
+	Staple bool
+
+	// WriteManifest, if true, writes a manifest named after File with a
+	// ".notary.json" suffix next to it once the file was submitted. If
+	// Staple is set, this is written after stapling. This
+	// records the request UUID, status, and hash of the file so that it
+	// can be shipped alongside the file as proof of its notarization. See
+	// Manifest for the contents.
+	WriteManifest bool
+
+	// StatePath, if set, is a file that Notarize checkpoints the submission
+	// to so that a restarted build can continue waiting on it rather than
+	// submitting again. The info is written as JSON once the file was
+	// submitted and whenever its status changes. If the file exists when
+	// Notarize starts, is for the same File with the same contents, and
+	// Apple still knows the submission, the upload is skipped. The file is
+	// removed once Apple accepted or rejected the submission. A relative
+	// path is relative to WorkDir. When notarizing multiple files that
+	// share a StatePath, such as with NotarizeGlob, each file uses the
+	// path with a suffix derived from its contents instead, or from its
+	// path for bundle directories.
+	StatePath string
+
+	// TempDir, if set, is the directory that temporary files are created
+	// in, which defaults to the OS temp directory. Set this to a larger
+	// volume if the default is too small for zipping bundles. A relative
+	// path is relative to WorkDir.
+	TempDir string
+
+	// WorkDir, if set, is the working directory of the commands that are
+	// executed. A relative File is relative to it. This must be an existing
+	// directory.
+	WorkDir string
+
 	// Logger is the logger to use. If this is nil then no logging will be done.
 	Logger hclog.Logger
 
+	// Stdout and Stderr, if set, receive a copy of the output of the
+	// notarytool commands that submit the file and query the submission as
+	// it is written, such as the upload progress. The output is still
+	// parsed as usual. The two are written to concurrently, so a writer
+	// that is used for both must be safe for concurrent use.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// NotarytoolLocator, if set, returns the command that runs notarytool
+	// instead of `xcrun notarytool`. This is useful if notarytool is at a
+	// nonstandard location or must be called through a wrapper script.
+	// The arguments for each notarytool command are appended to the Args of
+	// the returned command, and its Env and Dir are kept. This is called
+	// for each command that is executed, and may be called concurrently
+	// when the same Options are shared, such as by NotarizeBatch or a Queue.
+	// The returned command is only read and never run, so it is fine to
+	// return the same *exec.Cmd every time.
+	NotarytoolLocator func() (*exec.Cmd, error)
+
 	// BaseCmd is the base command for executing app submission. This is
 	// used for tests to overwrite where the codesign binary is. If this isn't
 	// specified then we use `xcrun notarytool` as the base.
 	BaseCmd *exec.Cmd
+
+	// credentialsFrom is the Options these were copied from with
+	// copyOptions, whose credentials are copied again after ReauthFunc
+	// refreshed them.
+	credentialsFrom *Options
+}
+
+// ErrTotalTimeout is returned by Notarize when Options.MaxTotalDuration
+// is exceeded.
+var ErrTotalTimeout = errors.New("notarization exceeded the maximum total duration")
+
+// ErrMaxPollAttempts is returned by Notarize when Options.MaxPollAttempts
+// is exceeded.
+var ErrMaxPollAttempts = errors.New("notarization exceeded the maximum number of poll attempts")
+
+// ErrUnknownStatus is returned by Notarize when Apple kept reporting a
+// status that gon doesn't know. See Options.ExtraTerminalStatuses.
+var ErrUnknownStatus = errors.New("notarization reported an unknown status")
+
+// maxUnknownStatusPolls is the number of consecutive requests that may
+// report an unknown status before we stop waiting with ErrUnknownStatus.
+const maxUnknownStatusPolls = 60
+
+// outputFormatArgs returns the arguments that make notarytool output a
+// plist that we can parse.
+func outputFormatArgs(opts *Options) []string {
+	if opts.OutputFormatArgs != nil {
+		return opts.OutputFormatArgs
+	}
+
+	return []string{"--output-format", "plist"}
 }
 
+// maxResubmits is the maximum number of times a file is resubmitted
+// because of ResubmitAfterQueueTimeout.
+const maxResubmits = 3
+
+// uploadFunc is the function Notarize uses to submit the file. This is
+// only overridden by tests to observe the upload while it is running.
+var uploadFunc = upload
+
 // Notarize performs the notarization process for macOS applications. This
 // will block for the duration of this process which can take many minutes.
 // The Status field in Options can be used to get status change notifications.
 //
 // This will return the notarization info and an error if any occurred.
-// The Info result _may_ be non-nil in the presence of an error and can be
-// used to gather more information about the notarization attempt.
+// The info and log are returned as far as they are known even if there is
+// an error, so they can be used to gather more information about the
+// notarization attempt:
 //
-// If error is nil, then Info is guaranteed to be non-nil.
-// If error is not nil, notarization failed and Info _may_ be non-nil.
+//   - If the error occurred before the file was submitted, both are nil.
+//   - Once the file was submitted, Info is non-nil and has at least the
+//     RequestUUID and the timing fields that were measured. The other
+//     fields are those of the last info that was successfully requested,
+//     if there was one.
+//   - Log is non-nil once a log was successfully requested.
+//
+// If error is nil, then Info is guaranteed to be non-nil, and so is Log
+// unless SkipLog or DryRun is set.
+// A rejection caused by files modified after signing matches
+// ErrHashMismatch.
+//
+// Notarize doesn't take ownership of any file of the caller, including
+// the File and any file returned by PreUpload. The temporary files that it
+// creates itself, such as the zip archive of a bundle directory, are
+// removed before it returns, whether notarization succeeded, failed, or
+// ctx was cancelled.
+//
+// If ctx is cancelled after the file was submitted, the error matches
+// ErrInterrupted and includes the request UUID, since Apple continues to
+// process the submission. notarytool can't cancel a submission, but the
+// UUID can be used to find it with History later. If ctx is cancelled,
+// the error always matches the cause of the cancellation, such as
+// context.Canceled, so that a deliberate cancellation can be told apart
+// from a failure. To handle Ctrl-C this way, notarize with a
+// context from signal.NotifyContext:
+//
+//	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+//	defer stop()
+//
+//	_, _, err := notarize.Notarize(ctx, opts)
+//	var ierr *notarize.InterruptedError
+//	if errors.As(err, &ierr) {
+//		fmt.Println("submission continues at Apple as", ierr.RequestUUID)
+//	}
 func Notarize(ctx context.Context, opts *Options) (*Info, *Log, error) {
+	return notarize(ctx, opts, nil)
+}
+
+// NotarizeWithDeadline is like Notarize, but gives up once d has elapsed.
+// Unlike with Options.MaxTotalDuration, the error then matches
+// context.DeadlineExceeded, as well as ErrInterrupted if the file was
+// already submitted. The best-known Info and Log are returned along with
+// it as documented for Notarize, so the RequestUUID and the last status
+// that was seen aren't lost and the submission can be resumed with
+// WaitForCompletion or reported.
+func NotarizeWithDeadline(ctx context.Context, opts *Options, d time.Duration) (*Info, *Log, error) {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	return Notarize(ctx, opts)
+}
+
+// notarize implements Notarize. If attempts is non-nil, the requests that
+// failed along the way are appended to it.
+func notarize(ctx context.Context, opts *Options, attempts *[]Attempt) (*Info, *Log, error) {
+	if err := validateStaple(opts); err != nil {
+		return nil, nil, err
+	}
+
+	if opts.DryRun {
+		info, err := dryRun(ctx, opts)
+		return info, nil, err
+	}
+
+	started := time.Now()
+	infoResult, logResult, err := notarizeFile(ctx, opts, attempts)
+
 	logger := opts.Logger
 	if logger == nil {
 		logger = hclog.NewNullLogger()
 	}
+	logger = withBuildID(ctx, logger)
+	if err == nil && opts.Staple {
+		err = stapleFile(ctx, logger, opts)
+	}
+	writeSummary(ctx, logger, opts, started, infoResult, logResult, err)
+
+	if opts.WriteManifest && infoResult != nil {
+		if merr := writeManifest(logger, opts, infoResult); merr != nil && err == nil {
+			err = merr
+		}
+	}
+
+	return infoResult, logResult, err
+}
 
-	status := opts.Status
-	if status == nil {
-		status = noopStatus{}
+// notarizeFile notarizes the file without writing the summary artifact.
+func notarizeFile(ctx context.Context, opts *Options, attempts *[]Attempt) (*Info, *Log, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
 	}
+	logger = withBuildID(ctx, logger)
+
+	status := newStatus(ctx, opts, logger)
 
 	lock := opts.UploadLock
 	if lock == nil {
 		lock = &sync.Mutex{}
 	}
 
-	// First perform the upload
-	lock.Lock()
-	status.Submitting()
-	uuid, err := upload(ctx, opts)
-	lock.Unlock()
+	intervals := opts.Intervals.withDefaults()
+
+	if err := workdir.Validate(opts.WorkDir); err != nil {
+		return nil, nil, err
+	}
+
+	opts, err := resolveProvider(ctx, logger, opts)
 	if err != nil {
 		return nil, nil, err
 	}
-	status.Submitted(uuid)
 
-	// Begin polling the info. The first thing we wait for is for the status
-	// _to even exist_. While we get an error requesting info with an error
-	// code of 1519 (UUID not found), then we are stuck in a queue. Sometimes
-	// this queue is hours long. We just have to wait.
-	infoResult := &Info{RequestUUID: uuid}
-	ticker := time.NewTicker(10 * time.Second)
-	for {
-		<-ticker.C
+	if opts.Endpoint != "" {
+		logger.Warn("notarytool doesn't support overriding the endpoint, ignoring it",
+			"endpoint", opts.Endpoint)
+	}
 
-		_, err = info(ctx, infoResult.RequestUUID, opts)
-		if err == nil {
-			ticker.Stop()
-			break
-		}
+	// Enforce the overall time limit. We set the cause so that we can tell
+	// our own deadline apart from one that was set by the caller.
+	if opts.MaxTotalDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, opts.MaxTotalDuration, ErrTotalTimeout)
+		defer cancel()
+	}
 
-		// If we got error code 1519 that means that the UUID was not found.
-		// This means we're in a queue.
-		var e Errors
-		if errors.As(err, &e) && e.ContainsCode(1519) {
-			continue
+	// If this content was already notarized, we can skip all the work.
+	// The cache is only an optimization so we proceed if it fails.
+	useCache := opts.ResultCache != nil && opts.ContentHash != ""
+	if useCache {
+		cached, err := opts.ResultCache.Get(ctx, opts.ContentHash)
+		if err != nil {
+			logger.Warn("error reading result cache, will notarize",
+				"hash", opts.ContentHash, "err", err)
+		}
+		if cached != nil && cached.Info != nil {
+			logger.Info("using cached notarization result", "hash", opts.ContentHash)
+			return cached.Info, cached.Log, nil
 		}
+	}
 
-		ticker.Stop()
-		// A real error, just return that
-		return infoResult, nil, err
+	// A submission from a previous run that was checkpointed to the state
+	// file is resumed rather than submitted again.
+	var p *poller
+	if opts.StatePath != "" {
+		p, err = resumeSubmission(ctx, logger, opts, status, intervals, attempts)
+		if err != nil {
+			return &Info{RequestUUID: p.uuid}, nil, interrupted(ctx, opts, p.uuid, err)
+		}
 	}
 
-	// Now that the UUID result has been found, we poll more quickly
-	// waiting for the analysis to complete. This usually happens within
-	// minutes.
-	for {
-		// Update the info. It is possible for this to return a nil info, and we don't ever want to set result to nil,
-		// so we have a check.
-		infoResult, err = info(ctx, infoResult.RequestUUID, opts)
+	// Submit and wait for the submission to leave Apple's queue. If the
+	// submission is stuck in the queue, we abandon it and submit again.
+	if p == nil {
+		uploadOpts, cleanup, err := prepareUpload(ctx, logger, opts)
 		if err != nil {
-			// This code is the network became unavailable error. If this happens then we just log and retry.
-			var e Errors
-			if errors.As(err, &e) && e.ContainsCode(-19000) {
-				logger.Warn("error that network became unavailable, will retry")
-				// Wait for 5 seconds and try again. I haven't yet found any rate limits to the service so this
-				// seems okay.
-				<-time.After(5 * time.Second)
+			return nil, nil, err
+		}
+		defer cleanup()
+
+		if err := checkUploadSize(logger, status, uploadOpts); err != nil {
+			return nil, nil, err
+		}
+
+		for resubmits := 0; ; resubmits++ {
+			lock.Lock()
+			status.Submitting()
+			uploadStart := time.Now()
+			uuid, err := uploadRetry(ctx, logger, uploadOpts, attempts)
+			submitted := time.Now()
+			lock.Unlock()
+			if err != nil {
+				recordAttempt(attempts, uuid, PhaseSubmit, err, AttemptAborted)
+				if uuid == "" {
+					return nil, nil, cancelled(ctx, err)
+				}
+
+				// The submission exists even though the upload failed, so we
+				// return its UUID to check on.
+				info := &Info{RequestUUID: uuid}
+				if ctx.Err() != nil {
+					return info, nil, interrupted(ctx, opts, uuid, err)
+				}
+
+				return info, nil, fmt.Errorf("%w\n\nThe submission was created as request %s. "+
+					"Check on it with `xcrun notarytool info %s`.", err, uuid, uuid)
+			}
+			status.Submitted(uuid)
+			logger.Debug("upload finished",
+				"uuid", uuid, "upload_duration", submitted.Sub(uploadStart), "resubmits", resubmits)
+
+			// Begin polling the info. The first thing we wait for is for the status
+			// _to even exist_. While we get an error requesting info with an error
+			// code of 1519 (UUID not found), then we are stuck in a queue. Sometimes
+			// this queue is hours long. We just have to wait.
+			p = &poller{
+				opts:      opts,
+				uuid:      uuid,
+				logger:    logger,
+				status:    status,
+				intervals: intervals,
+				attempts:  attempts,
+				submitted: submitted,
+				uploaded:  submitted.Sub(uploadStart),
+			}
+			if resubmits < maxResubmits {
+				p.queueTimeout = opts.ResubmitAfterQueueTimeout
+			}
+
+			// notarytool waits for the submission to be processed, so there
+			// is no queue to poll. How long it was queued is unknown. This
+			// runs after the upload lock was released since the lock only
+			// covers uploads.
+			if opts.ServerWait {
+				if err := serverWait(ctx, opts, uuid); err != nil {
+					recordAttempt(attempts, uuid, PhaseQueue, err, AttemptAborted)
+					infoResult := &Info{RequestUUID: uuid}
+					p.setTiming(infoResult)
+					return infoResult, nil, interrupted(ctx, opts, uuid, err)
+				}
+
+				status.QueueCleared(uuid, 0)
+				break
+			}
+
+			queueStart := time.Now()
+			err = p.waitQueue(ctx)
+			if errors.Is(err, errQueueStuck) {
+				logger.Warn("submission is stuck in the queue, resubmitting",
+					"uuid", uuid, "queue_wait", time.Since(queueStart))
+				recordAttempt(attempts, uuid, PhaseQueue, err, AttemptResubmitted)
 				continue
 			}
+			if err != nil {
+				infoResult := &Info{RequestUUID: uuid}
+				p.setTiming(infoResult)
+				return infoResult, nil, interrupted(ctx, opts, uuid, err)
+			}
 
-			return infoResult, nil, err
+			p.queueCleared = time.Now()
+			status.QueueCleared(uuid, p.queueCleared.Sub(queueStart))
+			logger.Debug("queue cleared", "uuid", uuid, "queue_wait", p.queueCleared.Sub(queueStart))
+			break
 		}
+	}
 
-		status.InfoStatus(*infoResult)
+	infoResult, logResult, final, err := p.complete(ctx)
+	p.setTiming(infoResult)
+	if opts.StatePath != "" && (final == outcomeAccepted || final == outcomeInvalid) {
+		removeState(logger, opts)
+	}
+	if useCache && final == outcomeAccepted {
+		result := &Result{File: opts.File, Info: infoResult, Log: logResult}
+		if err := opts.ResultCache.Put(ctx, opts.ContentHash, result); err != nil {
+			logger.Warn("error writing result cache", "hash", opts.ContentHash, "err", err)
+		}
+	}
 
-		// If we reached a terminal state then exit
-		if infoResult.Status == "Accepted" || infoResult.Status == "Invalid" {
-			break
+	return infoResult, logResult, err
+}
+
+// noCleanup is the cleanup function of prepareUpload when there is nothing
+// to clean up.
+func noCleanup() {}
+
+// prepareUpload checks opts.File and prepares it for upload. This returns
+// the options to upload with, which differ from opts if the file was
+// zipped or replaced by PreUpload. The returned function removes any
+// temporary files and must be called once uploading is done.
+func prepareUpload(ctx context.Context, logger hclog.Logger, opts *Options) (*Options, func(), error) {
+	cleanup := noCleanup
+
+	// Apple always rejects unsigned files, which takes a full round trip,
+	// so we check for a signature before doing anything else.
+	if !opts.SkipSignatureCheck {
+		if err := checkSignature(ctx, logger, opts, opts.File); err != nil {
+			return nil, noCleanup, err
 		}
 	}
 
-	logResult := &Log{JobId: uuid}
-	for {
-		// Update the log. It is possible for this to return a nil log, and we don't ever want to set result to nil,
-		// so we have a check.
-		logResult, err = log(ctx, logResult.JobId, opts)
+	// notarytool only accepts archives, so bundle directories are zipped
+	// into a temporary directory that we clean up once we're done.
+	uploadOpts := opts
+	if bundle := workdir.Path(opts.WorkDir, opts.File); isBundle(bundle) {
+		tmp := workdir.Path(opts.WorkDir, opts.TempDir)
+		if err := tempdir.Validate(tmp, tempdir.Size(bundle)); err != nil {
+			return nil, noCleanup, err
+		}
+
+		td, err := os.MkdirTemp(tmp, "gon-notarize")
 		if err != nil {
-			// This code is the network became unavailable error. If this
-			// happens then we just log and retry.
-			var e Errors
-			if errors.As(err, &e) && e.ContainsCode(-19000) {
-				logger.Warn("error that network became unavailable, will retry")
-				// Wait for 5 seconds and try again. I haven't yet found any rate limits to the service so this
-				// seems okay.
-				<-time.After(5 * time.Second)
-				continue
-			}
+			return nil, noCleanup, err
+		}
+		cleanup = func() { os.RemoveAll(td) }
 
-			return infoResult, logResult, err
+		zipPath := filepath.Join(td, filepath.Base(opts.File)+".zip")
+		if err := zipBundle(ctx, opts, opts.File, zipPath); err != nil {
+			cleanup()
+			return nil, noCleanup, err
 		}
 
-		status.LogStatus(*logResult)
+		// We only replace the file for the upload, the rest of the process
+		// keeps using opts so that ReauthFunc can update it.
+		optsCopy := *opts
+		optsCopy.File = zipPath
+		uploadOpts = &optsCopy
+	}
 
-		// If we reached a terminal state then exit
-		if logResult.Status == "Accepted" || logResult.Status == "Invalid" {
-			break
+	if opts.PreUpload != nil {
+		resolved := workdir.Path(opts.WorkDir, uploadOpts.File)
+		path, err := opts.PreUpload(ctx, resolved)
+		if err != nil {
+			cleanup()
+			return nil, noCleanup, fmt.Errorf("error preparing %s for upload: %w", resolved, err)
 		}
-	}
 
-	// If we're in an invalid status then return an error
-	err = nil
-	if logResult.Status == "Invalid" && infoResult.Status == "Invalid" {
-		err = fmt.Errorf("package is invalid")
+		if path != resolved {
+			logger.Info("uploading file returned by PreUpload", "file", path)
+			optsCopy := *uploadOpts
+			optsCopy.File = path
+			uploadOpts = &optsCopy
+		}
 	}
 
-	return infoResult, logResult, err
+	return uploadOpts, cleanup, nil
 }
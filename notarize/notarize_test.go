@@ -1,12 +1,21 @@
 package notarize
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMain(m *testing.M) {
@@ -15,14 +24,37 @@ func TestMain(m *testing.M) {
 	logger.SetLevel(hclog.Trace)
 	hclog.SetDefault(logger)
 
-	// If we got a subcommand, run that
+	// If we got a subcommand, run that. Bundles are zipped through the
+	// same BaseCmd, so every subcommand zips the same way.
 	if v := os.Getenv(childEnv); v != "" && childCommands[v] != nil {
+		if len(os.Args) > 1 && (os.Args[1] == "ditto" || os.Args[1] == "zip") {
+			os.Exit(testCmdZip())
+		}
 		os.Exit(childCommands[v]())
 	}
 
 	os.Exit(m.Run())
 }
 
+func init() {
+	childCommands["notarize-accepted"] = testCmdNotarizeAccepted
+	childCommands["notarize-info-error"] = testCmdNotarizeInfoError
+	childCommands["notarize-in-progress"] = testCmdNotarizeInProgress
+	childCommands["notarize-info-auth"] = testCmdNotarizeInfoAuth
+	childCommands["notarize-info-auth-code"] = testCmdNotarizeInfoAuthCode
+	childCommands["notarize-reauth-password"] = testCmdNotarizeReauthPassword
+	childCommands["notarize-resubmit"] = testCmdNotarizeResubmit
+	childCommands["notarize-queued"] = testCmdNotarizeQueued
+	childCommands["notarize-log-network"] = testCmdNotarizeLogNetwork
+	childCommands["notarize-unsigned"] = testCmdNotarizeUnsigned
+	childCommands["notarize-signature-error"] = testCmdNotarizeSignatureError
+	childCommands["notarize-upload-flaky"] = testCmdNotarizeUploadFlaky
+	childCommands["notarize-upload-partial"] = testCmdNotarizeUploadPartial
+	childCommands["notarize-server-wait"] = testCmdNotarizeServerWait
+	childCommands["notarize-rate-limited"] = testCmdNotarizeRateLimited
+	childCommands["notarize-unknown-status"] = testCmdNotarizeUnknownStatus
+}
+
 // childEnv is the env var that must be set to trigger a child command.
 const childEnv = "GON_TEST_CHILD"
 
@@ -41,11 +73,1211 @@ func childCmd(t *testing.T, name string, args ...string) *exec.Cmd {
 		return nil
 	}
 
+	// With -race, children otherwise sleep for a second before exiting,
+	// which tests with short deadlines can't wait for.
 	cmd := exec.Command(selfPath, args...)
 	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, childEnv+"="+name)
+	cmd.Env = append(cmd.Env, childEnv+"="+name, "GORACE=atexit_sleep_ms=0")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
 	return cmd
 }
+
+func TestNotarize_uploadLockHeld(t *testing.T) {
+	var lock sync.Mutex
+	var heldDuringUpload bool
+	uploadFunc = func(ctx context.Context, opts *Options) (string, error) {
+		// TryLock fails only if Notarize is holding the lock for us.
+		heldDuringUpload = !lock.TryLock()
+		if !heldDuringUpload {
+			lock.Unlock()
+		}
+
+		return "", errors.New("upload failed")
+	}
+	defer func() { uploadFunc = upload }()
+
+	file := filepath.Join(t.TempDir(), "app.dmg")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+
+	_, _, err := Notarize(context.Background(), &Options{
+		File:       file,
+		Logger:     hclog.L(),
+		BaseCmd:    childCmd(t, "notarize-accepted"),
+		Intervals:  testIntervals,
+		UploadLock: &lock,
+	})
+
+	req := require.New(t)
+	req.ErrorContains(err, "upload failed")
+	req.True(heldDuringUpload, "lock must be held during upload")
+	req.True(lock.TryLock(), "lock must be released after upload")
+}
+
+func TestNotarize_accepted(t *testing.T) {
+	info, log, err := Notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal(StatusAccepted, info.Status)
+	req.Equal(StatusAccepted, log.Status)
+}
+
+func TestNotarize_skipLog(t *testing.T) {
+	status := &testStatus{}
+	info, log, err := Notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+		Status:    status,
+		SkipLog:   true,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal(StatusAccepted, info.Status)
+	req.Nil(log)
+	req.NotContains(status.Events, "LogStatus")
+}
+
+func TestNotarize_queueCleared(t *testing.T) {
+	status := &testStatus{}
+	_, _, err := Notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+		Status:    status,
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"Submitting", "Submitted", "QueueCleared", "InfoStatus", "LogStatus",
+	}, status.Events)
+}
+
+func TestNotarize_uploadRetry(t *testing.T) {
+	cmd := childCmd(t, "notarize-upload-flaky")
+	cmd.Env = append(cmd.Env, childEnv+"_MARKER="+filepath.Join(t.TempDir(), "marker"))
+
+	var result Result
+	info, _, err := notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   cmd,
+		Intervals: testIntervals,
+	}, &result.Attempts)
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal(StatusAccepted, info.Status)
+	req.Len(result.Attempts, 1)
+	req.Equal(PhaseSubmit, result.Attempts[0].Phase)
+	req.Equal(AttemptRetried, result.Attempts[0].Action)
+	req.EqualValues(codeNetworkUnavailable, result.Attempts[0].Code)
+}
+
+func TestNotarize_uploadRetryDisabled(t *testing.T) {
+	cmd := childCmd(t, "notarize-upload-flaky")
+	cmd.Env = append(cmd.Env, childEnv+"_MARKER="+filepath.Join(t.TempDir(), "marker"))
+
+	_, _, err := Notarize(context.Background(), &Options{
+		Logger:        hclog.L(),
+		BaseCmd:       cmd,
+		Intervals:     testIntervals,
+		UploadRetries: -1,
+	})
+	require.ErrorContains(t, err, "The network connection was lost")
+}
+
+func TestIsTransientUploadError(t *testing.T) {
+	req := require.New(t)
+	req.True(isTransientUploadError(errors.New("Error: The network connection was lost. (-19000)")))
+	req.True(isTransientUploadError(errors.New("read tcp: connection reset by peer")))
+	req.False(isTransientUploadError(errors.New("Error: HTTP status code: 401. Invalid credentials.")))
+	req.False(isTransientUploadError(errors.New("Error: The file is not a valid archive.")))
+}
+
+func TestNotarize_output(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	info, _, err := Notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+		Stdout:    &stdout,
+		Stderr:    &stderr,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal(StatusAccepted, info.Status)
+	req.Contains(stdout.String(), info.RequestUUID)
+	req.Contains(stdout.String(), "<key>status</key>")
+	req.Empty(stderr.String())
+}
+
+func TestNotarize_serverWait(t *testing.T) {
+	// The child waits until the test saw whether the upload lock is held.
+	td := t.TempDir()
+	waiting := filepath.Join(td, "waiting")
+	release := filepath.Join(td, "release")
+	cmd := childCmd(t, "notarize-server-wait")
+	cmd.Env = append(cmd.Env, childEnv+"_WAITING="+waiting, childEnv+"_RELEASE="+release)
+
+	var lock sync.Mutex
+	lockFree := make(chan bool, 1)
+	go func() {
+		for {
+			if _, err := os.Stat(waiting); err == nil {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		free := lock.TryLock()
+		if free {
+			lock.Unlock()
+		}
+		lockFree <- free
+		os.WriteFile(release, nil, 0644)
+	}()
+
+	status := &testStatus{}
+	info, log, err := Notarize(context.Background(), &Options{
+		Logger:     hclog.L(),
+		BaseCmd:    cmd,
+		Intervals:  testIntervals,
+		Status:     status,
+		ServerWait: true,
+		UploadLock: &lock,
+	})
+
+	// Only the info and log are requested after waiting.
+	req := require.New(t)
+	req.NoError(err)
+	req.True(<-lockFree, "upload lock held while waiting")
+	req.Equal(StatusAccepted, info.Status)
+	req.Equal(StatusAccepted, log.Status)
+	req.Equal(2, status.Waits)
+	req.Contains(status.Events, "QueueCleared")
+}
+
+func TestNotarize_rateLimited(t *testing.T) {
+	cmd := childCmd(t, "notarize-rate-limited")
+	cmd.Env = append(cmd.Env, childEnv+"_MARKER="+filepath.Join(t.TempDir(), "marker"))
+
+	status := &testStatus{}
+	result, err := EnsureNotarized(context.Background(), "foo.zip", &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   cmd,
+		Intervals: testIntervals,
+		Status:    status,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal(StatusAccepted, result.Info.Status)
+	req.Contains(status.Events, "RateLimited")
+	req.Len(result.Attempts, 1)
+	req.Equal(PhaseQueue, result.Attempts[0].Phase)
+}
+
+func TestNotarize_structuredLogs(t *testing.T) {
+	cmd := childCmd(t, "notarize-rate-limited")
+	cmd.Env = append(cmd.Env, childEnv+"_MARKER="+filepath.Join(t.TempDir(), "marker"))
+
+	var out bytes.Buffer
+	_, _, err := Notarize(context.Background(), &Options{
+		Logger: hclog.New(&hclog.LoggerOptions{
+			Output:     &out,
+			Level:      hclog.Debug,
+			JSONFormat: true,
+		}),
+		BaseCmd:   cmd,
+		Intervals: testIntervals,
+	})
+	require.NoError(t, err)
+
+	// Find the rate limit warning and check its fields.
+	var entry map[string]interface{}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.Contains(line, "rate limited by Apple") {
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		}
+	}
+
+	req := require.New(t)
+	req.NotNil(entry)
+	req.Equal("cfd69166-8e2f-1397-8636-ec06f98e3597", entry["uuid"])
+	req.Equal("queue", entry["phase"])
+	req.EqualValues(1, entry["attempt"])
+	req.Contains(entry, "wait_duration")
+	req.Contains(out.String(), "queue cleared")
+}
+
+func TestNotarize_networkRetry(t *testing.T) {
+	cmd := childCmd(t, "notarize-log-network")
+	cmd.Env = append(cmd.Env, childEnv+"_MARKER="+filepath.Join(t.TempDir(), "marker"))
+
+	var out bytes.Buffer
+	var attempts []Attempt
+	_, log, err := notarize(context.Background(), &Options{
+		Logger: hclog.New(&hclog.LoggerOptions{
+			Output:     &out,
+			Level:      hclog.Debug,
+			JSONFormat: true,
+		}),
+		BaseCmd:   cmd,
+		Intervals: testIntervals,
+	}, &attempts)
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal(StatusAccepted, log.Status)
+	req.Len(attempts, 1)
+	req.Equal(PhaseLog, attempts[0].Phase)
+	req.Equal(AttemptRetried, attempts[0].Action)
+	req.EqualValues(codeNetworkUnavailable, attempts[0].Code)
+
+	var entry map[string]interface{}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.Contains(line, "network became unavailable") {
+			req.NoError(json.Unmarshal([]byte(line), &entry))
+		}
+	}
+	req.NotNil(entry)
+	req.Equal("log", entry["phase"])
+	req.EqualValues(codeNetworkUnavailable, entry["error_code"])
+}
+
+func TestNotarize_timing(t *testing.T) {
+	before := time.Now()
+	info, _, err := Notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.True(info.SubmittedAt.After(before))
+	req.Positive(info.UploadDuration)
+	req.Positive(info.QueueDuration)
+	req.Positive(info.AnalysisDuration)
+}
+
+func TestNotarize_waiting(t *testing.T) {
+	status := &testStatus{}
+	_, _, err := Notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+		Status:    status,
+	})
+
+	// One request each for the queue, info, and log.
+	require.NoError(t, err)
+	require.Equal(t, 3, status.Waits)
+}
+
+func TestNotarize_queueError(t *testing.T) {
+	info, log, err := Notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-info-error"),
+		Intervals: testIntervals,
+	})
+
+	// The submission is known even though we never got its info.
+	req := require.New(t)
+	req.Error(err)
+	req.NotNil(info)
+	req.Equal("cfd69166-8e2f-1397-8636-ec06f98e3597", info.RequestUUID)
+	req.False(info.SubmittedAt.IsZero())
+	req.Zero(info.QueueDuration)
+	req.Nil(log)
+}
+
+func TestNotarize_noLeakOnError(t *testing.T) {
+	// Count the timers that are still running. Unlike goroutines, these
+	// can't be observed from the outside.
+	var lock sync.Mutex
+	created, running := 0, 0
+	timerFunc = func(d time.Duration) (<-chan time.Time, func() bool) {
+		lock.Lock()
+		defer lock.Unlock()
+		created++
+		running++
+
+		timer := time.NewTimer(d)
+		stopped := false
+		return timer.C, func() bool {
+			lock.Lock()
+			defer lock.Unlock()
+			if !stopped {
+				stopped = true
+				running--
+			}
+			return timer.Stop()
+		}
+	}
+	defer func() { timerFunc = newTimer }()
+
+	_, _, err := Notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-info-error"),
+		Intervals: testIntervals,
+	})
+	require.Error(t, err)
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.NotZero(t, created)
+	require.Zero(t, running)
+}
+
+func TestNotarize_queuedPredicate(t *testing.T) {
+	calls := 0
+	_, _, err := Notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-info-error"),
+		Intervals: testIntervals,
+		QueuedPredicate: func(err error) bool {
+			calls++
+			return calls < 3
+		},
+	})
+
+	require.Error(t, err)
+	require.Equal(t, 3, calls)
+}
+
+func TestNotarize_queued(t *testing.T) {
+	cmd := childCmd(t, "notarize-queued")
+	cmd.Env = append(cmd.Env, childEnv+"_MARKER="+filepath.Join(t.TempDir(), "marker"))
+
+	var attempts []Attempt
+	info, _, err := notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   cmd,
+		Intervals: testIntervals,
+	}, &attempts)
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal(StatusAccepted, info.Status)
+	req.Len(attempts, 1)
+	req.Equal(PhaseQueue, attempts[0].Phase)
+	req.Equal(AttemptRetried, attempts[0].Action)
+}
+
+func TestNotarize_logRequestedImmediately(t *testing.T) {
+	// With long status and log intervals, this only finishes quickly if the
+	// log is requested as soon as the info reaches a terminal state.
+	intervals := testIntervals
+	intervals.StatusPoll = time.Hour
+	intervals.LogPoll = time.Hour
+
+	_, log, err := Notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: intervals,
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, StatusAccepted, log.Status)
+}
+
+func TestNotarize_authExpiredCode(t *testing.T) {
+	// The credentials are rejected with an Apple error code rather than an
+	// HTTP status code.
+	_, _, err := Notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-info-auth-code"),
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	req.ErrorIs(err, ErrAuthExpired)
+	req.ErrorIs(err, Error{Code: codeAuthIncorrect})
+	req.NotContains(err.Error(), "HTTP status code")
+}
+
+func TestNotarize_authExpired(t *testing.T) {
+	_, _, err := Notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-info-auth"),
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	req.ErrorIs(err, ErrAuthExpired)
+
+	var authErr *AuthExpiredError
+	req.True(errors.As(err, &authErr))
+	req.Equal("cfd69166-8e2f-1397-8636-ec06f98e3597", authErr.RequestUUID)
+}
+
+func TestNotarize_reauth(t *testing.T) {
+	calls := 0
+	_, _, err := Notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-info-auth"),
+		Intervals: testIntervals,
+		ReauthFunc: func() error {
+			calls++
+			return nil
+		},
+	})
+
+	// Authentication keeps failing so we only reauthenticate once.
+	require.ErrorIs(t, err, ErrAuthExpired)
+	require.Equal(t, 1, calls)
+}
+
+func TestNotarize_reauthCredentials(t *testing.T) {
+	// The provider is resolved, which copies the options internally.
+	cmd := childCmd(t, "notarize-reauth-password")
+	cmd.Env = append(cmd.Env, childEnv+"_SINGLE=1")
+
+	opts := &Options{
+		DeveloperId: "foo@example.com",
+		Password:    "old",
+		Logger:      hclog.L(),
+		BaseCmd:     cmd,
+		Intervals:   testIntervals,
+	}
+	opts.ReauthFunc = func() error {
+		opts.Password = "new"
+		return nil
+	}
+
+	info, _, err := Notarize(context.Background(), opts)
+	require.NoError(t, err)
+	require.Equal(t, StatusAccepted, info.Status)
+}
+
+func TestNotarize_attempts(t *testing.T) {
+	var attempts []Attempt
+	_, _, err := notarize(context.Background(), &Options{
+		Logger:     hclog.L(),
+		BaseCmd:    childCmd(t, "notarize-info-auth"),
+		Intervals:  testIntervals,
+		ReauthFunc: func() error { return nil },
+	}, &attempts)
+
+	req := require.New(t)
+	req.Error(err)
+	req.Len(attempts, 2)
+	req.Equal(PhaseQueue, attempts[0].Phase)
+	req.Equal(AttemptRetried, attempts[0].Action)
+	req.Contains(attempts[0].Message, "HTTP status code: 401")
+	req.Equal(AttemptAborted, attempts[1].Action)
+	req.False(attempts[1].Time.Before(attempts[0].Time))
+}
+
+func TestNotarize_resubmitAfterQueueTimeout(t *testing.T) {
+	uuids := []string{"stuck", "cfd69166-8e2f-1397-8636-ec06f98e3597"}
+	uploadFunc = func(ctx context.Context, opts *Options) (string, error) {
+		uuid := uuids[0]
+		uuids = uuids[1:]
+		return uuid, nil
+	}
+	defer func() { uploadFunc = upload }()
+
+	var attempts []Attempt
+	info, _, err := notarize(context.Background(), &Options{
+		Logger:                    hclog.L(),
+		BaseCmd:                   childCmd(t, "notarize-resubmit"),
+		Intervals:                 testIntervals,
+		ResubmitAfterQueueTimeout: 5 * time.Millisecond,
+	}, &attempts)
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal(StatusAccepted, info.Status)
+	req.Empty(uuids)
+
+	last := attempts[len(attempts)-1]
+	req.Equal(AttemptResubmitted, last.Action)
+	req.Equal("stuck", last.RequestUUID)
+}
+
+func TestNotarize_workDirMissing(t *testing.T) {
+	_, _, err := Notarize(context.Background(), &Options{
+		File:    "foo.zip",
+		WorkDir: filepath.Join(t.TempDir(), "missing"),
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "notarize-accepted"),
+	})
+
+	require.Error(t, err)
+}
+
+func TestNotarize_preUpload(t *testing.T) {
+	var uploaded string
+	uploadFunc = func(ctx context.Context, opts *Options) (string, error) {
+		uploaded = opts.File
+		return upload(ctx, opts)
+	}
+	defer func() { uploadFunc = upload }()
+
+	opts := &Options{
+		File:      "foo.zip",
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+		PreUpload: func(ctx context.Context, path string) (string, error) {
+			return "transformed-" + path, nil
+		},
+	}
+	_, _, err := Notarize(context.Background(), opts)
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal("transformed-foo.zip", uploaded)
+	req.Equal("foo.zip", opts.File)
+}
+
+func TestNotarize_preUploadError(t *testing.T) {
+	_, _, err := Notarize(context.Background(), &Options{
+		File:    "foo.zip",
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "notarize-accepted"),
+		PreUpload: func(ctx context.Context, path string) (string, error) {
+			return "", errors.New("boom")
+		},
+	})
+
+	require.ErrorContains(t, err, "boom")
+}
+
+func TestNotarize_unsigned(t *testing.T) {
+	_, _, err := Notarize(context.Background(), &Options{
+		File:      "foo.pkg",
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-unsigned"),
+		Intervals: testIntervals,
+	})
+	require.ErrorIs(t, err, ErrUnsignedArtifact)
+
+	// Opting out submits the file anyways.
+	_, _, err = Notarize(context.Background(), &Options{
+		File:               "foo.pkg",
+		Logger:             hclog.L(),
+		BaseCmd:            childCmd(t, "notarize-unsigned"),
+		Intervals:          testIntervals,
+		SkipSignatureCheck: true,
+	})
+	require.NoError(t, err)
+}
+
+func TestNotarize_unsignedDMG(t *testing.T) {
+	// Apple notarizes disk images whose contents are signed, so an unsigned
+	// dmg is only warned about.
+	info, _, err := Notarize(context.Background(), &Options{
+		File:      "foo.dmg",
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-unsigned"),
+		Intervals: testIntervals,
+	})
+	require.NoError(t, err)
+	require.Equal(t, StatusAccepted, info.Status)
+}
+
+func TestNotarize_signatureToolError(t *testing.T) {
+	// Failing to check the signature isn't reported as a missing signature,
+	// even for disk images that are allowed to be unsigned.
+	for _, file := range []string{"foo.pkg", "foo.dmg"} {
+		_, _, err := Notarize(context.Background(), &Options{
+			File:      file,
+			Logger:    hclog.L(),
+			BaseCmd:   childCmd(t, "notarize-signature-error"),
+			Intervals: testIntervals,
+		})
+		require.Error(t, err, file)
+		require.NotErrorIs(t, err, ErrUnsignedArtifact, file)
+		require.ErrorContains(t, err, "No such file or directory", file)
+	}
+}
+
+func TestNotarize_maxTotalDuration(t *testing.T) {
+	info, log, err := Notarize(context.Background(), &Options{
+		Logger:           hclog.L(),
+		BaseCmd:          childCmd(t, "notarize-info-error"),
+		Intervals:        testIntervals,
+		QueuedPredicate:  func(error) bool { return true },
+		MaxTotalDuration: 100 * time.Millisecond,
+	})
+
+	req := require.New(t)
+	req.ErrorIs(err, ErrTotalTimeout)
+	req.NotErrorIs(err, ErrInterrupted)
+	req.Equal("cfd69166-8e2f-1397-8636-ec06f98e3597", info.RequestUUID)
+	req.Nil(log)
+}
+
+func TestNotarize_tempDirUnwritable(t *testing.T) {
+	td := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(td, "Foo.app", "Contents"), 0755))
+
+	_, _, err := Notarize(context.Background(), &Options{
+		File:               filepath.Join(td, "Foo.app"),
+		Logger:             hclog.L(),
+		BaseCmd:            childCmd(t, "notarize-accepted"),
+		Intervals:          testIntervals,
+		SkipSignatureCheck: true,
+		TempDir:            filepath.Join(td, "missing"),
+	})
+	require.ErrorContains(t, err, "isn't writable")
+}
+
+func TestNotarize_tempDirRelative(t *testing.T) {
+	td := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(td, "Foo.app", "Contents"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(td, "Foo.app", "Contents", "Info.plist"), nil, 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(td, "tmp"), 0755))
+
+	// The temp directory is relative to WorkDir like File.
+	var uploaded string
+	_, _, err := Notarize(context.Background(), &Options{
+		File:               "Foo.app",
+		WorkDir:            td,
+		Logger:             hclog.L(),
+		BaseCmd:            childCmd(t, "notarize-accepted"),
+		Intervals:          testIntervals,
+		SkipSignatureCheck: true,
+		ZipTool:            ZipToolZip,
+		TempDir:            "tmp",
+		PreUpload: func(_ context.Context, path string) (string, error) {
+			uploaded = path
+			return path, nil
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(uploaded, filepath.Join(td, "tmp")), uploaded)
+}
+
+func TestNotarize_tempFilesRemoved(t *testing.T) {
+	cases := []struct {
+		name   string
+		child  string
+		cancel bool
+	}{
+		{"accepted", "notarize-accepted", false},
+		{"error", "notarize-info-error", false},
+		{"cancelled", "notarize-in-progress", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Bundles are zipped into the OS temp directory by default, which
+			// we point at an empty directory so that we can check it after.
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+
+			src := t.TempDir()
+			bundle := filepath.Join(src, "Foo.app")
+			require.NoError(t, os.MkdirAll(filepath.Join(bundle, "Contents"), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(bundle, "Contents", "Info.plist"), nil, 0644))
+
+			// We cancel once polling started, after the file was uploaded,
+			// rather than after a timeout that races the child processes.
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var status Status = &testStatus{}
+			if tc.cancel {
+				status = &testOnInfoStatus{status: StatusInProgress, fn: cancel}
+			}
+
+			var uploaded string
+			_, _, err := Notarize(ctx, &Options{
+				File:               bundle,
+				Logger:             hclog.L(),
+				BaseCmd:            childCmd(t, tc.child),
+				Intervals:          testIntervals,
+				SkipSignatureCheck: true,
+				ZipTool:            ZipToolZip,
+				Status:             status,
+				PreUpload: func(_ context.Context, path string) (string, error) {
+					uploaded = path
+					return path, nil
+				},
+			})
+			if tc.cancel {
+				require.ErrorIs(t, err, context.Canceled)
+			}
+
+			entries, err := os.ReadDir(tmp)
+			require.NoError(t, err)
+			require.Empty(t, entries)
+			require.True(t, strings.HasPrefix(uploaded, tmp), uploaded)
+
+			// The caller's file is never removed.
+			require.DirExists(t, bundle)
+		})
+	}
+}
+
+func TestNotarize_maxPollAttempts(t *testing.T) {
+	info, _, err := Notarize(context.Background(), &Options{
+		Logger:          hclog.L(),
+		BaseCmd:         childCmd(t, "notarize-in-progress"),
+		Intervals:       testIntervals,
+		MaxPollAttempts: 3,
+	})
+
+	req := require.New(t)
+	req.ErrorIs(err, ErrMaxPollAttempts)
+	req.Equal(StatusInProgress, info.Status)
+}
+
+func TestNotarize_interruptedPolling(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The poll interval is far longer than the test, so this only returns
+	// if waiting is interrupted. We cancel once polling started rather than
+	// after a timeout so that slow child processes can't race it.
+	intervals := testIntervals
+	intervals.StatusPoll = time.Hour
+
+	info, _, err := Notarize(ctx, &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-in-progress"),
+		Intervals: intervals,
+		Status:    &testOnInfoStatus{status: StatusInProgress, fn: cancel},
+	})
+
+	req := require.New(t)
+	req.ErrorIs(err, ErrInterrupted)
+	req.ErrorIs(err, context.Canceled)
+	req.Equal(StatusInProgress, info.Status)
+}
+
+func TestNotarize_unknownStatus(t *testing.T) {
+	info, _, err := Notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-unknown-status"),
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	req.ErrorIs(err, ErrUnknownStatus)
+	req.Equal(SubmissionStatus("Needs Review"), info.Status)
+}
+
+func TestNotarize_extraTerminalStatuses(t *testing.T) {
+	status := &testStatus{}
+	info, log, err := Notarize(context.Background(), &Options{
+		Logger:                hclog.L(),
+		BaseCmd:               childCmd(t, "notarize-unknown-status"),
+		Intervals:             testIntervals,
+		Status:                status,
+		ExtraTerminalStatuses: []SubmissionStatus{"Needs Review"},
+	})
+
+	// Polling stops right away, but we can't tell if the file was accepted.
+	req := require.New(t)
+	req.ErrorContains(err, "unexpected status")
+	req.NotErrorIs(err, ErrUnknownStatus)
+	req.Equal(SubmissionStatus("Needs Review"), info.Status)
+	req.Equal(StatusAccepted, log.Status)
+	req.Equal(3, status.Waits)
+}
+
+func TestNotarizeWithDeadline(t *testing.T) {
+	// The upload doesn't run a child so that only the first poll has to
+	// finish within the deadline. Polling then blocks until the deadline,
+	// so the test doesn't depend on how long the deadline is beyond that.
+	var uploadCtx context.Context
+	uploadFunc = func(ctx context.Context, opts *Options) (string, error) {
+		uploadCtx = ctx
+		return "cfd69166-8e2f-1397-8636-ec06f98e3597", nil
+	}
+	defer func() { uploadFunc = upload }()
+
+	info, log, err := NotarizeWithDeadline(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-in-progress"),
+		Intervals: testIntervals,
+		Status: &testOnInfoStatus{
+			status: StatusInProgress,
+			fn:     func() { <-uploadCtx.Done() },
+		},
+	}, time.Second)
+
+	req := require.New(t)
+	req.ErrorIs(err, context.DeadlineExceeded)
+	req.ErrorIs(err, ErrInterrupted)
+	req.Equal("cfd69166-8e2f-1397-8636-ec06f98e3597", info.RequestUUID)
+	req.Equal(StatusInProgress, info.Status)
+	req.Nil(log)
+}
+
+func TestNotarize_interrupted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	info, _, err := Notarize(ctx, &Options{
+		Logger:          hclog.L(),
+		BaseCmd:         childCmd(t, "notarize-info-error"),
+		Intervals:       testIntervals,
+		QueuedPredicate: func(error) bool { return true },
+	})
+
+	req := require.New(t)
+	req.ErrorIs(err, ErrInterrupted)
+	req.ErrorIs(err, context.DeadlineExceeded)
+	req.Equal("cfd69166-8e2f-1397-8636-ec06f98e3597", info.RequestUUID)
+
+	var ierr *InterruptedError
+	req.True(errors.As(err, &ierr))
+	req.Equal(info.RequestUUID, ierr.RequestUUID)
+	req.Equal(info.RequestUUID, ierr.Token.RequestUUID)
+	req.Contains(err.Error(), "xcrun notarytool info "+info.RequestUUID)
+}
+
+func TestNotarize_uploadFailedAfterSubmission(t *testing.T) {
+	info, _, err := Notarize(context.Background(), &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-upload-partial"),
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	req.ErrorContains(err, "error submitting for notarization")
+	req.ErrorContains(err, "xcrun notarytool info cfd69166-8e2f-1397-8636-ec06f98e3597")
+	req.NotErrorIs(err, ErrInterrupted)
+	req.Equal("cfd69166-8e2f-1397-8636-ec06f98e3597", info.RequestUUID)
+}
+
+func TestNotarize_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Failing before the submission was created.
+	info, _, err := Notarize(ctx, &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "upload-exit-status"),
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	req.ErrorIs(err, context.Canceled)
+	req.NotErrorIs(err, ErrInterrupted)
+	req.Nil(info)
+
+	// Failing after the submission was created. The commands are killed
+	// once ctx is cancelled, so we cancel it once the upload returned.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	uploadFunc = func(ctx context.Context, opts *Options) (string, error) {
+		defer cancel()
+		return upload(ctx, opts)
+	}
+	defer func() { uploadFunc = upload }()
+
+	info, _, err = Notarize(ctx, &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-upload-partial"),
+		Intervals: testIntervals,
+	})
+	req.ErrorIs(err, context.Canceled)
+	req.ErrorIs(err, ErrInterrupted)
+	req.Equal("cfd69166-8e2f-1397-8636-ec06f98e3597", info.RequestUUID)
+}
+
+// testStatus implements Status and records the name of each callback.
+// Waiting is only counted since it is called on every request.
+type testStatus struct {
+	Events []string
+	Waits  int
+}
+
+func (s *testStatus) Submitting()                        { s.record("Submitting") }
+func (s *testStatus) Submitted(string)                   { s.record("Submitted") }
+func (s *testStatus) QueueCleared(string, time.Duration) { s.record("QueueCleared") }
+func (s *testStatus) InfoStatus(Info)                    { s.record("InfoStatus") }
+func (s *testStatus) LogStatus(Log)                      { s.record("LogStatus") }
+func (s *testStatus) Waiting(time.Duration, int)         { s.Waits++ }
+func (s *testStatus) RateLimited(string, time.Duration)  { s.record("RateLimited") }
+func (s *testStatus) LargeUpload(int64)                  { s.record("LargeUpload") }
+
+func (s *testStatus) record(event string) {
+	s.Events = append(s.Events, event)
+}
+
+// testOnInfoStatus is a testStatus that calls fn the first time the info
+// reports status.
+type testOnInfoStatus struct {
+	testStatus
+
+	status SubmissionStatus
+	fn     func()
+	called bool
+}
+
+func (s *testOnInfoStatus) InfoStatus(info Info) {
+	s.testStatus.InfoStatus(info)
+	if info.Status == s.status && !s.called {
+		s.called = true
+		s.fn()
+	}
+}
+
+// testIntervals are short intervals so tests don't wait on polling.
+var testIntervals = Intervals{
+	QueuePoll:      time.Millisecond,
+	StatusPoll:     time.Millisecond,
+	NetworkRetry:   time.Millisecond,
+	LogPoll:        time.Millisecond,
+	RateLimitRetry: time.Millisecond,
+}
+
+// testCmdNotarizeAccepted mimicks every notarytool subcommand used by
+// Notarize for an accepted submission. info and log set their own
+// arguments, anything else is the upload.
+func testCmdNotarizeAccepted() int {
+	if len(os.Args) > 2 {
+		switch os.Args[2] {
+		case "info":
+			return testCmdInfoAcceptedSubmission()
+		case "log":
+			return testCmdLogValidSubmission()
+		}
+	}
+
+	return testCmdUploadSuccess()
+}
+
+// testCmdNotarizeInfoError mimicks a successful upload followed by info
+// requests that fail with an error that isn't retried.
+func testCmdNotarizeInfoError() int {
+	if len(os.Args) > 2 && os.Args[2] == "info" {
+		return 1
+	}
+
+	return testCmdUploadSuccess()
+}
+
+// testCmdNotarizeServerWait mimicks an accepted submission that is waited
+// on with `notarytool wait`.
+func testCmdNotarizeServerWait() int {
+	if len(os.Args) > 2 && os.Args[2] == "wait" {
+		// Wait for the test to check the upload lock, which it does once
+		// the waiting file exists.
+		os.WriteFile(os.Getenv(childEnv+"_WAITING"), nil, 0644)
+		for i := 0; i < 10000; i++ {
+			if _, err := os.Stat(os.Getenv(childEnv + "_RELEASE")); err == nil {
+				fmt.Println(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>id</key>
+	<string>` + os.Args[3] + `</string>
+	<key>status</key>
+	<string>Accepted</string>
+</dict>
+</plist>`))
+				return 0
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		fmt.Fprintln(os.Stderr, "not released")
+		return 1
+	}
+
+	return testCmdNotarizeAccepted()
+}
+
+// testCmdNotarizeRateLimited mimicks an accepted submission whose first
+// info request is rate limited, which is tracked with a marker file.
+func testCmdNotarizeRateLimited() int {
+	marker := os.Getenv(childEnv + "_MARKER")
+	if len(os.Args) > 2 && os.Args[2] == "info" {
+		if _, err := os.Stat(marker); err != nil {
+			os.WriteFile(marker, nil, 0644)
+			fmt.Fprintln(os.Stderr, "Error: HTTP status code: 429. Too Many Requests.")
+			return 1
+		}
+	}
+
+	return testCmdNotarizeAccepted()
+}
+
+// testCmdNotarizeLogNetwork mimicks an accepted submission whose first
+// log request fails because the network was lost, which is tracked with a
+// marker file.
+func testCmdNotarizeLogNetwork() int {
+	marker := os.Getenv(childEnv + "_MARKER")
+	if len(os.Args) > 2 && os.Args[2] == "log" {
+		if _, err := os.Stat(marker); err != nil {
+			os.WriteFile(marker, nil, 0644)
+			fmt.Fprintln(os.Stderr, "Error: The network connection was lost. (-19000)")
+			return 1
+		}
+	}
+
+	return testCmdNotarizeAccepted()
+}
+
+// testCmdNotarizeQueued mimicks an accepted submission that is still in
+// Apple's queue for the first info request, which is tracked with a
+// marker file.
+func testCmdNotarizeQueued() int {
+	marker := os.Getenv(childEnv + "_MARKER")
+	if len(os.Args) > 2 && os.Args[2] == "info" {
+		if _, err := os.Stat(marker); err != nil {
+			os.WriteFile(marker, nil, 0644)
+			fmt.Fprintln(os.Stderr, "Error: Submission not found (1519)")
+			return 1
+		}
+	}
+
+	return testCmdNotarizeAccepted()
+}
+
+// testCmdNotarizeUploadPartial mimicks an upload that fails after the
+// submission was created.
+func testCmdNotarizeUploadPartial() int {
+	testCmdUploadSuccess()
+	return 1
+}
+
+// testCmdNotarizeUploadFlaky mimicks an upload that fails due to the
+// network the first time, which is tracked with a marker file, and an
+// accepted submission afterwards.
+func testCmdNotarizeUploadFlaky() int {
+	marker := os.Getenv(childEnv + "_MARKER")
+	if len(os.Args) > 2 && os.Args[2] == "submit" {
+		if _, err := os.Stat(marker); err != nil {
+			os.WriteFile(marker, nil, 0644)
+			fmt.Fprintln(os.Stderr, "Error: The network connection was lost. (-19000)")
+			return 1
+		}
+	}
+
+	return testCmdNotarizeAccepted()
+}
+
+// testCmdNotarizeInProgress mimicks a successful upload followed by a
+// submission that is never done processing.
+func testCmdNotarizeInProgress() int {
+	if len(os.Args) > 2 && os.Args[2] == "info" {
+		data, err := os.ReadFile(filepath.Join("testdata", "info", "in_progress.plist"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		os.Stdout.Write(data)
+		return 0
+	}
+
+	return testCmdUploadSuccess()
+}
+
+// testCmdNotarizeUnknownStatus mimicks a submission whose info always
+// reports a status that isn't known, followed by an accepted log.
+func testCmdNotarizeUnknownStatus() int {
+	if len(os.Args) > 2 && os.Args[2] == "info" {
+		data, err := os.ReadFile(filepath.Join("testdata", "info", "in_progress.plist"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		os.Stdout.Write(bytes.ReplaceAll(data, []byte("In Progress"), []byte("Needs Review")))
+		return 0
+	}
+
+	return testCmdNotarizeAccepted()
+}
+
+// testCmdNotarizeInfoAuthCode mimicks a successful upload followed by
+// info requests that fail with the Apple error code for bad credentials.
+func testCmdNotarizeInfoAuthCode() int {
+	if len(os.Args) > 2 && os.Args[2] == "info" {
+		fmt.Fprintln(os.Stderr, "Error: Your Apple ID or password was entered incorrectly. (-20101)")
+		return 1
+	}
+
+	return testCmdUploadSuccess()
+}
+
+// testCmdNotarizeInfoAuth mimicks a successful upload followed by info
+// requests that fail because the credentials are no longer valid.
+func testCmdNotarizeInfoAuth() int {
+	if len(os.Args) > 2 && os.Args[2] == "info" {
+		fmt.Fprintln(os.Stderr, "Error: HTTP status code: 401. Invalid credentials. "+
+			"Username or password is incorrect.")
+		return 1
+	}
+
+	return testCmdUploadSuccess()
+}
+
+// testCmdNotarizeReauthPassword mimicks an account with a single provider
+// whose info requests only succeed with the password "new".
+func testCmdNotarizeReauthPassword() int {
+	if len(os.Args) > 1 && os.Args[1] == "altool" {
+		return testCmdProviders()
+	}
+
+	if len(os.Args) > 2 && os.Args[2] != "submit" {
+		args := strings.Join(os.Args, " ")
+		if !strings.Contains(args, "--team-id TEAM1") {
+			fmt.Fprintln(os.Stderr, "Error: missing provider")
+			return 1
+		}
+		if !strings.Contains(args, "--password new") {
+			return testCmdNotarizeInfoAuth()
+		}
+	}
+
+	return testCmdNotarizeAccepted()
+}
+
+// testCmdNotarizeResubmit mimicks a submission with the UUID "stuck" that
+// never leaves the queue, while any other submission is accepted.
+func testCmdNotarizeResubmit() int {
+	if len(os.Args) > 3 && os.Args[2] == "info" && os.Args[3] == "stuck" {
+		fmt.Fprintln(os.Stderr, "Error: Submission not found (1519)")
+		return 1
+	}
+
+	return testCmdNotarizeAccepted()
+}
+
+// testCmdNotarizeSignatureError mimicks codesign and pkgutil failing
+// because the file doesn't exist.
+func testCmdNotarizeSignatureError() int {
+	if len(os.Args) > 1 && (os.Args[1] == "codesign" || os.Args[1] == "pkgutil") {
+		fmt.Fprintln(os.Stderr, os.Args[len(os.Args)-1]+": No such file or directory")
+		return 1
+	}
+
+	return testCmdNotarizeAccepted()
+}
+
+// testCmdNotarizeUnsigned mimicks a file that isn't signed but is
+// accepted if it is submitted anyways.
+func testCmdNotarizeUnsigned() int {
+	if len(os.Args) > 1 && os.Args[1] == "codesign" {
+		fmt.Fprintln(os.Stderr, os.Args[len(os.Args)-1]+": code object is not signed at all")
+		return 1
+	}
+	if len(os.Args) > 1 && os.Args[1] == "pkgutil" {
+		fmt.Println("Package \"" + filepath.Base(os.Args[len(os.Args)-1]) + "\":")
+		fmt.Println("   Status: no signature")
+		return 1
+	}
+
+	return testCmdNotarizeAccepted()
+}
@@ -0,0 +1,87 @@
+package notarize
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sync"
+)
+
+// notarytoolCmd returns the command that runs notarytool with args. This
+// is BaseCmd if it is set, otherwise the command returned by
+// NotarytoolLocator, and `xcrun notarytool` by default. The command is
+// bound to ctx, so it is killed once ctx is done. It is returned as a
+// pointer since a copy of it can't be killed.
+func notarytoolCmd(ctx context.Context, opts *Options, args ...string) (*exec.Cmd, error) {
+	// BaseCmd is our test mechanism, which replaces the arguments
+	// including argv[0]. We recreate it so that it is bound to ctx too.
+	if opts.BaseCmd != nil && opts.BaseCmd.Path != "" {
+		base := opts.BaseCmd
+		cmd := exec.CommandContext(ctx, base.Path)
+		cmd.Args = append([]string{filepath.Base(base.Path), "notarytool"}, args...)
+		cmd.Env = base.Env
+		cmd.Dir = base.Dir
+		cmd.Stdin = base.Stdin
+		cmd.Stdout = base.Stdout
+		cmd.Stderr = base.Stderr
+		return cmd, nil
+	}
+
+	if opts.NotarytoolLocator != nil {
+		located, err := opts.NotarytoolLocator()
+		if err != nil {
+			return nil, err
+		}
+
+		// We recreate the command so that it is bound to ctx, keeping the
+		// arguments of the located command before ours.
+		var prefix []string
+		if len(located.Args) > 1 {
+			prefix = append(prefix, located.Args[1:]...)
+		}
+
+		cmd := exec.CommandContext(ctx, located.Path, append(prefix, args...)...)
+		cmd.Env = located.Env
+		cmd.Dir = located.Dir
+		return cmd, nil
+	}
+
+	path, err := exec.LookPath("xcrun")
+	if err != nil {
+		return nil, err
+	}
+
+	return exec.CommandContext(ctx, path, append([]string{"notarytool"}, args...)...), nil
+}
+
+// teeOutput sets the stdout and stderr of cmd to the given writers, and
+// also copies them to the Stdout and Stderr of opts if those are set.
+// stdout and stderr are copied from separate goroutines, so the writes to
+// them are serialized since they usually share a buffer for the combined
+// output.
+func teeOutput(cmd *exec.Cmd, opts *Options, stdout, stderr io.Writer) {
+	if opts.Stdout != nil {
+		stdout = io.MultiWriter(stdout, opts.Stdout)
+	}
+	if opts.Stderr != nil {
+		stderr = io.MultiWriter(stderr, opts.Stderr)
+	}
+
+	var lock sync.Mutex
+	cmd.Stdout = &lockedWriter{lock: &lock, w: stdout}
+	cmd.Stderr = &lockedWriter{lock: &lock, w: stderr}
+}
+
+// lockedWriter is a writer that holds lock while writing to w.
+type lockedWriter struct {
+	lock *sync.Mutex
+	w    io.Writer
+}
+
+// Write implements io.Writer
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.w.Write(p)
+}
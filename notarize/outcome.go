@@ -0,0 +1,81 @@
+package notarize
+
+import (
+	"errors"
+	"fmt"
+)
+
+// outcome is the final outcome of a notarization.
+type outcome int
+
+const (
+	// outcomeUnknown is returned if the info or log has a status other
+	// than Accepted or Invalid, which should never happen once polling
+	// completed.
+	outcomeUnknown outcome = iota
+
+	// outcomeAccepted means the file was notarized.
+	outcomeAccepted
+
+	// outcomeInvalid means Apple rejected the file.
+	outcomeInvalid
+
+	// outcomeInconsistent means the info and log disagree on the status.
+	outcomeInconsistent
+)
+
+// determineOutcome decides the final outcome of a notarization from the
+// status reported by info and log:
+//
+//	info      log       outcome       error
+//	Accepted  Accepted  accepted      nil
+//	Invalid   Invalid   invalid       package is invalid
+//	Accepted  Invalid   inconsistent  status is inconsistent
+//	Invalid   Accepted  inconsistent  status is inconsistent
+//	Rejected  any       invalid       package is invalid
+//	any       Rejected  invalid       package is invalid
+//
+// The error of an invalid outcome is an *InvalidError.
+// An inconsistent status is an error since we can't tell whether a ticket
+// was issued. Any other status, including a nil log, is an error too.
+func determineOutcome(info *Info, log *Log) (outcome, error) {
+	if info == nil || log == nil {
+		return outcomeUnknown, fmt.Errorf("notarization finished without a status")
+	}
+
+	if info.Status == StatusRejected || log.Status == StatusRejected {
+		return outcomeInvalid, &InvalidError{
+			Info: info,
+			Log:  log,
+			Err:  errors.New("submission was rejected"),
+		}
+	}
+
+	known := func(status SubmissionStatus) bool {
+		return status == StatusAccepted || status == StatusInvalid
+	}
+	if !known(info.Status) || !known(log.Status) {
+		return outcomeUnknown, fmt.Errorf(
+			"notarization finished with unexpected status: info reports %q, log reports %q",
+			info.Status, log.Status)
+	}
+
+	switch {
+	case info.Status == StatusAccepted && log.Status == StatusAccepted:
+		return outcomeAccepted, nil
+
+	case info.Status == StatusInvalid && log.Status == StatusInvalid:
+		// Classify modified-after-signing rejections separately since the
+		// generic message doesn't tell the user how to fix it.
+		if mismatch := hashMismatch(log); mismatch != nil {
+			return outcomeInvalid, &InvalidError{Info: info, Log: log, Err: mismatch}
+		}
+
+		return outcomeInvalid, &InvalidError{Info: info, Log: log}
+
+	default:
+		return outcomeInconsistent, fmt.Errorf(
+			"notarization status is inconsistent: info reports %q, log reports %q",
+			info.Status, log.Status)
+	}
+}
@@ -0,0 +1,68 @@
+package notarize
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetermineOutcome(t *testing.T) {
+	cases := []struct {
+		Name    string
+		Info    SubmissionStatus
+		Log     SubmissionStatus
+		Outcome outcome
+		Err     bool
+	}{
+		{"accepted", "Accepted", "Accepted", outcomeAccepted, false},
+		{"invalid", "Invalid", "Invalid", outcomeInvalid, true},
+		{"info accepted log invalid", "Accepted", "Invalid", outcomeInconsistent, true},
+		{"info invalid log accepted", "Invalid", "Accepted", outcomeInconsistent, true},
+		{"rejected", "Rejected", "In Progress", outcomeInvalid, true},
+		{"unknown", "Accepted", "Pending", outcomeUnknown, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			result, err := determineOutcome(&Info{Status: tc.Info}, &Log{Status: tc.Log})
+			require.Equal(t, tc.Outcome, result)
+			if tc.Err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDetermineOutcome_hashMismatch(t *testing.T) {
+	_, err := determineOutcome(&Info{Status: "Invalid"}, &Log{
+		Status: "Invalid",
+		Issues: []LogIssue{
+			{Path: "gon.zip/foo", Message: "The signature of the binary is invalid."},
+		},
+	})
+	require.ErrorIs(t, err, ErrHashMismatch)
+	require.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestDetermineOutcome_invalidError(t *testing.T) {
+	info := &Info{Status: "Invalid"}
+	log := &Log{Status: "Invalid"}
+	_, err := determineOutcome(info, log)
+
+	var ierr *InvalidError
+	req := require.New(t)
+	req.ErrorIs(err, ErrInvalid)
+	req.True(errors.As(err, &ierr))
+	req.Same(info, ierr.Info)
+	req.Same(log, ierr.Log)
+	req.EqualError(err, "package is invalid")
+}
+
+func TestDetermineOutcome_nilLog(t *testing.T) {
+	result, err := determineOutcome(&Info{Status: "Accepted"}, nil)
+	require.Equal(t, outcomeUnknown, result)
+	require.Error(t, err)
+}
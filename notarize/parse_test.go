@@ -0,0 +1,91 @@
+package notarize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/sebdah/goldie"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	goldie.FixtureDir = "testdata"
+	spew.Config.DisablePointerAddresses = true
+}
+
+// TestParseInfo parses the recorded `notarytool info` output fixtures in
+// testdata/info. Add a fixture and run with -update to cover new output.
+func TestParseInfo(t *testing.T) {
+	testParseFixtures(t, "info", func(data []byte) (interface{}, error) {
+		result, err := parseInfo(data)
+		return (*dumpInfo)(result), err
+	})
+}
+
+// TestParseLog parses the recorded `notarytool log` output fixtures in
+// testdata/log. Add a fixture and run with -update to cover new output.
+func TestParseLog(t *testing.T) {
+	testParseFixtures(t, "log", func(data []byte) (interface{}, error) {
+		result, err := parseLog(data, 0)
+		return (*dumpLog)(result), err
+	})
+}
+
+// dumpInfo and dumpLog don't have the String methods of Info and Log, so
+// that spew dumps all of their fields.
+type (
+	dumpInfo Info
+	dumpLog  Log
+)
+
+func TestParseLog_maxIssues(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "log", "invalid.json"))
+	require.NoError(t, err)
+
+	req := require.New(t)
+	result, err := parseLog(data, 1)
+	req.NoError(err)
+	req.Len(result.Issues, 1)
+	req.True(result.IssuesTruncated)
+
+	result, err = parseLog(data, -1)
+	req.NoError(err)
+	req.Len(result.Issues, 2)
+	req.False(result.IssuesTruncated)
+}
+
+func TestParseInfo_invalid(t *testing.T) {
+	_, err := parseInfo([]byte("Error: not a plist"))
+	require.Error(t, err)
+}
+
+func TestParseLog_invalid(t *testing.T) {
+	_, err := parseLog([]byte("Error: not json"), 0)
+	require.Error(t, err)
+
+	_, err = parseLog([]byte(`{"issues": {}}`), 0)
+	require.Error(t, err)
+}
+
+// testParseFixtures parses each fixture in testdata/dir and compares the
+// result to its golden file.
+func testParseFixtures(t *testing.T, dir string, parse func([]byte) (interface{}, error)) {
+	fis, err := os.ReadDir(filepath.Join("testdata", dir))
+	require.NoError(t, err)
+	for _, fi := range fis {
+		if fi.IsDir() || filepath.Ext(fi.Name()) == ".golden" {
+			continue
+		}
+
+		t.Run(fi.Name(), func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", dir, fi.Name()))
+			require.NoError(t, err)
+
+			result, err := parse(data)
+			require.NoError(t, err)
+			goldie.Assert(t, filepath.Join(dir, fi.Name()), []byte(spew.Sdump(result)))
+		})
+	}
+}
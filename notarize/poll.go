@@ -0,0 +1,414 @@
+package notarize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// poller polls the state of a single submission. It holds the state that
+// is shared between the phases of waiting for notarization to complete.
+type poller struct {
+	opts      *Options
+	uuid      string
+	logger    hclog.Logger
+	status    Status
+	intervals Intervals
+
+	// queueTimeout, if non-zero, is how long waitQueue waits before it
+	// returns errQueueStuck.
+	queueTimeout time.Duration
+
+	// attempts, if non-nil, records the requests that failed.
+	attempts *[]Attempt
+
+	// reauthed is true if we reauthenticated and haven't had a successful
+	// request since. This limits reauthentication to once per failure.
+	reauthed bool
+
+	// rateLimitWait is how long we waited after the last request that was
+	// rate limited, which is reset by a successful request.
+	rateLimitWait time.Duration
+
+	// unknownPolls is the number of consecutive requests that reported an
+	// unknown status, which is limited by maxUnknownStatusPolls.
+	unknownPolls int
+
+	// polls is the number of requests made, which is limited by
+	// Options.MaxPollAttempts.
+	polls int
+
+	// started is when the first request was made.
+	started time.Time
+
+	// submitted, uploaded, queueCleared, and analyzed are the times of the
+	// phase transitions that we observed, which are zero for the phases
+	// that weren't. See setTiming.
+	submitted    time.Time
+	uploaded     time.Duration
+	queueCleared time.Time
+	analyzed     time.Time
+}
+
+// setTiming sets the timing fields of info from the phase transitions we
+// observed. Nothing is set unless we submitted the file ourselves.
+func (p *poller) setTiming(info *Info) {
+	if info == nil || p.submitted.IsZero() {
+		return
+	}
+
+	info.SubmittedAt = p.submitted
+	info.UploadDuration = p.uploaded
+	if !p.queueCleared.IsZero() {
+		info.QueueDuration = p.queueCleared.Sub(p.submitted)
+
+		if !p.analyzed.IsZero() {
+			info.AnalysisDuration = p.analyzed.Sub(p.queueCleared)
+		}
+	}
+}
+
+// poll counts a request that is about to be made and returns
+// ErrMaxPollAttempts if that exceeds Options.MaxPollAttempts. Otherwise,
+// the status is notified that we're still waiting.
+func (p *poller) poll() error {
+	if p.started.IsZero() {
+		p.started = time.Now()
+	}
+
+	p.polls++
+	if max := p.opts.MaxPollAttempts; max > 0 && p.polls > max {
+		p.logger.Warn("maximum poll attempts exceeded", "uuid", p.uuid, "max", max)
+		return ErrMaxPollAttempts
+	}
+
+	p.status.Waiting(time.Since(p.started), p.polls)
+	return nil
+}
+
+// waitQueue blocks until the submission has left Apple's queue and its
+// info can be requested.
+func (p *poller) waitQueue(ctx context.Context) error {
+	queued := p.opts.QueuedPredicate
+	if queued == nil {
+		queued = isQueuedError
+	}
+
+	// Apple's queue can be hours long, so we back off while we're queued
+	// rather than polling at a fixed interval.
+	start := time.Now()
+	interval := p.intervals.QueuePoll
+	for {
+		if err := sleep(ctx, interval); err != nil {
+			return err
+		}
+
+		if err := p.poll(); err != nil {
+			return err
+		}
+
+		_, err := info(ctx, p.uuid, p.opts)
+		if err == nil {
+			p.reauthed = false
+			p.rateLimitWait = 0
+			p.logger.Debug("submission left the queue", "uuid", p.uuid, "attempt", p.polls)
+			return nil
+		}
+		if ctx.Err() != nil {
+			recordAttempt(p.attempts, p.uuid, PhaseQueue, context.Cause(ctx), AttemptAborted)
+			return context.Cause(ctx)
+		}
+
+		// If the error means that the UUID was not found, then we're in
+		// a queue.
+		if queued(err) {
+			if p.queueTimeout > 0 && time.Since(start) >= p.queueTimeout {
+				return errQueueStuck
+			}
+
+			recordAttempt(p.attempts, p.uuid, PhaseQueue, err, AttemptRetried)
+			interval = p.intervals.nextQueuePoll(interval)
+			p.logger.Debug("submission is still queued",
+				"uuid", p.uuid,
+				"attempt", p.polls,
+				"queue_wait", time.Since(start),
+				"wait_duration", interval)
+			continue
+		}
+
+		if err := p.retry(ctx, PhaseQueue, err); err != nil {
+			return err
+		}
+	}
+}
+
+// complete waits for the submission to be processed once it has left the
+// queue and returns its info, log, and outcome.
+func (p *poller) complete(ctx context.Context) (*Info, *Log, outcome, error) {
+	// Now that the UUID result has been found, we poll more quickly
+	// waiting for the analysis to complete. This usually happens within
+	// minutes.
+	infoResult, err := p.waitInfo(ctx)
+	if err != nil {
+		return infoResult, nil, outcomeUnknown, interrupted(ctx, p.opts, p.uuid, err)
+	}
+
+	// The info is enough to know the file was accepted, the log would
+	// only add warnings.
+	if p.opts.SkipLog && infoResult.Status == StatusAccepted {
+		p.logger.Info("submission accepted, skipping log", "uuid", p.uuid)
+		return infoResult, nil, outcomeAccepted, nil
+	}
+
+	// The log only exists once the info reached a terminal state, at which
+	// point it is usually available right away, so we request it without
+	// waiting for another poll interval.
+	p.logger.Debug("submission processed, requesting log",
+		"uuid", p.uuid, "status", infoResult.Status)
+	logResult, err := p.waitLog(ctx)
+	if err != nil {
+		return infoResult, logResult, outcomeUnknown, interrupted(ctx, p.opts, p.uuid, err)
+	}
+
+	// Unless both the info and log report Accepted, this is an error
+	final, err := determineOutcome(infoResult, logResult)
+	return infoResult, logResult, final, err
+}
+
+// waitInfo polls the info until it reaches a terminal state. On error, the
+// last info that was successfully requested is returned. This is never nil.
+func (p *poller) waitInfo(ctx context.Context) (*Info, error) {
+	result := &Info{RequestUUID: p.uuid}
+	for {
+		if err := p.poll(); err != nil {
+			return result, err
+		}
+
+		current, err := info(ctx, p.uuid, p.opts)
+		if err != nil && ctx.Err() != nil {
+			recordAttempt(p.attempts, p.uuid, PhaseInfo, context.Cause(ctx), AttemptAborted)
+			return result, context.Cause(ctx)
+		}
+		if err != nil {
+			if err := p.retry(ctx, PhaseInfo, err); err != nil {
+				return result, err
+			}
+			continue
+		}
+
+		p.reauthed = false
+		p.rateLimitWait = 0
+		result = current
+		p.status.InfoStatus(*result)
+		p.logger.Debug("received submission info",
+			"uuid", p.uuid, "status", result.Status, "attempt", p.polls)
+
+		// If we reached a terminal state then exit
+		if p.terminal(result.Status) {
+			p.analyzed = time.Now()
+			return result, nil
+		}
+
+		if err := p.checkUnknown(result.Status); err != nil {
+			return result, err
+		}
+
+		if err := sleep(ctx, p.intervals.StatusPoll); err != nil {
+			return result, err
+		}
+	}
+}
+
+// waitLog polls the log until it reaches a terminal state. On error, the
+// last log that was successfully requested is returned, which may be nil.
+func (p *poller) waitLog(ctx context.Context) (*Log, error) {
+	var result *Log
+	for {
+		if err := p.poll(); err != nil {
+			return result, err
+		}
+
+		current, err := log(ctx, p.uuid, p.opts)
+		if err != nil && ctx.Err() != nil {
+			recordAttempt(p.attempts, p.uuid, PhaseLog, context.Cause(ctx), AttemptAborted)
+			return result, context.Cause(ctx)
+		}
+		if err != nil {
+			if err := p.retry(ctx, PhaseLog, err); err != nil {
+				return result, err
+			}
+			continue
+		}
+
+		p.reauthed = false
+		p.rateLimitWait = 0
+		result = current
+		p.status.LogStatus(*result)
+		p.logger.Debug("received submission log",
+			"uuid", p.uuid, "status", result.Status, "attempt", p.polls)
+
+		// If we reached a terminal state then exit
+		if p.terminal(result.Status) {
+			return result, nil
+		}
+
+		if err := p.checkUnknown(result.Status); err != nil {
+			return result, err
+		}
+
+		if err := sleep(ctx, p.intervals.LogPoll); err != nil {
+			return result, err
+		}
+	}
+}
+
+// retry handles an error from a request made while waiting on the
+// submission. Network and rate limit errors are retried after waiting, and
+// anything else is handled by handleAuth. This returns nil if the request
+// should be retried, and otherwise the error to return from the polling
+// loop.
+func (p *poller) retry(ctx context.Context, phase Phase, err error) error {
+	if errors.Is(err, Error{Code: codeNetworkUnavailable}) {
+		p.logger.Warn("error that network became unavailable, will retry",
+			"uuid", p.uuid,
+			"phase", phase,
+			"attempt", p.polls,
+			"error_code", errorCode(err),
+			"wait_duration", p.intervals.NetworkRetry,
+			"description", CodeDescription(codeNetworkUnavailable))
+		recordAttempt(p.attempts, p.uuid, phase, err, AttemptRetried)
+		return sleep(ctx, p.intervals.NetworkRetry)
+	}
+
+	// Rate limiting is reported differently and needs a longer wait.
+	if isRateLimitError(err) {
+		return p.handleRateLimit(ctx, phase, err)
+	}
+
+	return p.handleAuth(phase, err)
+}
+
+// handleAuth handles an error from a request that isn't otherwise retried.
+// If the error is due to expired authentication and ReauthFunc refreshes it,
+// this returns nil and the request should be retried. Otherwise, this
+// returns the error that should be returned from the polling loop.
+func (p *poller) handleAuth(phase Phase, err error) error {
+	if !isAuthError(err) {
+		recordAttempt(p.attempts, p.uuid, phase, err, AttemptAborted)
+		return err
+	}
+
+	if p.opts.ReauthFunc == nil || p.reauthed {
+		recordAttempt(p.attempts, p.uuid, phase, err, AttemptAborted)
+		return &AuthExpiredError{RequestUUID: p.uuid, Err: err}
+	}
+
+	p.logger.Warn("authentication expired, reauthenticating",
+		"uuid", p.uuid, "phase", phase, "attempt", p.polls, "err", err)
+	if rerr := p.opts.ReauthFunc(); rerr != nil {
+		recordAttempt(p.attempts, p.uuid, phase, err, AttemptAborted)
+		return &AuthExpiredError{RequestUUID: p.uuid, Err: rerr}
+	}
+
+	refreshCredentials(p.opts)
+	recordAttempt(p.attempts, p.uuid, phase, err, AttemptRetried)
+	p.reauthed = true
+	return nil
+}
+
+// terminal returns true if status means that Apple finished processing
+// the submission, including the Options.ExtraTerminalStatuses.
+func (p *poller) terminal(status SubmissionStatus) bool {
+	if status.Terminal() {
+		return true
+	}
+
+	for _, extra := range p.opts.ExtraTerminalStatuses {
+		if status == extra {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkUnknown counts the consecutive requests that reported a status that
+// isn't known and returns ErrUnknownStatus once there were too many, so an
+// unknown status that will never change can't make us wait forever.
+func (p *poller) checkUnknown(status SubmissionStatus) error {
+	if status.Known() {
+		p.unknownPolls = 0
+		return nil
+	}
+
+	p.unknownPolls++
+	p.logger.Warn("submission has an unknown status",
+		"uuid", p.uuid, "status", status, "attempt", p.polls, "count", p.unknownPolls)
+	if p.unknownPolls < maxUnknownStatusPolls {
+		return nil
+	}
+
+	return fmt.Errorf("%w %q for %d requests, set Options.ExtraTerminalStatuses if it is final",
+		ErrUnknownStatus, status, p.unknownPolls)
+}
+
+// handleRateLimit waits before retrying a request that Apple rejected
+// because of rate limiting. The wait is longer than for network errors
+// since retrying quickly would only prolong the throttling, especially
+// when many files are notarized at once.
+func (p *poller) handleRateLimit(ctx context.Context, phase Phase, err error) error {
+	wait := p.intervals.RateLimitRetry
+	if p.rateLimitWait > 0 {
+		wait = p.intervals.nextRateLimitRetry(p.rateLimitWait)
+	}
+	p.rateLimitWait = wait
+
+	p.logger.Warn("rate limited by Apple, will retry",
+		"uuid", p.uuid,
+		"phase", phase,
+		"attempt", p.polls,
+		"wait_duration", wait,
+		"err", err)
+	recordAttempt(p.attempts, p.uuid, phase, err, AttemptRetried)
+	p.status.RateLimited(p.uuid, wait)
+	return sleep(ctx, wait)
+}
+
+// errQueueStuck is returned by waitQueue when the submission was queued
+// for longer than the queue timeout.
+var errQueueStuck = errors.New("submission was queued for longer than the resubmit timeout")
+
+// isQueuedError returns true if err is the 1519 (UUID not found) error
+// that Apple returns while a submission is still waiting in the queue.
+func isQueuedError(err error) bool {
+	var e Errors
+	return errors.As(err, &e) && e.ContainsCode(codeUUIDNotFound)
+}
+
+// timerFunc is the function sleep uses to start a timer. This is only
+// overridden by tests to check that every timer is stopped.
+var timerFunc = newTimer
+
+// newTimer returns the channel and stop function of a timer that fires
+// after d.
+func newTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	timer := time.NewTimer(d)
+	return timer.C, timer.Stop
+}
+
+// sleep waits for the given duration or until the context is done, in
+// which case the cause of the context being done is returned.
+func sleep(ctx context.Context, d time.Duration) error {
+	c, stop := timerFunc(d)
+	defer stop()
+
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-c:
+		return nil
+	}
+}
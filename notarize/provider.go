@@ -0,0 +1,160 @@
+package notarize
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+	"howett.net/plist"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+)
+
+// ErrProviderRequired is matched by the error returned when Provider isn't
+// set but the Apple ID has access to multiple providers. The error lists
+// the team IDs that Provider can be set to.
+var ErrProviderRequired = errors.New("the Apple ID has multiple providers, Provider must be set")
+
+// Provider is a provider, or team, that an Apple ID can notarize for.
+type Provider struct {
+	// Name and ShortName are the name of the provider and its short name.
+	Name      string `plist:"ProviderName"`
+	ShortName string `plist:"ProviderShortname"`
+
+	// PublicID is the public ID of the provider.
+	PublicID string `plist:"PublicID"`
+
+	// TeamID is the team ID of the provider, which is the value to set
+	// Options.Provider to.
+	TeamID string `plist:"WWDRTeamID"`
+}
+
+// Providers returns the providers that the Apple ID of opts has access to,
+// which is useful to let users pick one for Options.Provider. notarytool
+// can't list providers, so this runs `altool --list-providers`. This
+// requires an Apple ID and isn't supported with an API key since keys
+// belong to a single team.
+func Providers(ctx context.Context, opts *Options) ([]Provider, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	logger = withBuildID(ctx, logger)
+
+	if opts.DeveloperId == "" {
+		return nil, errors.New("listing providers requires an Apple ID")
+	}
+
+	password, err := resolvePassword(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, err := altoolCmd(ctx, opts,
+		"--list-providers",
+		"-u", opts.DeveloperId,
+		"-p", password,
+		"--output-format", "xml",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	workdir.Apply(&cmd, opts.WorkDir)
+
+	var out, combined bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &combined
+
+	// Log what we're going to execute
+	logger.Info("listing providers",
+		"command_path", cmd.Path,
+		"command_args", cmd.Args,
+	)
+
+	// Execute
+	err = cmd.Run()
+
+	// Log the result
+	logger.Info("listing providers complete",
+		"output", out.String(),
+		"err", err,
+	)
+
+	if err != nil {
+		combined.Write(out.Bytes())
+		return nil, commandFailed("error listing providers", combined.String())
+	}
+
+	var result providersResult
+	if _, perr := plist.Unmarshal(out.Bytes(), &result); perr != nil {
+		return nil, fmt.Errorf("failed to decode providers output: %w", perr)
+	}
+
+	return result.Providers, nil
+}
+
+// providersResult is the plist structure of the altool providers output.
+type providersResult struct {
+	Providers []Provider `plist:"providers"`
+}
+
+// altoolCmd returns the command that runs altool with args. This is BaseCmd
+// if it is set, and `xcrun altool` by default.
+func altoolCmd(ctx context.Context, opts *Options, args ...string) (exec.Cmd, error) {
+	if opts.BaseCmd != nil && opts.BaseCmd.Path != "" {
+		cmd := *opts.BaseCmd
+		cmd.Args = append([]string{filepath.Base(cmd.Path), "altool"}, args...)
+		return cmd, nil
+	}
+
+	path, err := exec.LookPath("xcrun")
+	if err != nil {
+		return exec.Cmd{}, err
+	}
+
+	return *(exec.CommandContext(ctx, path, append([]string{"altool"}, args...)...)), nil
+}
+
+// resolveProvider returns opts with Provider set if it is empty and the
+// Apple ID has exactly one provider. If it has multiple, this returns an
+// error matching ErrProviderRequired that lists them. Failing to list the
+// providers isn't an error since notarytool reports its own if it needs a
+// provider, so opts is returned unchanged in that case.
+func resolveProvider(ctx context.Context, logger hclog.Logger, opts *Options) (*Options, error) {
+	apiKey := opts.APIKeyPath != "" || opts.APIKeyID != "" || opts.APIKeyIssuerID != ""
+	if opts.Provider != "" || opts.DeveloperId == "" || apiKey {
+		return opts, nil
+	}
+
+	providers, err := Providers(ctx, opts)
+	if err != nil {
+		logger.Warn("error listing providers, continuing without one", "err", err)
+		return opts, nil
+	}
+
+	switch len(providers) {
+	case 0:
+		return opts, nil
+
+	case 1:
+		logger.Info("using the only provider of the Apple ID",
+			"provider", providers[0].ShortName, "team_id", providers[0].TeamID)
+		resolved := copyOptions(opts)
+		resolved.Provider = providers[0].TeamID
+		return resolved, nil
+
+	default:
+		var list strings.Builder
+		for _, p := range providers {
+			fmt.Fprintf(&list, "\n  - %s (%s, %s)", p.TeamID, p.ShortName, p.Name)
+		}
+
+		return nil, fmt.Errorf("%w to one of these team IDs:\n%s", ErrProviderRequired, list.String())
+	}
+}
@@ -0,0 +1,95 @@
+package notarize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	childCommands["providers"] = testCmdProviders
+}
+
+func TestProviders(t *testing.T) {
+	providers, err := Providers(context.Background(), &Options{
+		DeveloperId: "foo@example.com",
+		Logger:      hclog.L(),
+		BaseCmd:     childCmd(t, "providers"),
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []Provider{
+		{Name: "Example Inc", ShortName: "ExampleInc", PublicID: "public-1", TeamID: "TEAM1"},
+		{Name: "Other LLC", ShortName: "OtherLLC", PublicID: "public-2", TeamID: "TEAM2"},
+	}, providers)
+}
+
+func TestResolveProvider(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	// A single provider is used automatically.
+	cmd := childCmd(t, "providers")
+	cmd.Env = append(cmd.Env, childEnv+"_SINGLE=1")
+	opts := &Options{DeveloperId: "foo@example.com", BaseCmd: cmd}
+	resolved, err := resolveProvider(ctx, hclog.L(), opts)
+	req.NoError(err)
+	req.Equal("TEAM1", resolved.Provider)
+	req.Empty(opts.Provider)
+
+	// Multiple providers must be chosen from.
+	opts = &Options{DeveloperId: "foo@example.com", BaseCmd: childCmd(t, "providers")}
+	_, err = resolveProvider(ctx, hclog.L(), opts)
+	req.ErrorIs(err, ErrProviderRequired)
+	req.ErrorContains(err, "TEAM1 (ExampleInc, Example Inc)")
+	req.ErrorContains(err, "TEAM2 (OtherLLC, Other LLC)")
+
+	// Nothing is listed if the provider is already set.
+	opts.Provider = "TEAM2"
+	resolved, err = resolveProvider(ctx, hclog.L(), opts)
+	req.NoError(err)
+	req.Equal("TEAM2", resolved.Provider)
+}
+
+// testCmdProviders mimicks altool listing two providers, or only the first
+// one if the _SINGLE environment variable is set.
+func testCmdProviders() int {
+	if len(os.Args) < 3 || os.Args[1] != "altool" || os.Args[2] != "--list-providers" {
+		return 1
+	}
+
+	providers := `
+		<dict>
+			<key>ProviderName</key><string>Example Inc</string>
+			<key>ProviderShortname</key><string>ExampleInc</string>
+			<key>PublicID</key><string>public-1</string>
+			<key>WWDRTeamID</key><string>TEAM1</string>
+		</dict>`
+	if os.Getenv(childEnv+"_SINGLE") == "" {
+		providers += `
+		<dict>
+			<key>ProviderName</key><string>Other LLC</string>
+			<key>ProviderShortname</key><string>OtherLLC</string>
+			<key>PublicID</key><string>public-2</string>
+			<key>WWDRTeamID</key><string>TEAM2</string>
+		</dict>`
+	}
+
+	fmt.Println(strings.TrimSpace(`
+<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>providers</key>
+	<array>` + providers + `</array>
+	<key>success-message</key>
+	<string>Successfully listed providers.</string>
+</dict>
+</plist>`))
+	return 0
+}
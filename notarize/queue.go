@@ -0,0 +1,379 @@
+package notarize
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// ErrJobNotFound is returned by a JobStore when a job doesn't exist.
+var ErrJobNotFound = errors.New("job not found")
+
+// JobState is the state of a job in a Queue.
+type JobState string
+
+const (
+	// JobQueued is a job that is waiting for a worker.
+	JobQueued JobState = "queued"
+
+	// JobRunning is a job that a worker is notarizing.
+	JobRunning JobState = "running"
+
+	// JobSucceeded is a job whose file was accepted by Apple.
+	JobSucceeded JobState = "succeeded"
+
+	// JobFailed is a job whose notarization failed. The Error field of the
+	// record explains why.
+	JobFailed JobState = "failed"
+)
+
+// Job is a file to notarize with a Queue.
+type Job struct {
+	// File is the file to notarize. This overrides the File field of
+	// Options.
+	File string
+
+	// Options are the options to notarize with. This may be nil, in which
+	// case the default options of the queue are used.
+	Options *Options
+}
+
+// JobRecord is the state of a job that is persisted in a JobStore.
+type JobRecord struct {
+	// ID is the ID returned by Queue.Enqueue.
+	ID string
+
+	// File is the file that is notarized.
+	File string
+
+	// State is the state of the job.
+	State JobState
+
+	// RequestUUID is the UUID of the submission, which is saved as soon as
+	// the upload returns it.
+	RequestUUID string
+
+	// Status is the status of the submission reported by Apple.
+	Status SubmissionStatus
+
+	// Error is the error message if the job failed.
+	Error string
+
+	// Created and Updated are when the job was enqueued and when the
+	// record was last saved.
+	Created time.Time
+	Updated time.Time
+}
+
+// JobStore persists the records of the jobs in a Queue. Implementations
+// must be safe for concurrent use.
+type JobStore interface {
+	// Save creates or replaces the record with the same ID.
+	Save(record *JobRecord) error
+
+	// Load returns the record with the given ID, or an error matching
+	// ErrJobNotFound.
+	Load(id string) (*JobRecord, error)
+
+	// List returns all the records.
+	List() ([]*JobRecord, error)
+}
+
+// MemoryJobStore is a JobStore that keeps the records in memory. The zero
+// value is ready to use.
+type MemoryJobStore struct {
+	lock    sync.Mutex
+	records map[string]JobRecord
+}
+
+// Save implements JobStore
+func (s *MemoryJobStore) Save(record *JobRecord) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.records == nil {
+		s.records = map[string]JobRecord{}
+	}
+	s.records[record.ID] = *record
+	return nil
+}
+
+// Load implements JobStore
+func (s *MemoryJobStore) Load(id string) (*JobRecord, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	record, ok := s.records[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	return &record, nil
+}
+
+// List implements JobStore. The records are sorted by creation time.
+func (s *MemoryJobStore) List() ([]*JobRecord, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	result := make([]*JobRecord, 0, len(s.records))
+	for _, record := range s.records {
+		record := record
+		result = append(result, &record)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Created.Before(result[j].Created)
+	})
+
+	return result, nil
+}
+
+// Assert that we always implement it
+var _ JobStore = (*MemoryJobStore)(nil)
+
+// Queue notarizes jobs with a pool of workers, which is the building block
+// for a service that accepts notarization jobs. Jobs are enqueued with
+// Enqueue and processed while Run is running. The state of each job is
+// saved to the store as it changes.
+//
+// If a job doesn't set an UploadLock, uploads are serialized per bundle ID
+// across all the jobs of the queue.
+//
+// Jobs that are still queued or running in the store when the queue is
+// created, such as after a restart, are recovered with the default options
+// of the queue. A recovered job that was already submitted waits on its
+// submission rather than uploading the file again.
+type Queue struct {
+	store   JobStore
+	workers int
+	opts    *Options
+
+	lock        sync.Mutex
+	cond        *sync.Cond
+	pending     []queuedJob
+	uploadLocks map[string]*sync.Mutex
+}
+
+// queuedJob is a job waiting for a worker.
+type queuedJob struct {
+	record *JobRecord
+	opts   Options
+}
+
+// NewQueue returns a queue that saves jobs to store and notarizes up to
+// workers jobs concurrently. opts are the default options for jobs that
+// don't set any and may be nil.
+func NewQueue(store JobStore, workers int, opts *Options) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	q := &Queue{
+		store:       store,
+		workers:     workers,
+		opts:        opts,
+		uploadLocks: map[string]*sync.Mutex{},
+	}
+	q.cond = sync.NewCond(&q.lock)
+	q.recover()
+	return q
+}
+
+// recover adds the jobs of the store that didn't finish to the pending
+// jobs. Errors are only logged since the queue can still take new jobs.
+func (q *Queue) recover() {
+	logger := q.opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+
+	records, err := q.store.List()
+	if err != nil {
+		logger.Error("error listing jobs to recover", "err", err)
+		return
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Created.Before(records[j].Created)
+	})
+
+	for _, record := range records {
+		if record.State != JobQueued && record.State != JobRunning {
+			continue
+		}
+
+		logger.Info("recovering job", "job", record.ID, "state", record.State)
+		queued := queuedJob{record: record, opts: *copyOptions(q.opts)}
+		queued.opts.File = record.File
+		shareUploadLock(q.uploadLocks, &queued.opts)
+		q.pending = append(q.pending, queued)
+	}
+}
+
+// Enqueue adds a job to the queue and returns its ID, which can be used to
+// load its record from the store. The job is saved in the JobQueued state
+// before this returns.
+func (q *Queue) Enqueue(job Job) (string, error) {
+	opts := q.opts
+	if job.Options != nil {
+		opts = job.Options
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	record := &JobRecord{
+		ID:      id,
+		File:    job.File,
+		State:   JobQueued,
+		Created: now,
+		Updated: now,
+	}
+	if err := q.store.Save(record); err != nil {
+		return "", err
+	}
+
+	queued := queuedJob{record: record, opts: *copyOptions(opts)}
+	queued.opts.File = job.File
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	shareUploadLock(q.uploadLocks, &queued.opts)
+	q.pending = append(q.pending, queued)
+	q.cond.Signal()
+
+	return id, nil
+}
+
+// Run processes jobs until ctx is cancelled and returns once all the
+// workers have stopped. Cancelling ctx also cancels the running jobs,
+// which fail with the context error. Jobs that no worker started stay in
+// the JobQueued state in the store, so a new queue with the same store
+// recovers them.
+func (q *Queue) Run(ctx context.Context) error {
+	// Wake up the idle workers once we're cancelled so they can exit.
+	stop := context.AfterFunc(ctx, func() {
+		q.lock.Lock()
+		defer q.lock.Unlock()
+		q.cond.Broadcast()
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < q.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+
+	wg.Wait()
+	return ctx.Err()
+}
+
+// work runs jobs until ctx is cancelled.
+func (q *Queue) work(ctx context.Context) {
+	for {
+		q.lock.Lock()
+		for len(q.pending) == 0 && ctx.Err() == nil {
+			q.cond.Wait()
+		}
+		if ctx.Err() != nil {
+			q.lock.Unlock()
+			return
+		}
+
+		job := q.pending[0]
+		q.pending = q.pending[1:]
+		q.lock.Unlock()
+
+		q.run(ctx, job)
+	}
+}
+
+// run notarizes a single job and saves its state.
+func (q *Queue) run(ctx context.Context, job queuedJob) {
+	logger := job.opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	logger = withBuildID(ctx, logger).With("job", job.record.ID)
+	job.opts.Logger = logger
+
+	record := job.record
+	record.State = JobRunning
+	q.save(logger, record)
+
+	status := job.opts.Status
+	if status == nil {
+		status = noopStatus{}
+	}
+	job.opts.Status = &jobStatus{Status: status, queue: q, logger: logger, record: record}
+
+	var info *Info
+	var err error
+	if record.RequestUUID != "" {
+		// A recovered job that was already submitted.
+		info, _, err = WaitForCompletion(ctx, ResumeToken{RequestUUID: record.RequestUUID}, &job.opts)
+	} else {
+		info, _, err = notarize(ctx, &job.opts, nil)
+	}
+	if info != nil {
+		record.RequestUUID = info.RequestUUID
+		record.Status = info.Status
+	}
+
+	record.State = JobSucceeded
+	if err != nil {
+		record.State = JobFailed
+		record.Error = err.Error()
+	}
+	q.save(logger, record)
+}
+
+// save saves record to the store. Errors are only logged since they
+// shouldn't interrupt the notarization.
+func (q *Queue) save(logger hclog.Logger, record *JobRecord) {
+	record.Updated = time.Now()
+	if err := q.store.Save(record); err != nil {
+		logger.Error("error saving job", "state", record.State, "err", err)
+	}
+}
+
+// jobStatus implements Status by saving the request UUID of a job as soon
+// as it is submitted and forwarding every callback to the wrapped Status.
+type jobStatus struct {
+	Status
+
+	queue  *Queue
+	logger hclog.Logger
+	record *JobRecord
+}
+
+func (s *jobStatus) Submitted(uuid string) {
+	s.Status.Submitted(uuid)
+	s.record.RequestUUID = uuid
+	s.queue.save(s.logger, s.record)
+}
+
+// newJobID returns a random ID for a job.
+func newJobID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b[:]), nil
+}
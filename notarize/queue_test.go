@@ -0,0 +1,173 @@
+package notarize
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue(t *testing.T) {
+	store := &MemoryJobStore{}
+	q := NewQueue(store, 2, &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	accepted, err := q.Enqueue(Job{File: "a.zip"})
+	req.NoError(err)
+	failed, err := q.Enqueue(Job{File: "b.zip", Options: &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "upload-exit-status"),
+		Intervals: testIntervals,
+	}})
+	req.NoError(err)
+
+	record, err := store.Load(accepted)
+	req.NoError(err)
+	req.Equal(JobQueued, record.State)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- q.Run(ctx) }()
+
+	req.Eventually(func() bool {
+		records, err := store.List()
+		if err != nil {
+			return false
+		}
+
+		for _, r := range records {
+			if r.State != JobSucceeded && r.State != JobFailed {
+				return false
+			}
+		}
+
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+
+	cancel()
+	req.ErrorIs(<-done, context.Canceled)
+
+	record, err = store.Load(accepted)
+	req.NoError(err)
+	req.Equal(JobSucceeded, record.State)
+	req.Equal("a.zip", record.File)
+	req.Equal(StatusAccepted, record.Status)
+	req.NotEmpty(record.RequestUUID)
+
+	record, err = store.Load(failed)
+	req.NoError(err)
+	req.Equal(JobFailed, record.State)
+	req.NotEmpty(record.Error)
+}
+
+func TestQueue_recover(t *testing.T) {
+	req := require.New(t)
+	store := &MemoryJobStore{}
+	now := time.Now()
+	for _, record := range []*JobRecord{
+		{ID: "queued", File: "a.zip", State: JobQueued, Created: now},
+		{ID: "submitted", File: "b.zip", State: JobRunning, RequestUUID: "cfd69166-8e2f-1397-8636-ec06f98e3597", Created: now.Add(time.Second)},
+		{ID: "done", File: "c.zip", State: JobFailed, Error: "nope", Created: now.Add(2 * time.Second)},
+	} {
+		req.NoError(store.Save(record))
+	}
+
+	var uploads []string
+	uploadFunc = func(ctx context.Context, opts *Options) (string, error) {
+		uploads = append(uploads, opts.File)
+		return upload(ctx, opts)
+	}
+	defer func() { uploadFunc = upload }()
+
+	q := NewQueue(store, 1, &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- q.Run(ctx) }()
+
+	req.Eventually(func() bool {
+		for _, id := range []string{"queued", "submitted"} {
+			record, err := store.Load(id)
+			if err != nil || record.State != JobSucceeded {
+				return false
+			}
+		}
+
+		return true
+	}, 10*time.Second, 10*time.Millisecond)
+
+	cancel()
+	req.ErrorIs(<-done, context.Canceled)
+
+	// The submitted job only waits on its submission.
+	req.Equal([]string{"a.zip"}, uploads)
+
+	record, err := store.Load("done")
+	req.NoError(err)
+	req.Equal(JobFailed, record.State)
+}
+
+func TestQueue_requestUUIDSaved(t *testing.T) {
+	req := require.New(t)
+	store := &savesJobStore{}
+	q := NewQueue(store, 1, &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+	})
+
+	id, err := q.Enqueue(Job{File: "a.zip"})
+	req.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- q.Run(ctx) }()
+
+	req.Eventually(func() bool {
+		record, err := store.Load(id)
+		return err == nil && record.State == JobSucceeded
+	}, 10*time.Second, 10*time.Millisecond)
+
+	cancel()
+	req.ErrorIs(<-done, context.Canceled)
+
+	// The UUID is saved while the job is still running.
+	var running bool
+	for _, record := range store.saves {
+		if record.State == JobRunning && record.RequestUUID != "" {
+			running = true
+		}
+	}
+	req.True(running)
+}
+
+// savesJobStore is a MemoryJobStore that records every saved record.
+type savesJobStore struct {
+	MemoryJobStore
+
+	lock  sync.Mutex
+	saves []JobRecord
+}
+
+func (s *savesJobStore) Save(record *JobRecord) error {
+	s.lock.Lock()
+	s.saves = append(s.saves, *record)
+	s.lock.Unlock()
+	return s.MemoryJobStore.Save(record)
+}
+
+func TestMemoryJobStore_notFound(t *testing.T) {
+	_, err := (&MemoryJobStore{}).Load("foo")
+	require.ErrorIs(t, err, ErrJobNotFound)
+}
@@ -0,0 +1,45 @@
+package notarize
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotarytoolUnavailable is matched by the error returned by
+// CheckRequirements when notarytool can't be run.
+var ErrNotarytoolUnavailable = errors.New("notarytool is not available")
+
+// notarytoolGuidance explains how to install notarytool.
+const notarytoolGuidance = "Notarization requires notarytool, which is included with Xcode 13 or later " +
+	"and the matching Command Line Tools. Install or update Xcode, or run " +
+	"`xcode-select --install` to install the Command Line Tools, and make sure " +
+	"they are selected with `xcode-select --print-path`."
+
+// CheckRequirements checks that notarytool can be run the way Notarize
+// runs it, so that a missing or outdated Xcode installation is reported
+// with guidance on how to fix it rather than as an error from executing
+// a command. This respects BaseCmd and NotarytoolLocator, and nothing is
+// sent to Apple. The error matches ErrNotarytoolUnavailable.
+func CheckRequirements(ctx context.Context, opts *Options) error {
+	cmd, err := notarytoolCmd(ctx, opts, "--version")
+	if err != nil {
+		return fmt.Errorf("%w: %s\n\n%s", ErrNotarytoolUnavailable, err, notarytoolGuidance)
+	}
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		detail := strings.TrimSpace(out.String())
+		if detail == "" {
+			detail = err.Error()
+		}
+
+		return fmt.Errorf("%w: %s\n\n%s", ErrNotarytoolUnavailable, detail, notarytoolGuidance)
+	}
+
+	return nil
+}
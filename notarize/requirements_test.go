@@ -0,0 +1,65 @@
+package notarize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	childCommands["requirements-ok"] = testCmdRequirementsOK
+	childCommands["requirements-missing"] = testCmdRequirementsMissing
+}
+
+func TestCheckRequirements(t *testing.T) {
+	err := CheckRequirements(context.Background(), &Options{
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "requirements-ok"),
+	})
+	require.NoError(t, err)
+}
+
+func TestCheckRequirements_missing(t *testing.T) {
+	err := CheckRequirements(context.Background(), &Options{
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "requirements-missing"),
+	})
+
+	req := require.New(t)
+	req.ErrorIs(err, ErrNotarytoolUnavailable)
+	req.ErrorContains(err, `unable to find utility "notarytool"`)
+	req.ErrorContains(err, "Xcode 13 or later")
+}
+
+func TestCheckRequirements_locatorError(t *testing.T) {
+	err := CheckRequirements(context.Background(), &Options{
+		Logger: hclog.L(),
+		NotarytoolLocator: func() (*exec.Cmd, error) {
+			return nil, exec.ErrNotFound
+		},
+	})
+	require.ErrorIs(t, err, ErrNotarytoolUnavailable)
+}
+
+// testCmdRequirementsOK mimicks notarytool --version.
+func testCmdRequirementsOK() int {
+	if len(os.Args) < 3 || os.Args[1] != "notarytool" || os.Args[2] != "--version" {
+		fmt.Fprintf(os.Stderr, "unexpected args: %v\n", os.Args)
+		return 1
+	}
+
+	fmt.Println("1.2.0 (29)")
+	return 0
+}
+
+// testCmdRequirementsMissing mimicks xcrun with an Xcode that doesn't
+// include notarytool.
+func testCmdRequirementsMissing() int {
+	fmt.Fprintln(os.Stderr, `xcrun: error: unable to find utility "notarytool", not a developer tool or in PATH`)
+	return 72
+}
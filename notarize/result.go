@@ -0,0 +1,49 @@
+package notarize
+
+import "time"
+
+// Result is the outcome of notarizing a single file.
+type Result struct {
+	// File is the file that was notarized.
+	File string
+
+	// Info is the notarization info. This may be nil if the file was
+	// never successfully submitted.
+	Info *Info
+
+	// Log is the notarization log. This may be nil if notarization didn't
+	// reach a terminal state.
+	Log *Log
+
+	// Err is the error that occurred during notarization, if any.
+	Err error
+
+	// Duration is the wall-clock time that notarization took.
+	Duration time.Duration
+
+	// StapleDuration is how long stapling the ticket to File took. This
+	// is only set by EnsureNotarized, and is zero if it didn't staple.
+	StapleDuration time.Duration
+
+	// Attempts are the requests that failed during notarization and what
+	// was done about them, in order. This is empty if nothing failed.
+	Attempts []Attempt
+
+	// Stapled is true if the notarization ticket is stapled to File.
+	Stapled bool
+
+	// GatekeeperAccepted is true if Gatekeeper accepted File once the
+	// ticket was stapled. Together with a nil Err this means File is ready
+	// to ship. This is only set if Stapled is true.
+	GatekeeperAccepted bool
+
+	// Assessment is the output of the Gatekeeper assessment, which explains
+	// why File was accepted or rejected. This is only set if Stapled is
+	// true.
+	Assessment string
+
+	// AssessErr is the error that prevented Gatekeeper from assessing
+	// File, such as spctl failing to run. This isn't set in Err since the
+	// file was still notarized and stapled.
+	AssessErr error
+}
@@ -0,0 +1,167 @@
+package notarize
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// resumeTokenPrefix prefixes the text form of a ResumeToken so that the
+// format can change in the future.
+const resumeTokenPrefix = "gon1:"
+
+// ResumeToken identifies a submission so that waiting on it can continue
+// later, such as in a different CI stage, with WaitForCompletion. It
+// references the credentials by Apple ID and team, or by API key ID and
+// issuer, rather than including the password or the private key, so the
+// text form is safe to persist.
+type ResumeToken struct {
+	// RequestUUID is the UUID of the submission.
+	RequestUUID string `json:"id"`
+
+	// DeveloperId and Provider are the Apple ID and team ID that the file
+	// was submitted with.
+	DeveloperId string `json:"appleId,omitempty"`
+	Provider    string `json:"teamId,omitempty"`
+
+	// APIKeyID and APIKeyIssuerID identify the App Store Connect API key
+	// that the file was submitted with. The path to the private key isn't
+	// included since it is only valid on the machine that submitted.
+	APIKeyID       string `json:"keyId,omitempty"`
+	APIKeyIssuerID string `json:"issuer,omitempty"`
+
+	// ContentHash is the ContentHash of the options the file was submitted
+	// with, if any.
+	ContentHash string `json:"hash,omitempty"`
+}
+
+// newResumeToken returns the token for the submission uuid made with opts.
+func newResumeToken(opts *Options, uuid string) ResumeToken {
+	return ResumeToken{
+		RequestUUID: uuid,
+		DeveloperId: opts.DeveloperId,
+		Provider:    opts.Provider,
+		ContentHash: opts.ContentHash,
+
+		APIKeyID:       opts.APIKeyID,
+		APIKeyIssuerID: opts.APIKeyIssuerID,
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (t ResumeToken) MarshalText() ([]byte, error) {
+	if t.RequestUUID == "" {
+		return nil, errors.New("resume token has no request UUID")
+	}
+
+	// The alias doesn't implement TextMarshaler so json doesn't recurse.
+	type plain ResumeToken
+	data, err := json.Marshal(plain(t))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(resumeTokenPrefix + base64.RawURLEncoding.EncodeToString(data)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (t *ResumeToken) UnmarshalText(text []byte) error {
+	encoded, ok := bytes.CutPrefix(bytes.TrimSpace(text), []byte(resumeTokenPrefix))
+	if !ok {
+		return errors.New("invalid resume token: unknown format")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return fmt.Errorf("invalid resume token: %w", err)
+	}
+
+	type plain ResumeToken
+	var result plain
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("invalid resume token: %w", err)
+	}
+	if result.RequestUUID == "" {
+		return errors.New("invalid resume token: no request UUID")
+	}
+
+	*t = ResumeToken(result)
+	return nil
+}
+
+// WaitForCompletion waits on a submission identified by token until Apple
+// finished processing it, just like Notarize does after uploading. The
+// credentials come from opts, where DeveloperId, Provider, and ContentHash
+// default to those of the token, and so do APIKeyID and APIKeyIssuerID
+// for a token of a submission made with an API key. APIKeyPath must then
+// be set in opts. The File field and the options that only affect
+// uploading are ignored.
+func WaitForCompletion(ctx context.Context, token ResumeToken, opts *Options) (*Info, *Log, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	logger = withBuildID(ctx, logger)
+
+	status := newStatus(ctx, opts, logger)
+
+	// The Apple ID of the token is only used if opts doesn't set an API
+	// key, and the other way around, since both can't be used at once.
+	waitOpts := copyOptions(opts)
+	if waitOpts.APIKeyPath == "" && waitOpts.APIKeyID == "" && waitOpts.APIKeyIssuerID == "" {
+		if waitOpts.DeveloperId == "" {
+			waitOpts.DeveloperId = token.DeveloperId
+		}
+		if waitOpts.Provider == "" {
+			waitOpts.Provider = token.Provider
+		}
+	}
+	if waitOpts.DeveloperId == "" {
+		if waitOpts.APIKeyID == "" {
+			waitOpts.APIKeyID = token.APIKeyID
+		}
+		if waitOpts.APIKeyIssuerID == "" {
+			waitOpts.APIKeyIssuerID = token.APIKeyIssuerID
+		}
+	}
+	if waitOpts.ContentHash == "" {
+		waitOpts.ContentHash = token.ContentHash
+	}
+
+	if opts.MaxTotalDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, opts.MaxTotalDuration, ErrTotalTimeout)
+		defer cancel()
+	}
+
+	logger.Info("resuming wait for notarization", "uuid", token.RequestUUID)
+	p := &poller{
+		opts:      waitOpts,
+		uuid:      token.RequestUUID,
+		logger:    logger,
+		status:    status,
+		intervals: opts.Intervals.withDefaults(),
+	}
+
+	queueStart := time.Now()
+	if err := p.waitQueue(ctx); err != nil {
+		return &Info{RequestUUID: token.RequestUUID}, nil, interrupted(ctx, waitOpts, token.RequestUUID, err)
+	}
+	status.QueueCleared(token.RequestUUID, time.Since(queueStart))
+
+	infoResult, logResult, final, err := p.complete(ctx)
+	if final == outcomeAccepted && opts.ResultCache != nil && waitOpts.ContentHash != "" {
+		result := &Result{File: infoResult.Name, Info: infoResult, Log: logResult}
+		if err := opts.ResultCache.Put(ctx, waitOpts.ContentHash, result); err != nil {
+			logger.Warn("error writing result cache", "hash", waitOpts.ContentHash, "err", err)
+		}
+	}
+
+	return infoResult, logResult, err
+}
@@ -0,0 +1,91 @@
+package notarize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	childCommands["resume-api-key"] = testCmdResumeAPIKey
+}
+
+func TestResumeToken_text(t *testing.T) {
+	token := ResumeToken{
+		RequestUUID: "cfd69166-8e2f-1397-8636-ec06f98e3597",
+		DeveloperId: "foo@example.com",
+		Provider:    "ABCDE12345",
+		ContentHash: "abc",
+
+		APIKeyID:       "KEY",
+		APIKeyIssuerID: "issuer",
+	}
+
+	text, err := token.MarshalText()
+	require.NoError(t, err)
+	require.NotContains(t, string(text), "\n")
+
+	var result ResumeToken
+	require.NoError(t, result.UnmarshalText(text))
+	require.Equal(t, token, result)
+}
+
+func TestResumeToken_invalid(t *testing.T) {
+	_, err := ResumeToken{}.MarshalText()
+	require.Error(t, err)
+
+	var token ResumeToken
+	for _, text := range []string{"", "cfd69166", "gon1:!!!", "gon1:e30"} {
+		require.Error(t, token.UnmarshalText([]byte(text)), text)
+	}
+}
+
+func TestWaitForCompletion(t *testing.T) {
+	info, log, err := WaitForCompletion(context.Background(), ResumeToken{
+		RequestUUID: "cfd69166-8e2f-1397-8636-ec06f98e3597",
+	}, &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal(StatusAccepted, info.Status)
+	req.Equal(StatusAccepted, log.Status)
+}
+
+func TestWaitForCompletion_apiKey(t *testing.T) {
+	// The token of a submission made with an API key only needs the path
+	// to the private key.
+	info, _, err := WaitForCompletion(context.Background(), ResumeToken{
+		RequestUUID:    "cfd69166-8e2f-1397-8636-ec06f98e3597",
+		APIKeyID:       "KEY",
+		APIKeyIssuerID: "issuer",
+	}, &Options{
+		Logger:     hclog.L(),
+		BaseCmd:    childCmd(t, "resume-api-key"),
+		Intervals:  testIntervals,
+		APIKeyPath: "AuthKey_KEY.p8",
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal(StatusAccepted, info.Status)
+}
+
+// testCmdResumeAPIKey mimicks an accepted submission that is only found
+// with the API key KEY of the issuer "issuer".
+func testCmdResumeAPIKey() int {
+	if !strings.Contains(strings.Join(os.Args, " "), "--key AuthKey_KEY.p8 --key-id KEY --issuer issuer") {
+		fmt.Fprintln(os.Stderr, "Error: HTTP status code: 401. Invalid credentials. Username or password is incorrect.")
+		return 1
+	}
+
+	return testCmdNotarizeAccepted()
+}
@@ -0,0 +1,108 @@
+package notarize
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+)
+
+// ErrUnsignedArtifact is returned by Notarize when a bundle or pkg file
+// isn't signed. Apple always rejects those, so this is checked before
+// uploading unless Options.SkipSignatureCheck is set.
+var ErrUnsignedArtifact = errors.New("file is not signed")
+
+// checkSignature returns an error matching ErrUnsignedArtifact if file
+// isn't signed. Bundles and dmg files are checked with `codesign -dv` and
+// pkg files with `pkgutil --check-signature`. Apple notarizes unsigned dmg
+// files as long as their contents are signed, so those are only warned
+// about. Zip archives themselves aren't signed, so they aren't checked.
+// If the tool fails for any other reason, such as a missing file, that
+// error is returned instead.
+func checkSignature(ctx context.Context, logger hclog.Logger, opts *Options, file string) error {
+	var tool []string
+	dmg := false
+	switch {
+	case isBundle(workdir.Path(opts.WorkDir, file)):
+		tool = []string{"codesign", "-dv"}
+
+	case strings.EqualFold(filepath.Ext(file), ".dmg"):
+		tool = []string{"codesign", "-dv"}
+		dmg = true
+
+	case strings.EqualFold(filepath.Ext(file), ".pkg"):
+		tool = []string{"pkgutil", "--check-signature"}
+
+	default:
+		logger.Debug("not checking signature of file", "file", file)
+		return nil
+	}
+
+	// Build our command
+	var cmd exec.Cmd
+	if opts.BaseCmd != nil {
+		cmd = *opts.BaseCmd
+	}
+
+	// We only set the path if it isn't set. This lets the options set the
+	// path to the xcrun binary that we use.
+	if cmd.Path == "" {
+		path, err := exec.LookPath("xcrun")
+		if err != nil {
+			return err
+		}
+
+		cmd = *(exec.CommandContext(ctx, path))
+	}
+
+	cmd.Args = append([]string{filepath.Base(cmd.Path)}, tool...)
+	cmd.Args = append(cmd.Args, file)
+	workdir.Apply(&cmd, opts.WorkDir)
+
+	// We store all output in out for logging and in case there is an error
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	// Log what we're going to execute
+	logger.Info("checking file is signed",
+		"file", file,
+		"command_path", cmd.Path,
+		"command_args", cmd.Args,
+	)
+
+	if err := cmd.Run(); err != nil {
+		// Anything but the tool reporting a missing signature, such as a
+		// missing file or tool, is an error checking the signature.
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || !notSigned(out.String()) {
+			logger.Error("error checking file is signed", "file", file, "err", err, "output", out.String())
+			return fmt.Errorf("error checking signature of %s: %w\n\n%s", file, err, out.String())
+		}
+
+		if dmg {
+			logger.Warn("disk image is not signed, it is only accepted if its contents are signed",
+				"file", file, "output", out.String())
+			return nil
+		}
+
+		logger.Error("file is not signed", "file", file, "output", out.String())
+		return fmt.Errorf("%w: %s\n\n%s", ErrUnsignedArtifact, file, out.String())
+	}
+
+	return nil
+}
+
+// notSigned returns true if the output of codesign or pkgutil reports that
+// the file has no signature.
+func notSigned(output string) bool {
+	return strings.Contains(output, "is not signed at all") ||
+		strings.Contains(output, "Status: no signature")
+}
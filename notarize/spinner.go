@@ -0,0 +1,120 @@
+package notarize
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// spinnerFrames are the frames rendered in order by SpinnerStatus.
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// SpinnerStatus implements Status by rendering a single line with a
+// spinner, the current phase, and the elapsed time. The line is redrawn
+// on each callback and cleared once the log reaches a terminal state.
+//
+// If the writer isn't a terminal, each phase is instead written once on
+// its own line so the output stays readable in CI logs.
+type SpinnerStatus struct {
+	w     io.Writer
+	tty   bool
+	start time.Time
+
+	lock      sync.Mutex
+	frame     int
+	lastPhase string
+}
+
+// NewSpinnerStatus returns a SpinnerStatus that renders to w, which is
+// typically os.Stderr. The elapsed time is measured from this call.
+func NewSpinnerStatus(w io.Writer) *SpinnerStatus {
+	tty := false
+	if f, ok := w.(*os.File); ok {
+		tty = isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+	}
+
+	return &SpinnerStatus{w: w, tty: tty, start: time.Now()}
+}
+
+func (s *SpinnerStatus) Submitting() {
+	s.render("Submitting file for notarization")
+}
+
+func (s *SpinnerStatus) Submitted(uuid string) {
+	s.render("Waiting for results from Apple, request UUID: " + uuid)
+}
+
+func (s *SpinnerStatus) QueueCleared(uuid string, queueWait time.Duration) {
+	s.render(fmt.Sprintf("Analyzing after %s in Apple's queue", queueWait.Round(time.Second)))
+}
+
+func (s *SpinnerStatus) InfoStatus(info Info) {
+	s.render("InfoStatus: " + info.Status.String())
+}
+
+func (s *SpinnerStatus) LogStatus(log Log) {
+	phase := "LogStatus: " + log.Status.String()
+	if !log.Status.Terminal() {
+		s.render(phase)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.tty {
+		fmt.Fprint(s.w, "\r\033[K")
+	}
+	fmt.Fprintf(s.w, "%s (%s)\n", phase, s.elapsed())
+}
+
+// Waiting redraws the current phase on terminals so that the spinner and
+// elapsed time keep moving while nothing else changes.
+func (s *SpinnerStatus) Waiting(time.Duration, int) {
+	s.lock.Lock()
+	phase := s.lastPhase
+	s.lock.Unlock()
+
+	if s.tty && phase != "" {
+		s.render(phase)
+	}
+}
+
+func (s *SpinnerStatus) RateLimited(uuid string, wait time.Duration) {
+	s.render(fmt.Sprintf("Rate limited by Apple, retrying in %s", wait.Round(time.Second)))
+}
+
+func (s *SpinnerStatus) LargeUpload(size int64) {
+	s.render(fmt.Sprintf("Uploading large file (%.1f MB), this may take a while", float64(size)/1e6))
+}
+
+// render draws the given phase, replacing the current line on terminals.
+func (s *SpinnerStatus) render(phase string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.tty {
+		if phase != s.lastPhase {
+			s.lastPhase = phase
+			fmt.Fprintf(s.w, "%s (%s)\n", phase, s.elapsed())
+		}
+
+		return
+	}
+
+	s.lastPhase = phase
+	frame := spinnerFrames[s.frame%len(spinnerFrames)]
+	s.frame++
+	fmt.Fprintf(s.w, "\r\033[K%s %s (%s)", frame, phase, s.elapsed())
+}
+
+// elapsed returns the time since the spinner was created.
+func (s *SpinnerStatus) elapsed() time.Duration {
+	return time.Since(s.start).Round(time.Second)
+}
+
+var _ Status = (*SpinnerStatus)(nil)
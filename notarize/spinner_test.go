@@ -0,0 +1,29 @@
+package notarize
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpinnerStatus_plain(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewSpinnerStatus(&buf)
+	s.Submitting()
+	s.Submitted("foo")
+	s.InfoStatus(Info{Status: "In Progress"})
+	s.InfoStatus(Info{Status: "In Progress"})
+	s.InfoStatus(Info{Status: "Accepted"})
+	s.LogStatus(Log{Status: "Accepted"})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	req := require.New(t)
+	req.Len(lines, 5)
+	req.True(strings.HasPrefix(lines[1], "Waiting for results from Apple, request UUID: foo ("))
+	req.True(strings.HasPrefix(lines[2], "InfoStatus: In Progress ("))
+	req.True(strings.HasPrefix(lines[4], "LogStatus: Accepted ("))
+	req.NotContains(buf.String(), "\r")
+}
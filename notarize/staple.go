@@ -0,0 +1,47 @@
+package notarize
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/staple"
+)
+
+// stapleFile staples the ticket of the notarized opts.File for
+// Options.Staple.
+func stapleFile(ctx context.Context, logger hclog.Logger, opts *Options) error {
+	return staple.Staple(ctx, stapleOptions(logger, opts, opts.File, "staple"))
+}
+
+// stapleOptions returns the options to run the stapler action, "staple"
+// or "validate", on file.
+func stapleOptions(logger hclog.Logger, opts *Options, file, action string) *staple.Options {
+	stapleOpts := &staple.Options{
+		File:    file,
+		WorkDir: opts.WorkDir,
+		Logger:  logger.Named("staple"),
+	}
+
+	// The stapler uses BaseCmd as is, so we set the arguments here the
+	// same way as for our other tools.
+	if opts.BaseCmd != nil && opts.BaseCmd.Path != "" {
+		cmd := *opts.BaseCmd
+		cmd.Args = []string{filepath.Base(cmd.Path), "stapler", action, file}
+		stapleOpts.BaseCmd = &cmd
+	}
+
+	return stapleOpts
+}
+
+// validateStaple returns an error if Options.Staple is set for a file that
+// can't be stapled, so that this is reported before submitting.
+func validateStaple(opts *Options) error {
+	if !opts.Staple || canStaple(opts.File) {
+		return nil
+	}
+
+	return fmt.Errorf("%s can't be stapled, only app bundles, dmg, and pkg files support stapling", opts.File)
+}
@@ -0,0 +1,101 @@
+package notarize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	childCommands["staple-accepted"] = testCmdStapleAccepted
+	childCommands["staple-failed"] = testCmdStapleFailed
+}
+
+func TestNotarize_staple(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.dmg")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+
+	info, _, err := Notarize(context.Background(), &Options{
+		File:      file,
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "staple-accepted"),
+		Intervals: testIntervals,
+		Staple:    true,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal(StatusAccepted, info.Status)
+
+	data, err := os.ReadFile(file)
+	req.NoError(err)
+	req.Equal("hello+ticket", string(data))
+}
+
+func TestNotarize_stapleFailed(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.dmg")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+
+	info, _, err := Notarize(context.Background(), &Options{
+		File:      file,
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "staple-failed"),
+		Intervals: testIntervals,
+		Staple:    true,
+	})
+
+	req := require.New(t)
+	req.ErrorContains(err, "CloudKit query failed")
+	req.Equal(StatusAccepted, info.Status)
+}
+
+func TestNotarize_stapleUnsupported(t *testing.T) {
+	_, _, err := Notarize(context.Background(), &Options{
+		File:      "gon.zip",
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+		Staple:    true,
+	})
+	require.ErrorContains(t, err, "can't be stapled")
+}
+
+// testCmdStapleAccepted mimicks an accepted submission whose ticket is
+// stapled by appending to the file.
+func testCmdStapleAccepted() int {
+	if len(os.Args) > 3 && os.Args[1] == "stapler" && os.Args[2] == "staple" {
+		f, err := os.OpenFile(os.Args[3], os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+
+		if _, err := f.WriteString("+ticket"); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		fmt.Println("The staple and validate action worked!")
+		return 0
+	}
+
+	return testCmdNotarizeAccepted()
+}
+
+// testCmdStapleFailed mimicks an accepted submission whose ticket can't be
+// stapled.
+func testCmdStapleFailed() int {
+	if len(os.Args) > 1 && os.Args[1] == "stapler" {
+		fmt.Fprintln(os.Stderr, "CloudKit query failed due to \"Record not found\".")
+		fmt.Fprintln(os.Stderr, "The staple and validate action failed! Error 65.")
+		return 65
+	}
+
+	return testCmdNotarizeAccepted()
+}
@@ -0,0 +1,216 @@
+package notarize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+)
+
+// savedState is the JSON document written to Options.StatePath.
+type savedState struct {
+	// File is the file that was submitted, as set in Options.File.
+	File string `json:"file"`
+
+	// SHA256 is the hex-encoded SHA-256 of the file, or Options.ContentHash
+	// if it is set. This is empty for files that can't be hashed, such as
+	// app bundles.
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Info is the latest known info of the submission.
+	Info *Info `json:"info"`
+
+	// Updated is when the state was written, in UTC.
+	Updated time.Time `json:"updated"`
+}
+
+// stateStatus implements Status by writing the state of the submission to
+// Options.StatePath whenever it changes and forwarding every callback to
+// the wrapped Status.
+type stateStatus struct {
+	Status
+
+	opts   *Options
+	logger hclog.Logger
+
+	hash     string
+	hashed   bool
+	lastInfo SubmissionStatus
+}
+
+func (s *stateStatus) Submitted(uuid string) {
+	s.Status.Submitted(uuid)
+	s.lastInfo = ""
+	s.save(&Info{RequestUUID: uuid})
+}
+
+func (s *stateStatus) InfoStatus(info Info) {
+	s.Status.InfoStatus(info)
+	if info.Status != s.lastInfo {
+		s.lastInfo = info.Status
+		s.save(&info)
+	}
+}
+
+// save writes info to the state file. Errors are only logged since losing
+// the state only means that a restart submits again.
+func (s *stateStatus) save(info *Info) {
+	if !s.hashed {
+		s.hash = stateHash(s.logger, s.opts)
+		s.hashed = true
+	}
+
+	if err := writeState(s.opts, s.hash, info); err != nil {
+		s.logger.Warn("error writing notarization state", "path", s.opts.StatePath, "err", err)
+	}
+}
+
+// stateHash returns the hash of the file of opts to record in the state.
+func stateHash(logger hclog.Logger, opts *Options) string {
+	if opts.ContentHash != "" {
+		return opts.ContentHash
+	}
+
+	path := workdir.Path(opts.WorkDir, opts.File)
+	hash, err := fileSHA256(path)
+	if err != nil {
+		logger.Debug("not hashing file for notarization state", "file", path, "err", err)
+		return ""
+	}
+
+	return hash
+}
+
+// writeState writes info to opts.StatePath. The state is written to a
+// temporary file first so that a process killed mid-write doesn't leave a
+// truncated state behind.
+func writeState(opts *Options, hash string, info *Info) error {
+	data, err := json.MarshalIndent(savedState{
+		File:    opts.File,
+		SHA256:  hash,
+		Info:    info,
+		Updated: time.Now().UTC(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding notarization state: %w", err)
+	}
+
+	path := workdir.Path(opts.WorkDir, opts.StatePath)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing notarization state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error writing notarization state: %w", err)
+	}
+
+	return nil
+}
+
+// removeState removes the state file once the submission was processed,
+// since there is nothing left to resume.
+func removeState(logger hclog.Logger, opts *Options) {
+	path := workdir.Path(opts.WorkDir, opts.StatePath)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logger.Warn("error removing notarization state", "path", path, "err", err)
+	}
+}
+
+// resumeState returns the UUID of the submission recorded in the state
+// file of opts if it can be resumed, and whether it already left Apple's
+// queue. A submission can be resumed if the state is for the same file
+// with the same contents and Apple still knows about it. Files that can't
+// be hashed, such as bundles, are only resumed if Options.ContentHash is
+// set. Any problem with
+// the state is only logged since the file can always be submitted again.
+func resumeState(ctx context.Context, logger hclog.Logger, opts *Options) (string, bool) {
+	path := workdir.Path(opts.WorkDir, opts.StatePath)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warn("error reading notarization state, will submit", "path", path, "err", err)
+		}
+		return "", false
+	}
+
+	var state savedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		logger.Warn("error decoding notarization state, will submit", "path", path, "err", err)
+		return "", false
+	}
+	if state.Info == nil || state.Info.RequestUUID == "" {
+		return "", false
+	}
+
+	// Files that can't be hashed, such as bundles, may have been rebuilt
+	// since the state was written, so they are only resumed with an
+	// explicit ContentHash.
+	uuid := state.Info.RequestUUID
+	hash := stateHash(logger, opts)
+	if hash == "" {
+		logger.Info("file can't be hashed to match the notarization state, will submit",
+			"path", path, "uuid", uuid, "file", opts.File)
+		return "", false
+	}
+	if state.File != opts.File || state.SHA256 != hash {
+		logger.Info("notarization state is for a different file, will submit",
+			"path", path, "uuid", uuid, "file", state.File)
+		return "", false
+	}
+
+	// Make sure Apple still knows the submission before we skip the
+	// upload. While it is queued the UUID isn't found, but the UUID came
+	// from a successful submission so we keep waiting on it.
+	queued := opts.QueuedPredicate
+	if queued == nil {
+		queued = isQueuedError
+	}
+	_, err = info(ctx, uuid, opts)
+	if err != nil && !queued(err) {
+		logger.Warn("submission in notarization state can't be resumed, will submit",
+			"path", path, "uuid", uuid, "err", err)
+		return "", false
+	}
+
+	logger.Info("resuming submission from notarization state",
+		"path", path, "uuid", uuid, "status", state.Info.Status)
+	return uuid, err == nil
+}
+
+// resumeSubmission returns a poller for the submission recorded in the
+// state file of opts once it left Apple's queue, or nil if there is no
+// submission to resume. If waiting on the queue fails, the poller is
+// returned along with the error.
+func resumeSubmission(ctx context.Context, logger hclog.Logger, opts *Options, status Status, intervals Intervals, attempts *[]Attempt) (*poller, error) {
+	uuid, cleared := resumeState(ctx, logger, opts)
+	if uuid == "" {
+		return nil, nil
+	}
+
+	p := &poller{
+		opts:      opts,
+		uuid:      uuid,
+		logger:    logger,
+		status:    status,
+		intervals: intervals,
+		attempts:  attempts,
+	}
+
+	queueStart := time.Now()
+	if !cleared {
+		if err := p.waitQueue(ctx); err != nil {
+			return p, err
+		}
+	}
+
+	p.queueCleared = time.Now()
+	status.QueueCleared(uuid, p.queueCleared.Sub(queueStart))
+	logger.Debug("queue cleared", "uuid", uuid, "queue_wait", p.queueCleared.Sub(queueStart))
+	return p, nil
+}
@@ -0,0 +1,244 @@
+package notarize
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	childCommands["notarize-resume"] = testCmdNotarizeResume
+	childCommands["notarize-resume-queued"] = testCmdNotarizeResumeQueued
+}
+
+func TestNotarize_state(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.dmg")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+	statePath := filepath.Join(dir, "state.json")
+
+	status := &stateCheckStatus{path: statePath}
+	_, _, err := Notarize(context.Background(), &Options{
+		File:      file,
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+		Status:    status,
+		StatePath: statePath,
+	})
+
+	req := require.New(t)
+	req.NoError(err)
+	req.NotNil(status.state)
+	req.Equal(file, status.state.File)
+	req.Equal("2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", status.state.SHA256)
+	req.Equal("cfd69166-8e2f-1397-8636-ec06f98e3597", status.state.Info.RequestUUID)
+	req.NoFileExists(statePath)
+}
+
+func TestNotarize_stateResume(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.dmg")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+
+	opts := &Options{
+		File:      file,
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-resume"),
+		Intervals: testIntervals,
+		StatePath: filepath.Join(dir, "state.json"),
+	}
+	require.NoError(t, writeState(opts, stateHash(hclog.L(), opts), &Info{
+		RequestUUID: "cfd69166-8e2f-1397-8636-ec06f98e3597",
+		Status:      StatusInProgress,
+	}))
+
+	status := &testStatus{}
+	opts.Status = status
+	info, log, err := Notarize(context.Background(), opts)
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal(StatusAccepted, info.Status)
+	req.Equal(StatusAccepted, log.Status)
+	req.NotContains(status.Events, "Submitting")
+	req.Contains(status.Events, "QueueCleared")
+	req.NoFileExists(opts.StatePath)
+}
+
+func TestNotarize_stateResumeQueued(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.dmg")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+
+	cmd := childCmd(t, "notarize-resume-queued")
+	cmd.Env = append(cmd.Env, childEnv+"_MARKER="+filepath.Join(dir, "marker"))
+	opts := &Options{
+		File:      file,
+		Logger:    hclog.L(),
+		BaseCmd:   cmd,
+		Intervals: testIntervals,
+		StatePath: filepath.Join(dir, "state.json"),
+	}
+	require.NoError(t, writeState(opts, stateHash(hclog.L(), opts), &Info{
+		RequestUUID: "cfd69166-8e2f-1397-8636-ec06f98e3597",
+	}))
+
+	// The submission is still queued when we resume, which must not be
+	// mistaken for a submission that doesn't exist.
+	status := &testStatus{}
+	opts.Status = status
+	info, _, err := Notarize(context.Background(), opts)
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal(StatusAccepted, info.Status)
+	req.NotContains(status.Events, "Submitting")
+	req.FileExists(filepath.Join(dir, "marker"))
+}
+
+func TestNotarizeGlob_statePath(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.dmg", "b.dmg"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0644))
+	}
+
+	// The submissions never complete, so their state is left behind.
+	statePath := filepath.Join(dir, "state.json")
+	_, err := NotarizeGlob(context.Background(), filepath.Join(dir, "*.dmg"), &Options{
+		Logger:          hclog.L(),
+		BaseCmd:         childCmd(t, "notarize-in-progress"),
+		Intervals:       testIntervals,
+		MaxPollAttempts: 2,
+		StatePath:       statePath,
+	})
+	require.ErrorIs(t, err, ErrMaxPollAttempts)
+
+	paths, err := filepath.Glob(statePath + ".*")
+	require.NoError(t, err)
+	require.Len(t, paths, 2)
+	require.NoFileExists(t, statePath)
+
+	var files []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		var state savedState
+		require.NoError(t, json.Unmarshal(data, &state))
+		files = append(files, filepath.Base(state.File))
+	}
+	require.ElementsMatch(t, []string{"a.dmg", "b.dmg"}, files)
+}
+
+func TestNotarize_stateRebuiltBundle(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "Foo.app")
+	require.NoError(t, os.MkdirAll(filepath.Join(bundle, "Contents"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(bundle, "Contents", "Info.plist"), nil, 0644))
+	statePath := filepath.Join(dir, "state.json")
+
+	// The submission never completes, so its state is left behind.
+	_, _, err := Notarize(context.Background(), &Options{
+		File:               bundle,
+		Logger:             hclog.L(),
+		BaseCmd:            childCmd(t, "notarize-in-progress"),
+		Intervals:          testIntervals,
+		MaxPollAttempts:    2,
+		SkipSignatureCheck: true,
+		StatePath:          statePath,
+	})
+	require.ErrorIs(t, err, ErrMaxPollAttempts)
+	require.FileExists(t, statePath)
+
+	// The bundle is rebuilt, which the state can't tell, so it is submitted
+	// again rather than resuming the submission of the old build.
+	require.NoError(t, os.WriteFile(filepath.Join(bundle, "Contents", "Info.plist"), []byte("new"), 0644))
+
+	status := &testStatus{}
+	_, _, err = Notarize(context.Background(), &Options{
+		File:               bundle,
+		Logger:             hclog.L(),
+		BaseCmd:            childCmd(t, "notarize-accepted"),
+		Intervals:          testIntervals,
+		SkipSignatureCheck: true,
+		Status:             status,
+		StatePath:          statePath,
+	})
+	require.NoError(t, err)
+	require.Contains(t, status.Events, "Submitting")
+}
+
+func TestNotarize_stateDifferentFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.dmg")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+
+	opts := &Options{
+		File:      file,
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+		StatePath: filepath.Join(dir, "state.json"),
+	}
+	require.NoError(t, writeState(opts, "previous-build", &Info{
+		RequestUUID: "previous-build",
+		Status:      StatusInProgress,
+	}))
+
+	status := &testStatus{}
+	opts.Status = status
+	info, _, err := Notarize(context.Background(), opts)
+	require.NoError(t, err)
+	require.NotEqual(t, "previous-build", info.RequestUUID)
+	require.Contains(t, status.Events, "Submitting")
+}
+
+// stateCheckStatus records the state file once the queue cleared, after
+// the state was written for the submission.
+type stateCheckStatus struct {
+	testStatus
+
+	path  string
+	state *savedState
+}
+
+func (s *stateCheckStatus) QueueCleared(uuid string, d time.Duration) {
+	s.testStatus.QueueCleared(uuid, d)
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return
+	}
+
+	var state savedState
+	if json.Unmarshal(data, &state) == nil {
+		s.state = &state
+	}
+}
+
+// testCmdNotarizeResumeQueued mimicks a submission that is still queued
+// when it is resumed and fails if the file is submitted again.
+func testCmdNotarizeResumeQueued() int {
+	if len(os.Args) > 2 && os.Args[2] == "submit" {
+		return 1
+	}
+
+	return testCmdNotarizeQueued()
+}
+
+// testCmdNotarizeResume mimicks an accepted submission that fails if the
+// file is submitted again.
+func testCmdNotarizeResume() int {
+	if len(os.Args) > 2 && os.Args[2] == "submit" {
+		return 1
+	}
+
+	return testCmdNotarizeAccepted()
+}
@@ -1,9 +1,15 @@
 package notarize
 
+import "time"
+
 // Status is an interface that can be implemented to receive status callbacks.
 //
 // All the methods in this interface must NOT block for too long or it'll
 // block the notarization process.
+//
+// Methods may be added to this interface in minor releases, such as
+// Waiting, which breaks implementations outside of this package until they
+// add the method.
 type Status interface {
 	// Submitting is called when the file is being submitted for notarization.
 	Submitting()
@@ -12,6 +18,11 @@ type Status interface {
 	// The arguments give you access to the requestUUID to query more information.
 	Submitted(requestUUID string)
 
+	// QueueCleared is called once the submission has left Apple's queue and
+	// is being analyzed. queueWait is how long the submission was queued,
+	// which may be zero. This is called exactly once per submission.
+	QueueCleared(requestUUID string, queueWait time.Duration)
+
 	// InfoStatus is called as the status of the submitted package changes.
 	// The info argument contains additional information about the status.
 	// Note that some fields in the info argument may not be populated, please
@@ -20,15 +31,35 @@ type Status interface {
 
 	// LogStatus is called as the status of the submitted package changes.
 	LogStatus(Log)
+
+	// Waiting is called before each request made while waiting on the
+	// submission, including while it is in Apple's queue when no other
+	// callback is called for a long time. elapsed is the time since
+	// waiting started and attempt is the number of the request, starting
+	// at 1. This is useful to render progress or report a heartbeat.
+	Waiting(elapsed time.Duration, attempt int)
+
+	// RateLimited is called when Apple rejected a request because of rate
+	// limiting, before waiting for wait to retry it. Many concurrent
+	// notarizations with the same account can cause this.
+	RateLimited(requestUUID string, wait time.Duration)
+
+	// LargeUpload is called before uploading a file whose size in bytes
+	// exceeds Options.WarnUploadSize, since the upload may take a while.
+	LargeUpload(size int64)
 }
 
 // noopStatus implements Status and does nothing.
 type noopStatus struct{}
 
-func (noopStatus) Submitting()      {}
-func (noopStatus) Submitted(string) {}
-func (noopStatus) InfoStatus(Info)  {}
-func (noopStatus) LogStatus(Log)    {}
+func (noopStatus) Submitting()                        {}
+func (noopStatus) Submitted(string)                   {}
+func (noopStatus) QueueCleared(string, time.Duration) {}
+func (noopStatus) InfoStatus(Info)                    {}
+func (noopStatus) LogStatus(Log)                      {}
+func (noopStatus) Waiting(time.Duration, int)         {}
+func (noopStatus) RateLimited(string, time.Duration)  {}
+func (noopStatus) LargeUpload(int64)                  {}
 
 // Assert that we always implement it
 var _ Status = noopStatus{}
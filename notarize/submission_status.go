@@ -0,0 +1,49 @@
+package notarize
+
+// SubmissionStatus is the status of a submission as reported by Apple.
+// Statuses that gon doesn't know are kept as-is, so they round-trip and
+// can be checked with Known.
+type SubmissionStatus string
+
+const (
+	// StatusInProgress means Apple is still processing the submission.
+	StatusInProgress SubmissionStatus = "In Progress"
+
+	// StatusAccepted means the submission was notarized.
+	StatusAccepted SubmissionStatus = "Accepted"
+
+	// StatusInvalid means the submission didn't pass notarization. The
+	// log contains the issues that were found.
+	StatusInvalid SubmissionStatus = "Invalid"
+
+	// StatusRejected means Apple refused to process the submission, for
+	// example because of the account rather than the contents.
+	StatusRejected SubmissionStatus = "Rejected"
+
+	// StatusDryRun is never reported by Apple. It is the synthetic status
+	// of the Info returned by Notarize when Options.DryRun is set. It isn't
+	// Known, so a server reporting it is treated like any unknown status.
+	StatusDryRun SubmissionStatus = "Dry Run"
+)
+
+// Known returns true if s is one of the statuses defined above that Apple
+// reports, which excludes StatusDryRun.
+func (s SubmissionStatus) Known() bool {
+	switch s {
+	case StatusInProgress, StatusAccepted, StatusInvalid, StatusRejected:
+		return true
+	default:
+		return false
+	}
+}
+
+// Terminal returns true if the status won't change anymore. Unknown
+// statuses aren't terminal.
+func (s SubmissionStatus) Terminal() bool {
+	return s.Known() && s != StatusInProgress
+}
+
+// String implements fmt.Stringer
+func (s SubmissionStatus) String() string {
+	return string(s)
+}
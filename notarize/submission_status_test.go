@@ -0,0 +1,33 @@
+package notarize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"howett.net/plist"
+)
+
+func TestSubmissionStatus(t *testing.T) {
+	req := require.New(t)
+	req.True(StatusAccepted.Terminal())
+	req.True(StatusRejected.Terminal())
+	req.False(StatusInProgress.Terminal())
+	req.True(StatusInProgress.Known())
+	req.False(StatusDryRun.Known())
+
+	unknown := SubmissionStatus("Pending Review")
+	req.False(unknown.Known())
+	req.False(unknown.Terminal())
+	req.Equal("Pending Review", unknown.String())
+}
+
+func TestSubmissionStatus_roundTrip(t *testing.T) {
+	// Unknown statuses are kept as-is when decoded and encoded again.
+	data, err := plist.Marshal(&Info{Status: "Pending Review"}, plist.XMLFormat)
+	require.NoError(t, err)
+
+	var result Info
+	_, err = plist.Unmarshal(data, &result)
+	require.NoError(t, err)
+	require.Equal(t, SubmissionStatus("Pending Review"), result.Status)
+}
@@ -0,0 +1,78 @@
+package notarize
+
+import (
+	"context"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+)
+
+// Submit submits opts.File for notarization and returns the request UUID
+// without waiting for Apple to process it. The file is checked and
+// prepared the same way as by Notarize. Use Wait with the UUID to wait on
+// the submission, which may happen in a different process.
+//
+// If notarytool failed or was interrupted after the submission was
+// created, its UUID is returned along with the error.
+//
+// Submitting many files before waiting on any of them lets Apple process
+// them concurrently. Unlike Notarize, a submission that is stuck in
+// Apple's queue isn't resubmitted since Submit doesn't wait on it.
+//
+// With Options.DryRun, the file and credentials are checked as by Notarize
+// but nothing is uploaded, and the UUID is empty.
+func Submit(ctx context.Context, opts *Options) (string, error) {
+	if opts.DryRun {
+		_, err := dryRun(ctx, opts)
+		return "", err
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	logger = withBuildID(ctx, logger)
+
+	status := newStatus(ctx, opts, logger)
+
+	if err := workdir.Validate(opts.WorkDir); err != nil {
+		return "", err
+	}
+
+	opts, err := resolveProvider(ctx, logger, opts)
+	if err != nil {
+		return "", err
+	}
+
+	uploadOpts, cleanup, err := prepareUpload(ctx, logger, opts)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	if err := checkUploadSize(logger, status, uploadOpts); err != nil {
+		return "", err
+	}
+
+	if opts.UploadLock != nil {
+		opts.UploadLock.Lock()
+		defer opts.UploadLock.Unlock()
+	}
+
+	status.Submitting()
+	uuid, err := uploadRetry(ctx, logger, uploadOpts, nil)
+	if err != nil {
+		return uuid, cancelled(ctx, err)
+	}
+	status.Submitted(uuid)
+
+	return uuid, nil
+}
+
+// Wait waits on the submission uuid made by Submit until Apple finished
+// processing it and returns its info and log like Notarize. This is
+// WaitForCompletion for a submission made with the credentials in opts.
+func Wait(ctx context.Context, uuid string, opts *Options) (*Info, *Log, error) {
+	return WaitForCompletion(ctx, newResumeToken(opts, uuid), opts)
+}
@@ -0,0 +1,39 @@
+package notarize
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmitWait(t *testing.T) {
+	status := &testStatus{}
+	opts := &Options{
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+		Status:    status,
+	}
+
+	uuid, err := Submit(context.Background(), opts)
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal("cfd69166-8e2f-1397-8636-ec06f98e3597", uuid)
+	req.Equal([]string{"Submitting", "Submitted"}, status.Events)
+
+	info, log, err := Wait(context.Background(), uuid, opts)
+	req.NoError(err)
+	req.Equal(StatusAccepted, info.Status)
+	req.Equal(StatusAccepted, log.Status)
+}
+
+func TestSubmit_unsigned(t *testing.T) {
+	_, err := Submit(context.Background(), &Options{
+		File:    "foo.pkg",
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "notarize-unsigned"),
+	})
+	require.ErrorContains(t, err, "not signed")
+}
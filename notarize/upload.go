@@ -3,65 +3,86 @@ package notarize
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"os/exec"
-	"path/filepath"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"howett.net/plist"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
 )
 
 // upload submits the file for notarization and returns the request UUID
-// or an error.
+// or an error. If notarytool reported the UUID before it failed, such as
+// when it was interrupted after uploading, the UUID is returned along with
+// the error since the submission exists at Apple.
 func upload(ctx context.Context, opts *Options) (string, error) {
 	logger := opts.Logger
 	if logger == nil {
 		logger = hclog.NewNullLogger()
 	}
+	logger = withBuildID(ctx, logger)
 
-	// Build our command
-	var cmd exec.Cmd
-	if opts.BaseCmd != nil {
-		cmd = *opts.BaseCmd
+	auth, password, err := authArgs(ctx, opts)
+	if err != nil {
+		return "", err
 	}
 
-	// We only set the path if it isn't set. This lets the options set the
-	// path to the codesigning binary that we use.
-	if cmd.Path == "" {
-		path, err := exec.LookPath("xcrun")
-		if err != nil {
-			return "", err
+	args := append([]string{"submit", opts.File}, auth...)
+	args = append(args, outputFormatArgs(opts)...)
+
+	// Prefer notarytool's own timeout since it can abort the upload
+	// cleanly. Older versions don't support it so we fall back to
+	// cancelling the command ourselves.
+	if opts.SubmitTimeout > 0 {
+		if submitSupportsTimeout(ctx, opts) {
+			logger.Info("using notarytool submit timeout", "timeout", opts.SubmitTimeout)
+			args = append(args, "--timeout", timeoutFlag(opts.SubmitTimeout))
+		} else {
+			logger.Info("notarytool doesn't support a submit timeout, using context timeout",
+				"timeout", opts.SubmitTimeout)
+
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, opts.SubmitTimeout)
+			defer cancel()
 		}
+	}
 
-		cmd = *(exec.CommandContext(
-			ctx, path,
-			filepath.Base(cmd.Path), "notarytool", "submit", opts.File,
-			"--apple-id", opts.DeveloperId, "--password", opts.Password, "--team-id", opts.Provider,
-			"--output-format", "plist",
-		))
+	// Build our command
+	cmd, err := notarytoolCmd(ctx, opts, args...)
+	if err != nil {
+		return "", err
 	}
 
+	workdir.Apply(cmd, opts.WorkDir)
+
 	// We store all output in out for logging and in case there is an error
 	var out, combined bytes.Buffer
-	cmd.Stdout = io.MultiWriter(&out, &combined)
-	cmd.Stderr = &combined
+	teeOutput(cmd, opts, io.MultiWriter(&out, &combined), &combined)
 
 	// Log what we're going to execute
 	logger.Info("submitting file for notarization",
 		"file", opts.File,
+		"bundle_id", opts.BundleID,
 		"command_path", cmd.Path,
 		"command_args", cmd.Args,
 	)
 
 	// Execute
-	err := cmd.Run()
+	err = cmd.Run()
 
 	// Log the result
 	logger.Info("notarization submission complete",
 		"output", out.String(),
 		"err", err,
 	)
+	writeArtifact(logger, opts, "submit.plist", out.Bytes(), password)
 
 	// If we have any output, try to decode that since even in the case of
 	// an error it will output some information.
@@ -74,7 +95,7 @@ func upload(ctx context.Context, opts *Options) (string, error) {
 
 	// Now we check the error for actually running the process
 	if err != nil {
-		return "", fmt.Errorf("error submitting for notarization:\n\n%s", combined.String())
+		return result.RequestUUID, commandFailed("error submitting for notarization", combined.String())
 	}
 
 	// We should have a request UUID set at this point since we checked for errors
@@ -90,8 +111,187 @@ func upload(ctx context.Context, opts *Options) (string, error) {
 
 }
 
+// serverWait waits with `notarytool wait` until Apple finished processing
+// the submission uuid. This is used for Options.ServerWait after the upload
+// so that the UploadLock isn't held while Apple processes the submission.
+// SubmitTimeout limits the wait like it limits the upload.
+func serverWait(ctx context.Context, opts *Options, uuid string) error {
+	logger := opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	logger = withBuildID(ctx, logger)
+
+	auth, password, err := authArgs(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	args := append([]string{"wait", uuid}, auth...)
+	args = append(args, outputFormatArgs(opts)...)
+	if opts.SubmitTimeout > 0 {
+		args = append(args, "--timeout", timeoutFlag(opts.SubmitTimeout))
+	}
+
+	// Build our command
+	cmd, err := notarytoolCmd(ctx, opts, args...)
+	if err != nil {
+		return err
+	}
+
+	workdir.Apply(cmd, opts.WorkDir)
+
+	// We store all output in out for logging and in case there is an error
+	var out, combined bytes.Buffer
+	teeOutput(cmd, opts, io.MultiWriter(&out, &combined), &combined)
+
+	// Log what we're going to execute
+	logger.Info("waiting for notarization on the server",
+		"uuid", uuid,
+		"command_path", cmd.Path,
+		"command_args", cmd.Args,
+	)
+
+	// Execute
+	err = cmd.Run()
+
+	// Log the result
+	logger.Info("notarization wait complete",
+		"output", out.String(),
+		"err", err,
+	)
+	writeArtifact(logger, opts, "wait-"+uuid+".plist", out.Bytes(), password)
+
+	if err != nil {
+		return commandFailed("error waiting for notarization", combined.String())
+	}
+
+	return nil
+}
+
+// ErrEmptyUpload is returned when the file to upload is empty, which
+// notarytool would otherwise reject with a confusing error.
+var ErrEmptyUpload = errors.New("file to upload is empty")
+
+// checkUploadSize checks the size of the file to upload before the upload
+// starts. Empty files return ErrEmptyUpload and files larger than
+// Options.WarnUploadSize are reported to status.
+func checkUploadSize(logger hclog.Logger, status Status, opts *Options) error {
+	path := workdir.Path(opts.WorkDir, opts.File)
+	fi, err := os.Stat(path)
+	if err != nil {
+		// notarytool reports a missing file itself, so this is only a
+		// preflight check.
+		logger.Debug("not checking size of file to upload", "file", path, "err", err)
+		return nil
+	}
+
+	size := fi.Size()
+	if size == 0 {
+		return fmt.Errorf("%w: %s", ErrEmptyUpload, path)
+	}
+
+	if opts.WarnUploadSize > 0 && size > opts.WarnUploadSize {
+		logger.Warn("file to upload is large, uploading may take a while",
+			"file", path, "size", size, "warn_size", opts.WarnUploadSize)
+		status.LargeUpload(size)
+	}
+
+	return nil
+}
+
+// uploadRetry uploads the file with uploadFunc, retrying transient network
+// failures up to Options.UploadRetries times. The wait between retries
+// starts at the NetworkRetry interval and doubles each time.
+func uploadRetry(ctx context.Context, logger hclog.Logger, opts *Options, attempts *[]Attempt) (string, error) {
+	retries := opts.UploadRetries
+	if retries == 0 {
+		retries = defaultUploadRetries
+	}
+
+	wait := opts.Intervals.withDefaults().NetworkRetry
+	for retry := 0; ; retry++ {
+		uuid, err := uploadFunc(ctx, opts)
+		// A UUID means that the submission exists, so it isn't retried.
+		if err == nil || uuid != "" || retry >= retries || ctx.Err() != nil || !isTransientUploadError(err) {
+			return uuid, err
+		}
+
+		logger.Warn("transient error submitting file, will retry",
+			"file", opts.File,
+			"retry", retry+1,
+			"error_code", errorCode(err),
+			"wait_duration", wait,
+			"err", err)
+		recordAttempt(attempts, "", PhaseSubmit, err, AttemptRetried)
+		if err := sleep(ctx, wait); err != nil {
+			return "", err
+		}
+		wait *= 2
+	}
+}
+
+// defaultUploadRetries is the default for Options.UploadRetries.
+const defaultUploadRetries = 3
+
+// transientUploadErrors are substrings of the output of notarytool submit
+// for network failures that are worth retrying.
+var transientUploadErrors = []string{
+	strconv.Itoa(codeNetworkUnavailable),
+	"The network connection was lost",
+	"The request timed out",
+	"connection reset by peer",
+}
+
+// isTransientUploadError returns true if err is a network failure rather
+// than a problem with the credentials or the file. The upload error only
+// has the output of notarytool, so this matches on that.
+func isTransientUploadError(err error) bool {
+	if isAuthError(err) {
+		return false
+	}
+
+	msg := err.Error()
+	for _, s := range transientUploadErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // uploadResult is the plist structure when the upload succeeds
 type uploadResult struct {
 	// Upload is non-nil if there is a successful upload
 	RequestUUID string `plist:"id"`
 }
+
+// submitSupportsTimeout returns true if notarytool supports the --timeout
+// flag for submit. Any error checking for support is treated as no support.
+func submitSupportsTimeout(ctx context.Context, opts *Options) bool {
+	cmd, err := notarytoolCmd(ctx, opts, "submit", "--help")
+	if err != nil {
+		return false
+	}
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return false
+	}
+
+	return strings.Contains(out.String(), "--timeout")
+}
+
+// timeoutFlag formats d as a value for notarytool's --timeout flag, which
+// accepts a number of seconds. This is rounded up to at least one second.
+func timeoutFlag(d time.Duration) string {
+	secs := int64(math.Ceil(d.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+
+	return strconv.FormatInt(secs, 10)
+}
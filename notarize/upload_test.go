@@ -3,8 +3,11 @@ package notarize
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/require"
@@ -13,6 +16,8 @@ import (
 func init() {
 	childCommands["upload-success"] = testCmdUploadSuccess
 	childCommands["upload-exit-status"] = testCmdUploadExitStatus
+	childCommands["upload-timeout"] = testCmdUploadTimeout
+	childCommands["upload-hang"] = testCmdUploadHang
 }
 
 func TestUpload_success(t *testing.T) {
@@ -35,6 +40,84 @@ func TestUpload_exitStatus(t *testing.T) {
 	require.Empty(t, uuid)
 }
 
+func TestUpload_submitTimeout(t *testing.T) {
+	uuid, err := upload(context.Background(), &Options{
+		Logger:        hclog.L(),
+		BaseCmd:       childCmd(t, "upload-timeout"),
+		SubmitTimeout: 90 * time.Second,
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, uuid, "cfd69166-8e2f-1397-8636-ec06f98e3597")
+}
+
+func TestUpload_submitTimeoutFallback(t *testing.T) {
+	// The help output of this child doesn't mention --timeout, so the
+	// timeout is enforced with the context instead, which kills the
+	// hanging upload.
+	start := time.Now()
+	uuid, err := upload(context.Background(), &Options{
+		Logger:        hclog.L(),
+		BaseCmd:       childCmd(t, "upload-hang"),
+		SubmitTimeout: 100 * time.Millisecond,
+	})
+
+	require.ErrorContains(t, err, "error submitting for notarization")
+	require.Empty(t, uuid)
+	require.Less(t, time.Since(start), 30*time.Second)
+}
+
+func TestNotarize_emptyUpload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.dmg")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+
+	status := &testStatus{}
+	_, _, err := Notarize(context.Background(), &Options{
+		File:      file,
+		Logger:    hclog.L(),
+		BaseCmd:   childCmd(t, "notarize-accepted"),
+		Intervals: testIntervals,
+		Status:    status,
+	})
+	require.ErrorIs(t, err, ErrEmptyUpload)
+	require.NotContains(t, status.Events, "Submitting")
+}
+
+func TestNotarize_largeUpload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.dmg")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+
+	status := &testStatus{}
+	_, _, err := Notarize(context.Background(), &Options{
+		File:           file,
+		Logger:         hclog.L(),
+		BaseCmd:        childCmd(t, "notarize-accepted"),
+		Intervals:      testIntervals,
+		Status:         status,
+		WarnUploadSize: 4,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"LargeUpload", "Submitting"}, status.Events[:2])
+
+	status = &testStatus{}
+	_, _, err = Notarize(context.Background(), &Options{
+		File:           file,
+		Logger:         hclog.L(),
+		BaseCmd:        childCmd(t, "notarize-accepted"),
+		Intervals:      testIntervals,
+		Status:         status,
+		WarnUploadSize: 5,
+	})
+	require.NoError(t, err)
+	require.NotContains(t, status.Events, "LargeUpload")
+}
+
+func TestTimeoutFlag(t *testing.T) {
+	require.Equal(t, "90", timeoutFlag(90*time.Second))
+	require.Equal(t, "2", timeoutFlag(1500*time.Millisecond))
+	require.Equal(t, "1", timeoutFlag(time.Millisecond))
+}
+
 // testCmdUploadSuccess mimicks a successful submission.
 func testCmdUploadSuccess() int {
 	fmt.Println(strings.TrimSpace(`
@@ -58,3 +141,31 @@ func testCmdUploadSuccess() int {
 func testCmdUploadExitStatus() int {
 	return 1
 }
+
+// testCmdUploadTimeout mimicks a notarytool that supports --timeout and
+// only succeeds if the flag is passed.
+func testCmdUploadTimeout() int {
+	args := strings.Join(os.Args, " ")
+	if strings.Contains(args, "--help") {
+		fmt.Println("  --timeout <timeout>     Timeout for the submission.")
+		return 0
+	}
+
+	if !strings.Contains(args, "--timeout 90") {
+		fmt.Fprintln(os.Stderr, "expected --timeout flag")
+		return 1
+	}
+
+	return testCmdUploadSuccess()
+}
+
+// testCmdUploadHang mimicks a notarytool that doesn't support --timeout
+// and whose upload hangs.
+func testCmdUploadHang() int {
+	if strings.Contains(strings.Join(os.Args, " "), "--help") {
+		return 0
+	}
+
+	time.Sleep(time.Minute)
+	return testCmdUploadSuccess()
+}
@@ -0,0 +1,104 @@
+package notarize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+)
+
+// fileMagic are the leading bytes of each archive format that can be
+// submitted. dmg files are identified by their trailer instead.
+var fileMagic = map[string][][]byte{
+	".zip": {[]byte("PK\x03\x04"), []byte("PK\x05\x06")},
+	".pkg": {[]byte("xar!")},
+}
+
+// dmgTrailerSize is the size of the "koly" trailer at the end of a dmg.
+const dmgTrailerSize = 512
+
+// Validate checks that File exists and is a format that can be notarized:
+// an app bundle or other bundle directory, or a zip, dmg, or pkg file whose
+// contents match its extension. Notarize doesn't call this, so calling it
+// first reports a mistyped path or wrong file without waiting on
+// notarytool. A relative File is relative to WorkDir.
+func (o *Options) Validate() error {
+	if o.File == "" {
+		return errors.New("file to notarize must be set")
+	}
+
+	path := workdir.Path(o.WorkDir, o.File)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("file to notarize can't be read: %w", err)
+	}
+	if fi.IsDir() {
+		return nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".zip", ".pkg":
+		if !hasMagic(path, fileMagic[ext]) {
+			return fmt.Errorf("%s is not a valid %s file", o.File, ext)
+		}
+
+	case ".dmg":
+		if !hasDMGTrailer(path, fi.Size()) {
+			return fmt.Errorf("%s is not a valid .dmg file", o.File)
+		}
+
+	default:
+		return fmt.Errorf("unsupported file format %q, only app bundles, zip, dmg, and pkg files can be notarized", ext)
+	}
+
+	return nil
+}
+
+// hasMagic returns true if the file at path starts with any of magic.
+func hasMagic(path string, magic [][]byte) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+
+	for _, m := range magic {
+		if bytes.HasPrefix(header, m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasDMGTrailer returns true if the file at path, which is size bytes
+// long, ends with the trailer of a disk image.
+func hasDMGTrailer(path string, size int64) bool {
+	if size < dmgTrailerSize {
+		return false
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	trailer := make([]byte, 4)
+	if _, err := f.ReadAt(trailer, size-dmgTrailerSize); err != nil {
+		return false
+	}
+
+	return string(trailer) == "koly"
+}
@@ -0,0 +1,51 @@
+package notarize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0644))
+		return name
+	}
+
+	dmg := make([]byte, 1024)
+	copy(dmg[len(dmg)-dmgTrailerSize:], "koly")
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "Foo.app"), 0755))
+
+	cases := []struct {
+		Name string
+		File string
+		Err  string
+	}{
+		{"bundle", "Foo.app", ""},
+		{"zip", write("foo.zip", []byte("PK\x03\x04rest")), ""},
+		{"pkg", write("foo.pkg", []byte("xar!rest")), ""},
+		{"dmg", write("foo.dmg", dmg), ""},
+		{"empty", "", "must be set"},
+		{"missing", "missing.zip", "can't be read"},
+		{"unsupported", write("foo.tar.gz", []byte("data")), `unsupported file format ".gz"`},
+		{"bad zip", write("bad.zip", []byte("data")), "not a valid .zip file"},
+		{"bad dmg", write("bad.dmg", make([]byte, 1024)), "not a valid .dmg file"},
+		{"short dmg", write("short.dmg", []byte("koly")), "not a valid .dmg file"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := (&Options{File: tc.File, WorkDir: dir}).Validate()
+			if tc.Err == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.Err)
+			}
+		})
+	}
+}
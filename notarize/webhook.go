@@ -0,0 +1,153 @@
+package notarize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// webhookTimeout is how long posting a single webhook event may take.
+// Events are posted inline between polls, so this is kept short to not
+// delay notarization when the webhook is slow or unreachable.
+var webhookTimeout = 5 * time.Second
+
+// webhookEvent is the JSON document posted to Options.WebhookURL.
+type webhookEvent struct {
+	BuildID   string           `json:"build_id,omitempty"`
+	UUID      string           `json:"uuid,omitempty"`
+	Event     string           `json:"event"`
+	Status    SubmissionStatus `json:"status,omitempty"`
+	Timestamp time.Time        `json:"timestamp"`
+}
+
+// webhookStatus implements Status by posting each status change to a
+// webhook and forwarding every callback to the wrapped Status.
+type webhookStatus struct {
+	Status
+
+	ctx     context.Context
+	url     string
+	client  *http.Client
+	logger  hclog.Logger
+	buildID string
+
+	uuid              string
+	lastInfo, lastLog SubmissionStatus
+}
+
+// newStatus returns the Status to notify for opts, which writes the state
+// file and posts to the webhook if those are set. Webhook requests are
+// bound to ctx.
+func newStatus(ctx context.Context, opts *Options, logger hclog.Logger) Status {
+	var status Status = noopStatus{}
+	if opts.Status != nil {
+		status = opts.Status
+	}
+
+	if opts.StatePath != "" {
+		status = &stateStatus{Status: status, opts: opts, logger: logger}
+	}
+
+	if opts.WebhookURL == "" {
+		return status
+	}
+
+	buildID, _ := BuildID(ctx)
+	return &webhookStatus{
+		Status:  status,
+		ctx:     ctx,
+		url:     opts.WebhookURL,
+		client:  httpClient(opts),
+		logger:  logger,
+		buildID: buildID,
+	}
+}
+
+func (s *webhookStatus) Submitting() {
+	s.Status.Submitting()
+	s.post("submitting", "")
+}
+
+func (s *webhookStatus) Submitted(uuid string) {
+	s.Status.Submitted(uuid)
+	s.uuid = uuid
+	s.lastInfo, s.lastLog = "", ""
+	s.post("submitted", "")
+}
+
+func (s *webhookStatus) QueueCleared(uuid string, queueWait time.Duration) {
+	s.Status.QueueCleared(uuid, queueWait)
+	s.uuid = uuid
+	s.post("queueCleared", "")
+}
+
+func (s *webhookStatus) InfoStatus(info Info) {
+	s.Status.InfoStatus(info)
+	if info.Status != s.lastInfo {
+		s.lastInfo = info.Status
+		s.post("info", info.Status)
+	}
+}
+
+func (s *webhookStatus) LogStatus(log Log) {
+	s.Status.LogStatus(log)
+	if log.Status != s.lastLog {
+		s.lastLog = log.Status
+		s.post("log", log.Status)
+	}
+}
+
+func (s *webhookStatus) RateLimited(uuid string, wait time.Duration) {
+	s.Status.RateLimited(uuid, wait)
+	s.post("rateLimited", "")
+}
+
+func (s *webhookStatus) LargeUpload(size int64) {
+	s.Status.LargeUpload(size)
+	s.post("largeUpload", "")
+}
+
+// post posts an event to the webhook, giving up after webhookTimeout or
+// once the context is done. Errors are only logged since the webhook
+// shouldn't interrupt notarization.
+func (s *webhookStatus) post(event string, status SubmissionStatus) {
+	data, err := json.Marshal(webhookEvent{
+		BuildID:   s.buildID,
+		UUID:      s.uuid,
+		Event:     event,
+		Status:    status,
+		Timestamp: time.Now().UTC(),
+	})
+	if err != nil {
+		s.logger.Warn("error encoding webhook event", "event", event, "err", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		s.logger.Warn("error creating webhook request", "event", event, "err", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.Warn("error posting webhook", "event", event, "err", err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		s.logger.Warn("webhook returned an error", "event", event, "status", resp.Status)
+	}
+}
+
+// Assert that we always implement it
+var _ Status = (*webhookStatus)(nil)
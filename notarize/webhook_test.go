@@ -0,0 +1,100 @@
+package notarize
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotarize_webhook(t *testing.T) {
+	var lock sync.Mutex
+	var events []webhookEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event webhookEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, event)
+	}))
+	defer server.Close()
+
+	status := &testStatus{}
+	_, _, err := Notarize(WithBuildID(context.Background(), "build-42"), &Options{
+		Logger:     hclog.L(),
+		BaseCmd:    childCmd(t, "notarize-accepted"),
+		Intervals:  testIntervals,
+		Status:     status,
+		WebhookURL: server.URL,
+	})
+	require.NoError(t, err)
+
+	// The webhook doesn't replace the Status.
+	require.Equal(t, []string{
+		"Submitting", "Submitted", "QueueCleared", "InfoStatus", "LogStatus",
+	}, status.Events)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	var names []string
+	for _, e := range events {
+		names = append(names, e.Event)
+		require.False(t, e.Timestamp.IsZero())
+		require.Equal(t, "build-42", e.BuildID)
+	}
+	require.Equal(t, []string{"submitting", "submitted", "queueCleared", "info", "log"}, names)
+	require.Equal(t, "cfd69166-8e2f-1397-8636-ec06f98e3597", events[1].UUID)
+	require.Equal(t, StatusAccepted, events[3].Status)
+	require.Equal(t, StatusAccepted, events[4].Status)
+}
+
+func TestNotarize_webhookUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, _, err := Notarize(context.Background(), &Options{
+		Logger:     hclog.L(),
+		BaseCmd:    childCmd(t, "notarize-accepted"),
+		Intervals:  testIntervals,
+		WebhookURL: server.URL,
+	})
+	require.NoError(t, err)
+}
+
+func TestNotarize_webhookHung(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	old := webhookTimeout
+	webhookTimeout = 10 * time.Millisecond
+	defer func() { webhookTimeout = old }()
+
+	// Each event gives up after the timeout instead of waiting on the
+	// client timeout.
+	start := time.Now()
+	_, _, err := Notarize(context.Background(), &Options{
+		Logger:     hclog.L(),
+		BaseCmd:    childCmd(t, "notarize-accepted"),
+		Intervals:  testIntervals,
+		WebhookURL: server.URL,
+	})
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), 10*time.Second)
+}
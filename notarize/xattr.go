@@ -0,0 +1,35 @@
+package notarize
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// StripXattrs removes all extended attributes, such as
+// com.apple.quarantine, from path and everything within it using
+// `xattr -cr`. The file is modified in place and path is returned, so this
+// can be used as Options.PreUpload.
+//
+// Note that this only affects the file itself. Attributes stored within an
+// archive, such as a zip created from a bundle, aren't removed. For bundles
+// zipped by gon, this strips the zip archive rather than the bundle, so
+// strip the bundle before calling Notarize or pass ditto's --noextattr in
+// Options.ZipFlags instead.
+func StripXattrs(ctx context.Context, path string) (string, error) {
+	xattr, err := exec.LookPath("xattr")
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, xattr, "-cr", path)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("error removing extended attributes:\n\n%s", out.String())
+	}
+
+	return path, nil
+}
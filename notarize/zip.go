@@ -0,0 +1,160 @@
+package notarize
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+)
+
+// ZipTool is the tool used to zip a bundle directory before it is
+// submitted for notarization.
+type ZipTool string
+
+const (
+	// ZipToolDitto zips using `ditto -c -k --keepParent`. This is the
+	// invocation recommended by Apple since it preserves symlinks and
+	// extended metadata within the bundle.
+	ZipToolDitto ZipTool = "ditto"
+
+	// ZipToolZip zips using the `zip` binary. This is only meant for special
+	// cases, the result may not be accepted by the notarization service.
+	ZipToolZip ZipTool = "zip"
+)
+
+// zipArgs returns the full argument list (including argv[0]) to zip the
+// bundle src into the archive dst with the given tool. If flags is nil,
+// the default flags for the tool are used.
+func zipArgs(tool ZipTool, flags []string, src, dst string) ([]string, error) {
+	switch tool {
+	case ZipToolDitto:
+		if flags == nil {
+			flags = []string{"-c", "-k", "--keepParent"}
+		}
+
+		args := append([]string{"ditto"}, flags...)
+		return append(args, src, dst), nil
+
+	case ZipToolZip:
+		if flags == nil {
+			flags = []string{"-q", "-r", "-y"}
+		}
+
+		// zip is executed from the parent directory of the bundle so that
+		// the bundle is the top-level entry, mirroring --keepParent.
+		args := append([]string{"zip"}, flags...)
+		return append(args, dst, filepath.Base(src)), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported zip tool %q", tool)
+	}
+}
+
+// ZipBundle zips the bundle directory appPath, such as an .app bundle, into
+// the archive outZip with `ditto -c -k --keepParent`, which is the
+// invocation Apple recommends since it preserves symlinks and extended
+// metadata. The result is checked to be a zip archive. Notarize zips a
+// bundle set as Options.File the same way on its own, so this is only
+// needed to keep the zip, such as to distribute it.
+//
+// Only the Logger, WorkDir, and BaseCmd fields of opts are used, and opts
+// may be nil.
+func ZipBundle(ctx context.Context, opts *Options, appPath, outZip string) error {
+	zipOpts := &Options{ZipTool: ZipToolDitto}
+	if opts != nil {
+		zipOpts.Logger = opts.Logger
+		zipOpts.WorkDir = opts.WorkDir
+		zipOpts.BaseCmd = opts.BaseCmd
+	}
+
+	if !isBundle(workdir.Path(zipOpts.WorkDir, appPath)) {
+		return fmt.Errorf("%s is not a bundle directory", appPath)
+	}
+
+	return zipBundle(ctx, zipOpts, appPath, outZip)
+}
+
+// zipBundle zips the bundle directory src into the archive dst using
+// the zip tool configured in opts.
+func zipBundle(ctx context.Context, opts *Options, src, dst string) error {
+	logger := opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	logger = withBuildID(ctx, logger)
+
+	tool := opts.ZipTool
+	if tool == "" {
+		tool = ZipToolDitto
+	}
+
+	args, err := zipArgs(tool, opts.ZipFlags, src, dst)
+	if err != nil {
+		return err
+	}
+
+	// Build our command
+	var cmd exec.Cmd
+	if opts.BaseCmd != nil {
+		cmd = *opts.BaseCmd
+	}
+
+	// We only set the path if it isn't set. This lets the options set the
+	// path to a binary that runs the zip tool, like for the other tools.
+	if cmd.Path == "" {
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			return fmt.Errorf("%s is required to zip %q for notarization: %w", args[0], src, err)
+		}
+
+		cmd = *(exec.CommandContext(ctx, path))
+		cmd.Args = args
+	} else {
+		cmd.Args = append([]string{filepath.Base(cmd.Path)}, args...)
+	}
+	workdir.Apply(&cmd, opts.WorkDir)
+	if tool == ZipToolZip {
+		cmd.Dir = workdir.Path(opts.WorkDir, filepath.Dir(src))
+	}
+
+	// We store all output in out for logging and in case there is an error
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = cmd.Stdout
+
+	// Log what we're going to execute
+	logger.Info("zipping bundle for notarization",
+		"file", src,
+		"output_path", dst,
+		"command_path", cmd.Path,
+		"command_args", cmd.Args,
+	)
+
+	// Execute
+	if err := cmd.Run(); err != nil {
+		logger.Error("error zipping bundle", "err", err, "output", out.String())
+		return fmt.Errorf("error zipping bundle:\n\n%s", out.String())
+	}
+
+	// Check the result so that a misbehaving tool is reported here rather
+	// than as an invalid submission by Apple.
+	if !hasMagic(workdir.Path(opts.WorkDir, dst), fileMagic[".zip"]) {
+		return fmt.Errorf("zipping bundle %q didn't produce a zip archive at %q", src, dst)
+	}
+
+	logger.Info("bundle zipped", "output_path", dst)
+	return nil
+}
+
+// isBundle returns true if path is a directory, such as an .app bundle,
+// that must be zipped before it can be submitted.
+func isBundle(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,117 @@
+package notarize
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	childCommands["zip"] = testCmdZip
+}
+
+func TestZipArgs_ditto(t *testing.T) {
+	args, err := zipArgs(ZipToolDitto, nil, "/build/Foo.app", "/tmp/Foo.app.zip")
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal([]string{
+		"ditto", "-c", "-k", "--keepParent", "/build/Foo.app", "/tmp/Foo.app.zip",
+	}, args)
+}
+
+func TestZipArgs_flags(t *testing.T) {
+	args, err := zipArgs(ZipToolZip, []string{"-r"}, "/build/Foo.app", "/tmp/Foo.app.zip")
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal([]string{"zip", "-r", "/tmp/Foo.app.zip", "Foo.app"}, args)
+}
+
+func TestZipBundle(t *testing.T) {
+	td := t.TempDir()
+	bundle := filepath.Join(td, "Foo.app")
+	require.NoError(t, os.MkdirAll(filepath.Join(bundle, "Contents"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(bundle, "Contents", "Info.plist"), nil, 0644))
+
+	out := filepath.Join(td, "Foo.app.zip")
+	require.NoError(t, ZipBundle(context.Background(), &Options{
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "zip"),
+	}, bundle, out))
+	require.NoError(t, (&Options{File: out}).Validate())
+}
+
+func TestZipBundle_invalid(t *testing.T) {
+	td := t.TempDir()
+	bundle := filepath.Join(td, "Foo.app")
+	require.NoError(t, os.MkdirAll(bundle, 0755))
+
+	cmd := childCmd(t, "zip")
+	cmd.Env = append(cmd.Env, childEnv+"_ZIP_GARBAGE=1")
+	opts := &Options{
+		Logger:  hclog.L(),
+		BaseCmd: cmd,
+	}
+	err := ZipBundle(context.Background(), opts, bundle, filepath.Join(td, "Foo.app.zip"))
+	require.ErrorContains(t, err, "didn't produce a zip archive")
+
+	err = ZipBundle(context.Background(), opts, filepath.Join(td, "missing.app"), filepath.Join(td, "out.zip"))
+	require.ErrorContains(t, err, "not a bundle directory")
+}
+
+// testCmdZip mimicks both `ditto -c -k --keepParent src dst` and
+// `zip ... dst src` by writing a zip archive with the bundle as the only
+// entry. Every child runs this for the zip tools since bundles are zipped
+// through BaseCmd. If the _ZIP_GARBAGE variable is set, the archive isn't a
+// zip archive.
+func testCmdZip() int {
+	args := os.Args[1:]
+	if len(args) < 3 {
+		fmt.Fprintf(os.Stderr, "unexpected args: %v\n", args)
+		return 1
+	}
+
+	src, dst := args[len(args)-2], args[len(args)-1]
+	if args[0] == "zip" {
+		src, dst = dst, src
+	}
+
+	if os.Getenv(childEnv+"_ZIP_GARBAGE") != "" {
+		if err := os.WriteFile(dst, []byte("garbage\n"), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	if _, err := w.Create(filepath.Base(src) + "/"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := w.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func TestZipArgs_unknown(t *testing.T) {
+	_, err := zipArgs("tar", nil, "/build/Foo.app", "/tmp/Foo.app.zip")
+	require.Error(t, err)
+}
@@ -11,16 +11,34 @@
 package dmg
 
 import (
-	"bytes"
 	"context"
-	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 
 	"github.com/hashicorp/go-hclog"
 
 	"github.com/asahasrabuddhe/gon/internal/createdmg"
+	"github.com/asahasrabuddhe/gon/internal/tempdir"
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+)
+
+// CreateDMGError is the error returned by Dmg when create-dmg fails. It
+// contains the exit code and output of create-dmg for troubleshooting.
+type CreateDMGError = createdmg.CreateDMGError
+
+// ErrChecksumMismatch is matched by the error returned by Dmg when a file
+// of the bundled create-dmg doesn't have the expected hash once it was
+// extracted, such as if it was modified on disk.
+var ErrChecksumMismatch = createdmg.ErrChecksumMismatch
+
+// The SHA-256 hashes of the bundled create-dmg script and of the
+// dmg-license.py helper that it runs. Dmg verifies the extracted files
+// against these before running them.
+const (
+	CreateDMGSHA256  = createdmg.CreateDMGSHA256
+	DMGLicenseSHA256 = createdmg.DMGLicenseSHA256
 )
 
 // Options are the options for creating the dmg archive.
@@ -48,6 +66,40 @@ type Options struct {
 	// VolumeName is the name of the dmg volume when mounted.
 	VolumeName string
 
+	// Background, if set, is the path to the image shown as the background
+	// of the Finder window. This can be a png, gif, or jpg.
+	Background string
+
+	// Icons are the positions of the icons of files within the Finder
+	// window.
+	Icons []IconPosition
+
+	// WindowWidth and WindowHeight, if non-zero, are the size of the Finder
+	// window. Both must be set to take effect.
+	WindowWidth  int
+	WindowHeight int
+
+	// License is an (optional) path to a license agreement file that is
+	// shown when the dmg is opened. Attaching it requires a python3 or
+	// python interpreter on the PATH for create-dmg's licensing script. If
+	// neither works, the dmg is created without the license and a warning
+	// is logged.
+	License string
+
+	// SkipLicense, if true, creates the dmg without License even if it is
+	// set. This is useful on machines where python is known to be broken.
+	SkipLicense bool
+
+	// TempDir, if set, is the directory that temporary files are created
+	// in, which defaults to the OS temp directory. Set this to a larger
+	// volume if the default is too small for extracting create-dmg.
+	TempDir string
+
+	// WorkDir, if set, is the working directory that create-dmg runs in.
+	// Relative paths in these options are relative to it. This must be an
+	// existing directory.
+	WorkDir string
+
 	// Logger is the logger to use. If this is nil then no logging will be done.
 	Logger hclog.Logger
 
@@ -56,6 +108,16 @@ type Options struct {
 	BaseCmd *exec.Cmd
 }
 
+// IconPosition is the position of the icon of a file within the Finder
+// window of a dmg.
+type IconPosition struct {
+	// Name is the name of the file relative to the root of the dmg.
+	Name string
+
+	// X and Y are the coordinates of the center of the icon.
+	X, Y int
+}
+
 // Dmg creates a dmg archive for notarization using the options given.
 func Dmg(ctx context.Context, opts *Options) error {
 	logger := opts.Logger
@@ -63,6 +125,15 @@ func Dmg(ctx context.Context, opts *Options) error {
 		logger = hclog.NewNullLogger()
 	}
 
+	if err := workdir.Validate(opts.WorkDir); err != nil {
+		return err
+	}
+
+	tmp := workdir.Path(opts.WorkDir, opts.TempDir)
+	if err := tempdir.Validate(tmp, 0); err != nil {
+		return err
+	}
+
 	// Build our command
 	var cmd *exec.Cmd
 	if opts.BaseCmd != nil {
@@ -73,7 +144,7 @@ func Dmg(ctx context.Context, opts *Options) error {
 	// If the options didn't set a command, we do so from our vendored create-dmg
 	if cmd == nil {
 		var err error
-		cmd, err = createdmg.Cmd(ctx)
+		cmd, err = createdmg.Cmd(ctx, tmp)
 		if err != nil {
 			return err
 		}
@@ -86,6 +157,34 @@ func Dmg(ctx context.Context, opts *Options) error {
 		"--volname", opts.VolumeName,
 	}
 
+	// Set up the Finder window
+	if opts.Background != "" {
+		args = append(args, "--background", opts.Background)
+	}
+	if opts.WindowWidth > 0 && opts.WindowHeight > 0 {
+		args = append(args, "--window-size",
+			strconv.Itoa(opts.WindowWidth), strconv.Itoa(opts.WindowHeight))
+	}
+	for _, icon := range opts.Icons {
+		args = append(args, "--icon", icon.Name, strconv.Itoa(icon.X), strconv.Itoa(icon.Y))
+	}
+
+	// Attach the license if we can, since licensing is only cosmetic we
+	// degrade to a dmg without one rather than failing.
+	if opts.License != "" {
+		switch {
+		case opts.SkipLicense:
+			logger.Info("skipping dmg license", "license", opts.License)
+
+		case !pythonAvailable(ctx):
+			logger.Warn("python is unavailable, creating dmg without license",
+				"license", opts.License)
+
+		default:
+			args = append(args, "--eula", opts.License)
+		}
+	}
+
 	// Inject our files
 	for _, f := range opts.Files {
 		args = append(args, "--add-file", filepath.Base(f), f, "0", "0")
@@ -96,7 +195,7 @@ func Dmg(ctx context.Context, opts *Options) error {
 	// inject our files.
 	root := opts.Root
 	if root == "" {
-		td, err := os.MkdirTemp("", "gon")
+		td, err := os.MkdirTemp(tmp, "gon")
 		if err != nil {
 			return err
 		}
@@ -106,20 +205,17 @@ func Dmg(ctx context.Context, opts *Options) error {
 
 	// Add the final arguments and set it on cmd
 	cmd.Args = append(args, opts.OutputPath, root)
+	workdir.Apply(cmd, opts.WorkDir)
 
 	// If our output path exists prior to running, we have to delete that
-	if _, err := os.Stat(opts.OutputPath); err == nil {
-		logger.Info("output path exists, removing", "path", opts.OutputPath)
-		if err := os.Remove(opts.OutputPath); err != nil {
+	outputPath := workdir.Path(opts.WorkDir, opts.OutputPath)
+	if _, err := os.Stat(outputPath); err == nil {
+		logger.Info("output path exists, removing", "path", outputPath)
+		if err := os.Remove(outputPath); err != nil {
 			return err
 		}
 	}
 
-	// We store all output in out for logging and in case there is an error
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = cmd.Stdout
-
 	// Log what we're going to execute
 	logger.Info("executing create-dmg for dmg creation",
 		"output_path", opts.OutputPath,
@@ -127,12 +223,35 @@ func Dmg(ctx context.Context, opts *Options) error {
 		"command_args", cmd.Args,
 	)
 
-	// Execute
-	if err := cmd.Run(); err != nil {
-		logger.Error("error creating dmg", "err", err, "output", out.String())
-		return fmt.Errorf("error creating dmg:\n\n%s", out.String())
+	// Execute. On failure the error includes the create-dmg output.
+	out, err := createdmg.Run(cmd)
+	if err != nil {
+		logger.Error("error creating dmg", "err", err, "output", out)
+		return err
 	}
 
-	logger.Info("dmg creation complete", "output", out.String())
+	logger.Info("dmg creation complete", "output", out)
 	return nil
 }
+
+// pythonNames are the names of the python interpreters that create-dmg's
+// licensing script may run with, in the order they are probed. Recent
+// versions of macOS only ship python3.
+var pythonNames = []string{"python3", "python"}
+
+// pythonAvailable returns true if a working python interpreter is available
+// for create-dmg's licensing script.
+func pythonAvailable(ctx context.Context) bool {
+	for _, name := range pythonNames {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+
+		if exec.CommandContext(ctx, path, "-c", "import sys").Run() == nil {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,145 @@
+package dmg
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+// childEnv is the env var that must be set to trigger a child command.
+const childEnv = "GON_TEST_CHILD"
+
+// childCommands is the list of commands the tests can run as a child.
+var childCommands = map[string]func() int{
+	"create-dmg": childCreateDMG,
+}
+
+func TestMain(m *testing.M) {
+	// Set our default logger
+	logger := hclog.L()
+	logger.SetLevel(hclog.Trace)
+	hclog.SetDefault(logger)
+
+	// If we got a subcommand, run that
+	if v := os.Getenv(childEnv); v != "" && childCommands[v] != nil {
+		os.Exit(childCommands[v]())
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestDmg_license(t *testing.T) {
+	// Only python3 is on the PATH, like on recent versions of macOS.
+	testPath(t, "python3")
+
+	args := testDmg(t, &Options{License: "LICENSE.txt"})
+	require.Contains(t, args, "--eula LICENSE.txt")
+}
+
+func TestDmg_licensePython2(t *testing.T) {
+	testPath(t, "python")
+
+	args := testDmg(t, &Options{License: "LICENSE.txt"})
+	require.Contains(t, args, "--eula LICENSE.txt")
+}
+
+func TestDmg_licenseNoPython(t *testing.T) {
+	// The dmg is still created, just without the license.
+	testPath(t)
+
+	args := testDmg(t, &Options{License: "LICENSE.txt"})
+	require.NotContains(t, args, "--eula")
+}
+
+func TestDmg_skipLicense(t *testing.T) {
+	testPath(t, "python3")
+
+	args := testDmg(t, &Options{License: "LICENSE.txt", SkipLicense: true})
+	require.NotContains(t, args, "--eula")
+}
+
+func TestDmg_window(t *testing.T) {
+	args := testDmg(t, &Options{
+		Background:   "bg.png",
+		Icons:        []IconPosition{{Name: "Gon.app", X: 160, Y: 200}},
+		WindowWidth:  640,
+		WindowHeight: 480,
+	})
+	require.Contains(t, args, "--background bg.png --window-size 640 480 --icon Gon.app 160 200 ")
+
+	// The window size is only set if both are.
+	args = testDmg(t, &Options{WindowWidth: 640})
+	require.NotContains(t, args, "--window-size")
+}
+
+// testDmg creates a dmg with opts using a fake create-dmg and returns the
+// arguments it was run with, joined by spaces.
+func testDmg(t *testing.T, opts *Options) string {
+	t.Helper()
+
+	td := t.TempDir()
+	argsPath := filepath.Join(td, "args")
+	cmd := childCmd(t, "create-dmg")
+	cmd.Env = append(cmd.Env, childEnv+"_ARGS="+argsPath)
+
+	opts.OutputPath = filepath.Join(td, "out.dmg")
+	opts.VolumeName = "gon"
+	opts.Logger = hclog.L()
+	opts.BaseCmd = cmd
+	require.NoError(t, Dmg(context.Background(), opts))
+
+	args, err := os.ReadFile(argsPath)
+	require.NoError(t, err)
+	return string(args)
+}
+
+// testPath replaces the PATH with a directory that only has working
+// interpreters with the given names.
+func testPath(t *testing.T, names ...string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\nexit 0\n"), 0755))
+	}
+	t.Setenv("PATH", dir)
+}
+
+// childCmd is used to create a command that executes a command in the
+// childCommands map in a new process.
+func childCmd(t *testing.T, name string, args ...string) *exec.Cmd {
+	t.Helper()
+
+	// Get the path to our executable
+	selfPath, err := filepath.Abs(os.Args[0])
+	if err != nil {
+		t.Fatalf("error creating child command: %s", err)
+		return nil
+	}
+
+	cmd := exec.Command(selfPath, args...)
+	cmd.Env = os.Environ()
+	cmd.Env = append(cmd.Env, childEnv+"="+name)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd
+}
+
+// childCreateDMG mimicks create-dmg by writing its arguments to the file
+// in the _ARGS variable.
+func childCreateDMG() int {
+	args := strings.Join(os.Args[1:], " ")
+	if err := os.WriteFile(os.Getenv(childEnv+"_ARGS"), []byte(args), 0644); err != nil {
+		return 1
+	}
+
+	return 0
+}
@@ -9,6 +9,9 @@ import (
 	"path/filepath"
 
 	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/tempdir"
+	"github.com/asahasrabuddhe/gon/internal/workdir"
 )
 
 // Options are the options for creating the zip archive.
@@ -21,6 +24,16 @@ type Options struct {
 	// it will be overwritten.
 	OutputPath string
 
+	// TempDir, if set, is the directory that temporary files are created
+	// in, which defaults to the OS temp directory. Set this to a larger
+	// volume if the default is too small for copying Files.
+	TempDir string
+
+	// WorkDir, if set, is the working directory that ditto runs in. Relative
+	// paths in Files, OutputPath, and TempDir are relative to it. This must
+	// be an existing directory.
+	WorkDir string
+
 	// Logger is the logger to use. If this is nil then no logging will be done.
 	Logger hclog.Logger
 
@@ -41,6 +54,10 @@ func Zip(ctx context.Context, opts *Options) error {
 		logger = hclog.NewNullLogger()
 	}
 
+	if err := workdir.Validate(opts.WorkDir); err != nil {
+		return err
+	}
+
 	// Set up our root directory with the given files.
 	root, err := createRoot(ctx, logger, opts)
 	if err != nil {
@@ -61,6 +78,7 @@ func Zip(ctx context.Context, opts *Options) error {
 	}
 	cmd.Args = append(cmd.Args, root)
 	cmd.Args = append(cmd.Args, opts.OutputPath)
+	workdir.Apply(cmd, opts.WorkDir)
 
 	// We store all output in out for logging and in case there is an error
 	var out bytes.Buffer
@@ -125,8 +143,18 @@ func createRoot(ctx context.Context, logger hclog.Logger, opts *Options) (string
 		return "", err
 	}
 
-	// Create our root directory
-	root, err := os.MkdirTemp("", "gon-createzip")
+	// Create our root directory. The files are copied into it, so it
+	// needs room for all of them.
+	var files []string
+	for _, f := range opts.Files {
+		files = append(files, workdir.Path(opts.WorkDir, f))
+	}
+	tmp := workdir.Path(opts.WorkDir, opts.TempDir)
+	if err := tempdir.Validate(tmp, tempdir.Size(files...)); err != nil {
+		return "", err
+	}
+
+	root, err := os.MkdirTemp(tmp, "gon-createzip")
 	if err != nil {
 		return "", err
 	}
@@ -137,6 +165,7 @@ func createRoot(ctx context.Context, logger hclog.Logger, opts *Options) (string
 	}
 	cmd.Args = append(cmd.Args, opts.Files...)
 	cmd.Args = append(cmd.Args, root)
+	workdir.Apply(cmd, opts.WorkDir)
 
 	// We store all output in out for logging and in case there is an error
 	var out bytes.Buffer
@@ -0,0 +1,158 @@
+package sign
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"howett.net/plist"
+)
+
+// ErrInvalidEntitlements is matched by the error returned when an
+// entitlements file is malformed. Use errors.As with
+// *InvalidEntitlementsError to get the offending file and key.
+var ErrInvalidEntitlements = errors.New("invalid entitlements")
+
+// InvalidEntitlementsError is returned when an entitlements file isn't a
+// well-formed plist dictionary or a known entitlement has the wrong type.
+type InvalidEntitlementsError struct {
+	// File is the path to the entitlements file.
+	File string
+
+	// Key is the offending entitlement. This is empty if the file
+	// couldn't be parsed at all.
+	Key string
+
+	// Reason describes what is wrong.
+	Reason string
+}
+
+// Error implements error
+func (e *InvalidEntitlementsError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("%s in %s: %s", ErrInvalidEntitlements, e.File, e.Reason)
+	}
+
+	return fmt.Sprintf("%s in %s: %q %s", ErrInvalidEntitlements, e.File, e.Key, e.Reason)
+}
+
+// Is returns true for ErrInvalidEntitlements.
+func (e *InvalidEntitlementsError) Is(target error) bool {
+	return target == ErrInvalidEntitlements
+}
+
+// entitlementKind is the type of value an entitlement must have.
+type entitlementKind string
+
+const (
+	entitlementBool        entitlementKind = "a boolean"
+	entitlementString      entitlementKind = "a string"
+	entitlementStringArray entitlementKind = "an array of strings"
+)
+
+// knownEntitlements are the entitlements commonly used by apps
+// distributed outside the App Store, mapped to the type of their value.
+// Unknown keys aren't validated since Apple adds new ones regularly.
+var knownEntitlements = map[string]entitlementKind{
+	"com.apple.security.app-sandbox":                                       entitlementBool,
+	"com.apple.security.cs.allow-jit":                                      entitlementBool,
+	"com.apple.security.cs.allow-unsigned-executable-memory":               entitlementBool,
+	"com.apple.security.cs.allow-dyld-environment-variables":               entitlementBool,
+	"com.apple.security.cs.disable-library-validation":                     entitlementBool,
+	"com.apple.security.cs.disable-executable-page-protection":             entitlementBool,
+	"com.apple.security.cs.debugger":                                       entitlementBool,
+	"com.apple.security.get-task-allow":                                    entitlementBool,
+	"com.apple.security.network.client":                                    entitlementBool,
+	"com.apple.security.network.server":                                    entitlementBool,
+	"com.apple.security.device.audio-input":                                entitlementBool,
+	"com.apple.security.device.camera":                                     entitlementBool,
+	"com.apple.security.device.usb":                                        entitlementBool,
+	"com.apple.security.device.bluetooth":                                  entitlementBool,
+	"com.apple.security.automation.apple-events":                           entitlementBool,
+	"com.apple.security.files.user-selected.read-only":                     entitlementBool,
+	"com.apple.security.files.user-selected.read-write":                    entitlementBool,
+	"com.apple.security.files.downloads.read-write":                        entitlementBool,
+	"com.apple.security.personal-information.addressbook":                  entitlementBool,
+	"com.apple.security.personal-information.calendars":                    entitlementBool,
+	"com.apple.security.personal-information.location":                     entitlementBool,
+	"com.apple.security.personal-information.photos-library":               entitlementBool,
+	"com.apple.security.inherit":                                           entitlementBool,
+	"com.apple.security.application-groups":                                entitlementStringArray,
+	"com.apple.security.temporary-exception.files.absolute-path.read-only": entitlementStringArray,
+	"com.apple.security.temporary-exception.mach-lookup.global-name":       entitlementStringArray,
+	"keychain-access-groups":                                               entitlementStringArray,
+	"com.apple.application-identifier":                                     entitlementString,
+	"com.apple.developer.team-identifier":                                  entitlementString,
+}
+
+// validateEntitlements checks that the entitlements file at path is a
+// well-formed plist dictionary and that known entitlements have values of
+// the correct type.
+func validateEntitlements(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var entitlements map[string]interface{}
+	if _, err := plist.Unmarshal(data, &entitlements); err != nil {
+		return &InvalidEntitlementsError{
+			File:   path,
+			Reason: fmt.Sprintf("not a valid plist dictionary: %s", err),
+		}
+	}
+
+	// The keys are checked in order so that the same error is reported for
+	// a file with several invalid entitlements.
+	keys := make([]string, 0, len(entitlements))
+	for key := range entitlements {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		kind, ok := knownEntitlements[key]
+		if !ok {
+			continue
+		}
+
+		if !isEntitlementKind(entitlements[key], kind) {
+			return &InvalidEntitlementsError{
+				File:   path,
+				Key:    key,
+				Reason: fmt.Sprintf("must be %s", kind),
+			}
+		}
+	}
+
+	return nil
+}
+
+// isEntitlementKind returns true if value has the given kind.
+func isEntitlementKind(value interface{}, kind entitlementKind) bool {
+	switch kind {
+	case entitlementBool:
+		_, ok := value.(bool)
+		return ok
+
+	case entitlementString:
+		_, ok := value.(string)
+		return ok
+
+	case entitlementStringArray:
+		values, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, v := range values {
+			if _, ok := v.(string); !ok {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return false
+	}
+}
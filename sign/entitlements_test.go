@@ -0,0 +1,52 @@
+package sign
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateEntitlements(t *testing.T) {
+	require.NoError(t, validateEntitlements(filepath.Join("testdata", "valid.entitlements")))
+}
+
+func TestValidateEntitlements_wrongType(t *testing.T) {
+	// Both entitlements have the wrong type, the first one is reported.
+	err := validateEntitlements(filepath.Join("testdata", "wrong-type.entitlements"))
+
+	req := require.New(t)
+	req.ErrorIs(err, ErrInvalidEntitlements)
+
+	var target *InvalidEntitlementsError
+	req.True(errors.As(err, &target))
+	req.Equal("com.apple.security.cs.allow-jit", target.Key)
+	req.Contains(err.Error(), "must be a boolean")
+}
+
+func TestValidateEntitlements_malformed(t *testing.T) {
+	err := validateEntitlements(filepath.Join("testdata", "malformed.entitlements"))
+
+	req := require.New(t)
+	req.ErrorIs(err, ErrInvalidEntitlements)
+
+	var target *InvalidEntitlementsError
+	req.True(errors.As(err, &target))
+	req.Empty(target.Key)
+}
+
+func TestSign_validateEntitlements(t *testing.T) {
+	err := Sign(context.Background(), &Options{
+		Files:                []string{"foo"},
+		Identity:             "bar",
+		Entitlements:         filepath.Join("testdata", "wrong-type.entitlements"),
+		ValidateEntitlements: true,
+		Logger:               hclog.L(),
+		BaseCmd:              childCmd(t, "success"),
+	})
+
+	require.ErrorIs(t, err, ErrInvalidEntitlements)
+}
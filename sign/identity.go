@@ -0,0 +1,92 @@
+package sign
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Identity is a code signing identity available in the keychain.
+type Identity struct {
+	// Hash is the SHA-1 hash of the certificate. This can be used as the
+	// Identity in Options to select the identity unambiguously.
+	Hash string
+
+	// Name is the common name of the certificate, such as
+	// "Developer ID Application: Example, Inc. (TEAMID)".
+	Name string
+
+	// Valid is true if the identity can be used for signing.
+	Valid bool
+
+	// Reason is why the identity isn't valid, such as
+	// "CSSMERR_TP_CERT_EXPIRED". This is empty if Valid is true.
+	Reason string
+}
+
+// identityBaseCmd is the base command used by ListIdentities. This is only
+// overridden by tests to overwrite where the security binary is.
+var identityBaseCmd *exec.Cmd
+
+// ListIdentities lists the code signing identities that are available,
+// using `security find-identity -v -p codesigning`. This helps pick the
+// right Identity and debug "identity not found" errors.
+func ListIdentities(ctx context.Context) ([]Identity, error) {
+	// Build our command
+	var cmd exec.Cmd
+	if identityBaseCmd != nil {
+		cmd = *identityBaseCmd
+	}
+
+	// We only set the path if it isn't set. This lets tests set the path
+	// to the security binary that we use.
+	if cmd.Path == "" {
+		path, err := exec.LookPath("security")
+		if err != nil {
+			return nil, err
+		}
+
+		cmd = *(exec.CommandContext(ctx, path))
+	}
+
+	cmd.Args = []string{"security", "find-identity", "-v", "-p", "codesigning"}
+
+	// We store all output in out for parsing and in case there is an error
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error listing identities:\n\n%s", out.String())
+	}
+
+	return parseIdentities(out.String()), nil
+}
+
+// identityRe matches a single identity in the output of find-identity.
+var identityRe = regexp.MustCompile(`^\s*\d+\)\s+([0-9A-Fa-f]{40})\s+"(.*)"(?:\s+\((\S+)\))?\s*$`)
+
+// parseIdentities parses the output of `security find-identity`.
+func parseIdentities(out string) []Identity {
+	var result []Identity
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		m := identityRe.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+
+		result = append(result, Identity{
+			Hash:   m[1],
+			Name:   m[2],
+			Valid:  m[3] == "",
+			Reason: m[3],
+		})
+	}
+
+	return result
+}
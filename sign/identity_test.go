@@ -0,0 +1,34 @@
+package sign
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListIdentities(t *testing.T) {
+	identityBaseCmd = childCmd(t, "find-identity")
+	defer func() { identityBaseCmd = nil }()
+
+	identities, err := ListIdentities(context.Background())
+
+	req := require.New(t)
+	req.NoError(err)
+	req.Equal([]Identity{
+		{
+			Hash:  "1A2B3C4D5E6F7A8B9C0D1E2F3A4B5C6D7E8F9A0B",
+			Name:  "Developer ID Application: Example, Inc. (ABCDE12345)",
+			Valid: true,
+		},
+		{
+			Hash:   "0F9E8D7C6B5A4F3E2D1C0B9A8F7E6D5C4B3A2F1E",
+			Name:   "Apple Development: jane@example.com (FGHIJ67890)",
+			Reason: "CSSMERR_TP_CERT_EXPIRED",
+		},
+	}, identities)
+}
+
+func TestParseIdentities_none(t *testing.T) {
+	require.Empty(t, parseIdentities("     0 valid identities found\n"))
+}
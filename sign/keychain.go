@@ -0,0 +1,96 @@
+package sign
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+)
+
+// unlockKeychain unlocks the keychain in opts using KeychainPassword.
+func unlockKeychain(ctx context.Context, logger hclog.Logger, opts *Options) error {
+	password, err := resolvePassword(opts.KeychainPassword)
+	if err != nil {
+		return err
+	}
+
+	// Build our command
+	var cmd exec.Cmd
+	if opts.BaseSecurityCmd != nil {
+		cmd = *opts.BaseSecurityCmd
+	}
+
+	// We only set the path if it isn't set. This lets the options set the
+	// path to the security binary that we use.
+	if cmd.Path == "" {
+		path, err := exec.LookPath("security")
+		if err != nil {
+			return err
+		}
+
+		cmd = *(exec.CommandContext(ctx, path))
+	}
+
+	// The password is written to stdin so that it doesn't show up in the
+	// arguments of the process, which other users can see.
+	cmd.Args = []string{"security", "unlock-keychain"}
+	if opts.Keychain != "" {
+		cmd.Args = append(cmd.Args, opts.Keychain)
+	}
+	cmd.Stdin = strings.NewReader(password + "\n")
+	workdir.Apply(&cmd, opts.WorkDir)
+
+	// We store all output in out for logging and in case there is an error
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = cmd.Stdout
+
+	// Log what we're going to execute
+	logger.Info("unlocking keychain",
+		"keychain", opts.Keychain,
+		"command_path", cmd.Path,
+		"command_args", cmd.Args,
+	)
+
+	// Execute
+	if err := cmd.Run(); err != nil {
+		logger.Error("error unlocking keychain", "err", err, "output", out.String())
+		return fmt.Errorf("error unlocking keychain:\n\n%s", out.String())
+	}
+
+	logger.Info("keychain unlocked", "keychain", opts.Keychain)
+	return nil
+}
+
+// resolvePassword resolves the `@env:` and `@file:` forms of a password.
+// Any other value is returned as-is.
+func resolvePassword(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, "@env:"):
+		name := strings.TrimPrefix(v, "@env:")
+		password, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("keychain password environment variable %q is not set", name)
+		}
+
+		return password, nil
+
+	case strings.HasPrefix(v, "@file:"):
+		path := strings.TrimPrefix(v, "@file:")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("error reading keychain password file: %w", err)
+		}
+
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	default:
+		return v, nil
+	}
+}
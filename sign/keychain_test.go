@@ -0,0 +1,57 @@
+package sign
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign_keychain(t *testing.T) {
+	require.NoError(t, Sign(context.Background(), &Options{
+		Files:            []string{"foo"},
+		Identity:         "bar",
+		Keychain:         "signing.keychain-db",
+		KeychainPassword: "hunter2",
+		Logger:           hclog.L(),
+		BaseCmd:          childCmd(t, "keychain"),
+		BaseSecurityCmd:  childCmd(t, "unlock-keychain"),
+	}))
+}
+
+func TestSign_keychainUnlockFailed(t *testing.T) {
+	require.Error(t, Sign(context.Background(), &Options{
+		Files:            []string{"foo"},
+		Identity:         "bar",
+		Keychain:         "signing.keychain-db",
+		KeychainPassword: "wrong",
+		Logger:           hclog.L(),
+		BaseCmd:          childCmd(t, "keychain"),
+		BaseSecurityCmd:  childCmd(t, "unlock-keychain"),
+	}))
+}
+
+func TestResolvePassword(t *testing.T) {
+	req := require.New(t)
+
+	v, err := resolvePassword("hunter2")
+	req.NoError(err)
+	req.Equal("hunter2", v)
+
+	t.Setenv("GON_TEST_KEYCHAIN_PASSWORD", "from-env")
+	v, err = resolvePassword("@env:GON_TEST_KEYCHAIN_PASSWORD")
+	req.NoError(err)
+	req.Equal("from-env", v)
+
+	_, err = resolvePassword("@env:GON_TEST_KEYCHAIN_PASSWORD_UNSET")
+	req.Error(err)
+
+	path := filepath.Join(t.TempDir(), "password")
+	req.NoError(os.WriteFile(path, []byte("from-file\n"), 0600))
+	v, err = resolvePassword("@file:" + path)
+	req.NoError(err)
+	req.Equal("from-file", v)
+}
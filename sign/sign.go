@@ -7,8 +7,14 @@ import (
 	"fmt"
 	"io"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/exitcode"
+	"github.com/asahasrabuddhe/gon/internal/workdir"
 )
 
 // Options are the options for Sign.
@@ -27,6 +33,31 @@ type Options struct {
 	// Entitlements is an (optional) path to a plist format .entitlements file
 	Entitlements string
 
+	// EntitlementsByPath is an (optional) map of file paths or glob patterns
+	// (see filepath.Match) to the entitlements file to use for matching
+	// files. This allows signing files in Files with different entitlements,
+	// such as a helper tool that needs different ones than the main
+	// executable. Files that don't match use Entitlements.
+	EntitlementsByPath map[string]string
+
+	// ValidateEntitlements, if true, checks each entitlements file before
+	// signing. A malformed file returns an error matching
+	// ErrInvalidEntitlements that names the offending key, rather than the
+	// opaque error that codesign reports.
+	ValidateEntitlements bool
+
+	// NoForce, if true, doesn't pass codesign's --force flag, so signing a
+	// file that is already signed fails rather than replacing its
+	// signature. By default existing signatures are replaced, and since a
+	// forced re-sign can leave a bundle in a broken state, each file whose
+	// signature was replaced is verified with VerifyDeep after signing and
+	// an error is returned if verification fails.
+	NoForce bool
+
+	// Replaced, if set, is called with each file whose existing signature
+	// was replaced, which doesn't happen if NoForce is set.
+	Replaced func(file string)
+
 	// Deep is an (optional) toggle to force the --deep flag when codesigning.
 	// This can be useful for signing *.app directories and their child files.
 	Deep bool
@@ -43,9 +74,41 @@ type Options struct {
 	// used for tests to overwrite where the codesign binary is.
 	BaseCmd *exec.Cmd
 
+	// WorkDir, if set, is the working directory for the commands that are
+	// executed, including codesign. Relative paths in these options are
+	// resolved relative to it. This must be an existing directory.
+	WorkDir string
+
+	// BaseSecurityCmd is the base command for executing the security binary
+	// to unlock the keychain. This is used for tests to overwrite where the
+	// security binary is.
+	BaseSecurityCmd *exec.Cmd
+
 	// Requirements is used to pass requirements to the codesign binary.
 	// See https://developer.apple.com/library/archive/technotes/tn2206/_index.html#//apple_ref/doc/uid/DTS40007919-CH1-TNTAG6
 	Requirements string
+
+	// TimestampURL is an (optional) URL of the timestamp server to use for
+	// the secure timestamp. If this is empty, Apple's default server is used.
+	TimestampURL string
+
+	// NoTimestamp disables the secure timestamp. This is only useful for
+	// offline development builds since notarization requires a secure
+	// timestamp. This can't be set together with TimestampURL.
+	NoTimestamp bool
+
+	// Keychain is an (optional) path to the keychain to search for the
+	// signing identity. This is required on machines with multiple keychains
+	// where the identity isn't in the default search list, such as CI
+	// machines with a dedicated signing keychain.
+	Keychain string
+
+	// KeychainPassword, if set, is used to unlock Keychain (or the default
+	// keychain if Keychain is empty) with `security unlock-keychain` before
+	// signing. This supports `@env:<name>` and `@file:<path>` to read the
+	// password from an environment variable or file, respectively. The
+	// password is never logged.
+	KeychainPassword string
 }
 
 // Sign signs one or more files returning an error if any.
@@ -55,6 +118,67 @@ func Sign(ctx context.Context, opts *Options) error {
 		logger = hclog.NewNullLogger()
 	}
 
+	if err := workdir.Validate(opts.WorkDir); err != nil {
+		return err
+	}
+
+	if opts.NoTimestamp && opts.TimestampURL != "" {
+		return fmt.Errorf("a timestamp URL can't be specified when timestamps are disabled")
+	}
+
+	if opts.KeychainPassword != "" {
+		if err := unlockKeychain(ctx, logger, opts); err != nil {
+			return err
+		}
+	}
+
+	// Each distinct set of entitlements requires its own invocation of
+	// codesign. We keep the order of the files within each group.
+	var groups []string
+	filesByEntitlements := map[string][]string{}
+	for _, f := range opts.Files {
+		e := entitlementsFor(opts, f)
+		if _, ok := filesByEntitlements[e]; !ok {
+			groups = append(groups, e)
+		}
+		filesByEntitlements[e] = append(filesByEntitlements[e], f)
+	}
+
+	if opts.ValidateEntitlements {
+		for _, e := range groups {
+			if e == "" {
+				continue
+			}
+
+			if err := validateEntitlements(workdir.Path(opts.WorkDir, e)); err != nil {
+				return err
+			}
+		}
+	}
+
+	var replaced []string
+	for _, e := range groups {
+		files, err := sign(ctx, logger, opts, filesByEntitlements[e], e)
+		if err != nil {
+			return err
+		}
+		replaced = append(replaced, files...)
+	}
+
+	// A forced re-sign can silently break a bundle, which would only be
+	// found by a notarization rejection, so we verify right away.
+	for _, f := range replaced {
+		if _, err := VerifyDeep(ctx, f, opts); err != nil {
+			return fmt.Errorf("%s failed verification after forced signing: %w", f, err)
+		}
+	}
+
+	return nil
+}
+
+// sign signs the given files with the given entitlements file, which may
+// be empty. This returns the files whose existing signature was replaced.
+func sign(ctx context.Context, logger hclog.Logger, opts *Options, files []string, entitlements string) ([]string, error) {
 	// Build our command
 	var cmd exec.Cmd
 	if opts.BaseCmd != nil {
@@ -66,23 +190,30 @@ func Sign(ctx context.Context, opts *Options) error {
 	if cmd.Path == "" {
 		path, err := exec.LookPath("codesign")
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		cmd = *(exec.CommandContext(ctx, path, "codesign", "-s", opts.Identity, "-f", "-v", "--timestamp", "--options", "runtime"))
+		cmd = *(exec.CommandContext(ctx, path))
 	}
 
 	cmd.Args = []string{
 		"codesign",
 		"-s", opts.Identity,
-		"-f",
 		"-v",
-		"--timestamp",
+		timestampFlag(opts),
 		"--options", "runtime",
 	}
 
-	if len(opts.Entitlements) > 0 {
-		cmd.Args = append(cmd.Args, "--entitlements", opts.Entitlements)
+	if !opts.NoForce {
+		cmd.Args = append(cmd.Args, "-f")
+	}
+
+	if len(entitlements) > 0 {
+		cmd.Args = append(cmd.Args, "--entitlements", entitlements)
+	}
+
+	if len(opts.Keychain) > 0 {
+		cmd.Args = append(cmd.Args, "--keychain", opts.Keychain)
 	}
 
 	if opts.Deep {
@@ -95,7 +226,8 @@ func Sign(ctx context.Context, opts *Options) error {
 	}
 
 	// Append the files that we want to sign
-	cmd.Args = append(cmd.Args, opts.Files...)
+	cmd.Args = append(cmd.Args, files...)
+	workdir.Apply(&cmd, opts.WorkDir)
 
 	// We store all output in out for logging and in case there is an error
 	var out bytes.Buffer
@@ -111,17 +243,80 @@ func Sign(ctx context.Context, opts *Options) error {
 
 	// Log what we're going to execute
 	logger.Info("executing codesigning",
-		"files", opts.Files,
+		"files", files,
 		"command_path", cmd.Path,
 		"command_args", cmd.Args,
 	)
 
 	// Execute
-	if err := cmd.Run(); err != nil {
+	if err := exitcode.Run("codesign", cmd.Run(), out.String()); err != nil {
 		logger.Error("error codesigning", "err", err, "output", out.String())
-		return fmt.Errorf("error signing:\n\n%s", out.String())
+		return nil, fmt.Errorf("error signing: %w", err)
+	}
+
+	replaced := replacedFiles(out.String())
+	for _, f := range replaced {
+		logger.Info("replaced existing signature", "file", f)
+		if opts.Replaced != nil {
+			opts.Replaced(f)
+		}
 	}
 
 	logger.Info("codesigning complete", "output", out.String())
-	return nil
+	return replaced, nil
+}
+
+// replacedFiles returns the files that codesign reported replacing the
+// existing signature of.
+func replacedFiles(out string) []string {
+	var result []string
+	for _, line := range strings.Split(out, "\n") {
+		if f, ok := strings.CutSuffix(strings.TrimSpace(line), ": replacing existing signature"); ok {
+			result = append(result, f)
+		}
+	}
+
+	return result
+}
+
+// entitlementsFor returns the entitlements file to use for the given file.
+// An exact match in EntitlementsByPath is preferred, then the longest
+// matching glob pattern, falling back to Entitlements.
+func entitlementsFor(opts *Options, file string) string {
+	if e, ok := opts.EntitlementsByPath[file]; ok {
+		return e
+	}
+
+	var patterns []string
+	for pattern := range opts.EntitlementsByPath {
+		if ok, _ := filepath.Match(pattern, file); ok {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		return opts.Entitlements
+	}
+
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	return opts.EntitlementsByPath[patterns[0]]
+}
+
+// timestampFlag returns the codesign flag to configure the secure timestamp.
+func timestampFlag(opts *Options) string {
+	switch {
+	case opts.NoTimestamp:
+		return "--timestamp=none"
+
+	case opts.TimestampURL != "":
+		return "--timestamp=" + opts.TimestampURL
+
+	default:
+		return "--timestamp"
+	}
 }
@@ -1,9 +1,12 @@
 package sign
 
 import (
+	"bufio"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -12,7 +15,14 @@ const childEnv = "GON_TEST_CHILD"
 
 // childCommands is the list of commands we support
 var childCommands = map[string]func() int{
-	"success": childSuccess,
+	"success":         childSuccess,
+	"verify-failed":   childVerifyFailed,
+	"keychain":        childKeychain,
+	"unlock-keychain": childUnlockKeychain,
+	"find-identity":   childFindIdentity,
+	"force":           childForce,
+	"force-corrupt":   childForceCorrupt,
+	"no-force":        childNoForce,
 }
 
 // childCmd is used to create a command that executes a command in the
@@ -40,3 +50,105 @@ func childSuccess() int {
 	println("success")
 	return 0
 }
+
+// childVerifyFailed mimicks a deep verification with an unsigned helper.
+func childVerifyFailed() int {
+	fmt.Fprintln(os.Stderr, strings.TrimSpace(`
+--prepared:/build/Foo.app/Contents/Frameworks/Bar.framework/Versions/Current/.
+--validated:/build/Foo.app/Contents/Frameworks/Bar.framework/Versions/Current/.
+/build/Foo.app: code object is not signed at all
+In subcomponent: /build/Foo.app/Contents/MacOS/helper
+In architecture: x86_64
+`))
+	return 1
+}
+
+// childKeychain mimicks codesign and succeeds only if a keychain is set.
+func childKeychain() int {
+	if !strings.Contains(strings.Join(os.Args, " "), "--keychain signing.keychain-db") {
+		fmt.Fprintln(os.Stderr, "expected --keychain flag")
+		return 1
+	}
+
+	return 0
+}
+
+// childUnlockKeychain mimicks security and only unlocks the keychain with
+// the password "hunter2", which is read from stdin.
+func childUnlockKeychain() int {
+	expected := []string{"unlock-keychain", "signing.keychain-db"}
+	if strings.Join(os.Args[1:], " ") != strings.Join(expected, " ") {
+		fmt.Fprintln(os.Stderr, "unexpected arguments")
+		return 1
+	}
+
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil || password != "hunter2\n" {
+		fmt.Fprintln(os.Stderr, "security: SecKeychainUnlock: The user name or passphrase you entered is not correct.")
+		return 51
+	}
+
+	return 0
+}
+
+// childFindIdentity mimicks `security find-identity` with one valid and
+// one expired identity.
+func childFindIdentity() int {
+	fmt.Println(strings.TrimSpace(`
+  1) 1A2B3C4D5E6F7A8B9C0D1E2F3A4B5C6D7E8F9A0B "Developer ID Application: Example, Inc. (ABCDE12345)"
+  2) 0F9E8D7C6B5A4F3E2D1C0B9A8F7E6D5C4B3A2F1E "Apple Development: jane@example.com (FGHIJ67890)" (CSSMERR_TP_CERT_EXPIRED)
+     2 identities found
+`))
+	return 0
+}
+
+// hasArg returns true if the arguments of the child include arg.
+func hasArg(arg string) bool {
+	for _, a := range os.Args[1:] {
+		if a == arg {
+			return true
+		}
+	}
+
+	return false
+}
+
+// childForce mimicks codesign replacing a signature that verifies.
+func childForce() int {
+	file := os.Args[len(os.Args)-1]
+	if hasArg("--verify") {
+		fmt.Fprintf(os.Stderr, "%s: valid on disk\n%s: satisfies its Designated Requirement\n", file, file)
+		return 0
+	}
+
+	if !hasArg("-f") {
+		fmt.Fprintf(os.Stderr, "%s: is already signed\n", file)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: replacing existing signature\n", file)
+	return 0
+}
+
+// childForceCorrupt mimicks codesign replacing a signature that then
+// fails verification.
+func childForceCorrupt() int {
+	file := os.Args[len(os.Args)-1]
+	if hasArg("--verify") {
+		fmt.Fprintf(os.Stderr, "%s: a sealed resource is missing or invalid\n", file)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: replacing existing signature\n", file)
+	return 0
+}
+
+// childNoForce mimicks codesign and fails if the signature is forced.
+func childNoForce() int {
+	if hasArg("-f") {
+		fmt.Fprintln(os.Stderr, "unexpected -f flag")
+		return 1
+	}
+
+	return 0
+}
@@ -3,6 +3,7 @@ package sign
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/go-hclog"
@@ -31,3 +32,88 @@ func TestSign_success(t *testing.T) {
 		BaseCmd:  childCmd(t, "success"),
 	}))
 }
+
+func TestSign_force(t *testing.T) {
+	var replaced []string
+	require.NoError(t, Sign(context.Background(), &Options{
+		Files:    []string{"foo"},
+		Identity: "bar",
+		Replaced: func(f string) { replaced = append(replaced, f) },
+		Logger:   hclog.L(),
+		BaseCmd:  childCmd(t, "force"),
+	}))
+	require.Equal(t, []string{"foo"}, replaced)
+}
+
+func TestSign_forceVerifyFailed(t *testing.T) {
+	err := Sign(context.Background(), &Options{
+		Files:    []string{"foo"},
+		Identity: "bar",
+		Logger:   hclog.L(),
+		BaseCmd:  childCmd(t, "force-corrupt"),
+	})
+	require.ErrorContains(t, err, "failed verification after forced signing")
+}
+
+func TestSign_noForce(t *testing.T) {
+	require.NoError(t, Sign(context.Background(), &Options{
+		Files:    []string{"foo"},
+		Identity: "bar",
+		Logger:   hclog.L(),
+		NoForce:  true,
+		BaseCmd:  childCmd(t, "no-force"),
+	}))
+}
+
+func TestSign_timestampConflict(t *testing.T) {
+	require.Error(t, Sign(context.Background(), &Options{
+		Files:        []string{"foo"},
+		Identity:     "bar",
+		TimestampURL: "http://timestamp.example.com",
+		NoTimestamp:  true,
+		BaseCmd:      childCmd(t, "success"),
+	}))
+}
+
+func TestSign_workDir(t *testing.T) {
+	require.NoError(t, Sign(context.Background(), &Options{
+		Files:    []string{"foo"},
+		Identity: "bar",
+		WorkDir:  t.TempDir(),
+		Logger:   hclog.L(),
+		BaseCmd:  childCmd(t, "success"),
+	}))
+
+	require.Error(t, Sign(context.Background(), &Options{
+		Files:    []string{"foo"},
+		Identity: "bar",
+		WorkDir:  filepath.Join(t.TempDir(), "missing"),
+		BaseCmd:  childCmd(t, "success"),
+	}))
+}
+
+func TestTimestampFlag(t *testing.T) {
+	req := require.New(t)
+	req.Equal("--timestamp", timestampFlag(&Options{}))
+	req.Equal("--timestamp=none", timestampFlag(&Options{NoTimestamp: true}))
+	req.Equal("--timestamp=http://timestamp.example.com",
+		timestampFlag(&Options{TimestampURL: "http://timestamp.example.com"}))
+}
+
+func TestEntitlementsFor(t *testing.T) {
+	opts := &Options{
+		Entitlements: "default.entitlements",
+		EntitlementsByPath: map[string]string{
+			"dist/helper":    "helper.entitlements",
+			"dist/*":         "dist.entitlements",
+			"dist/helper-*":  "helpers.entitlements",
+			"other/specific": "specific.entitlements",
+		},
+	}
+
+	req := require.New(t)
+	req.Equal("helper.entitlements", entitlementsFor(opts, "dist/helper"))
+	req.Equal("helpers.entitlements", entitlementsFor(opts, "dist/helper-2"))
+	req.Equal("dist.entitlements", entitlementsFor(opts, "dist/main"))
+	req.Equal("default.entitlements", entitlementsFor(opts, "bin/main"))
+}
@@ -0,0 +1,160 @@
+package sign
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/workdir"
+)
+
+// VerifyResult is the verification result for a single component of a
+// signed file, such as a nested framework or helper binary in a bundle.
+type VerifyResult struct {
+	// Path is the path to the component.
+	Path string
+
+	// Valid is true if the component passed verification.
+	Valid bool
+
+	// Reason is why verification failed. This is empty if Valid is true.
+	Reason string
+
+	// Details are additional lines reported by codesign for a failure,
+	// such as the files that were added, modified, or are missing.
+	Details []string
+}
+
+// VerifyDeep verifies the signature of file and all nested code within
+// it using `codesign --verify --deep --strict`. The results list each
+// component that codesign reported on so that improperly signed nested
+// binaries can be found before submitting for notarization.
+//
+// The results are returned even if verification failed, along with an
+// error. Only Logger, Output, WorkDir, and BaseCmd are used from opts.
+func VerifyDeep(ctx context.Context, file string, opts *Options) ([]VerifyResult, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+
+	if err := workdir.Validate(opts.WorkDir); err != nil {
+		return nil, err
+	}
+
+	// Build our command
+	var cmd exec.Cmd
+	if opts.BaseCmd != nil {
+		cmd = *opts.BaseCmd
+	}
+
+	// We only set the path if it isn't set. This lets the options set the
+	// path to the codesigning binary that we use.
+	if cmd.Path == "" {
+		path, err := exec.LookPath("codesign")
+		if err != nil {
+			return nil, err
+		}
+
+		cmd = *(exec.CommandContext(ctx, path))
+	}
+
+	cmd.Args = []string{
+		"codesign",
+		"--verify",
+		"--deep",
+		"--strict",
+		"--verbose=4",
+		file,
+	}
+	workdir.Apply(&cmd, opts.WorkDir)
+
+	// We store all output in out for logging and parsing
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	// If we have an output set, we write to both
+	if opts.Output != nil {
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, opts.Output)
+	}
+
+	// We send stderr to the same place as stdout, codesign reports on
+	// stderr.
+	cmd.Stderr = cmd.Stdout
+
+	// Log what we're going to execute
+	logger.Info("executing codesign verification",
+		"file", file,
+		"command_path", cmd.Path,
+		"command_args", cmd.Args,
+	)
+
+	// Execute
+	err := cmd.Run()
+	results := parseVerifyOutput(out.String())
+	if err != nil {
+		logger.Error("error verifying signature", "err", err, "output", out.String())
+		return results, fmt.Errorf("error verifying signature:\n\n%s", out.String())
+	}
+
+	logger.Info("codesign verification complete", "output", out.String())
+	return results, nil
+}
+
+// parseVerifyOutput parses the output of a verbose codesign verification.
+func parseVerifyOutput(out string) []VerifyResult {
+	var results []VerifyResult
+	failure := -1
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+
+		case strings.HasPrefix(line, "--prepared:"):
+			// Preparation always precedes validation, nothing to report.
+
+		case strings.HasPrefix(line, "--validated:"):
+			results = append(results, VerifyResult{
+				Path:  strings.TrimPrefix(line, "--validated:"),
+				Valid: true,
+			})
+
+		case strings.HasPrefix(line, "In subcomponent: "):
+			// The failure was caused by a nested component, so attribute
+			// it to that component rather than the top-level file.
+			if failure >= 0 {
+				results[failure].Path = strings.TrimPrefix(line, "In subcomponent: ")
+			}
+
+		case strings.HasSuffix(line, ": valid on disk"):
+			results = append(results, VerifyResult{
+				Path:  strings.TrimSuffix(line, ": valid on disk"),
+				Valid: true,
+			})
+
+		case strings.HasSuffix(line, ": satisfies its Designated Requirement"):
+
+		case failure < 0 && strings.Contains(line, ": "):
+			idx := strings.Index(line, ": ")
+			results = append(results, VerifyResult{
+				Path:   line[:idx],
+				Reason: line[idx+2:],
+			})
+			failure = len(results) - 1
+
+		case failure >= 0:
+			results[failure].Details = append(results[failure].Details, line)
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,39 @@
+package sign
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyDeep_failed(t *testing.T) {
+	results, err := VerifyDeep(context.Background(), "/build/Foo.app", &Options{
+		Logger:  hclog.L(),
+		BaseCmd: childCmd(t, "verify-failed"),
+	})
+
+	req := require.New(t)
+	req.Error(err)
+	req.Equal([]VerifyResult{
+		{
+			Path:  "/build/Foo.app/Contents/Frameworks/Bar.framework/Versions/Current/.",
+			Valid: true,
+		},
+		{
+			Path:    "/build/Foo.app/Contents/MacOS/helper",
+			Reason:  "code object is not signed at all",
+			Details: []string{"In architecture: x86_64"},
+		},
+	}, results)
+}
+
+func TestParseVerifyOutput_valid(t *testing.T) {
+	results := parseVerifyOutput(`
+/build/Foo.app: valid on disk
+/build/Foo.app: satisfies its Designated Requirement
+`)
+
+	require.Equal(t, []VerifyResult{{Path: "/build/Foo.app", Valid: true}}, results)
+}
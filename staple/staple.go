@@ -6,11 +6,15 @@ package staple
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
-	"path/filepath"
 
 	"github.com/hashicorp/go-hclog"
+
+	"github.com/asahasrabuddhe/gon/internal/exitcode"
+	"github.com/asahasrabuddhe/gon/internal/workdir"
 )
 
 // Options are the options for creating the zip archive.
@@ -18,6 +22,11 @@ type Options struct {
 	// File to staple. It is stapled in-place.
 	File string
 
+	// WorkDir, if set, is the working directory that stapler runs in, which
+	// File is relative to if it is a relative path. This must be an
+	// existing directory.
+	WorkDir string
+
 	// Logger is the logger to use. If this is nil then no logging will be done.
 	Logger hclog.Logger
 
@@ -27,12 +36,24 @@ type Options struct {
 }
 
 // Staple staples the notarization ticket to a file.
+//
+// Stapling doesn't require any Apple credentials since the ticket is looked
+// up by the hash of the file, so this can run on a different machine than
+// the one that notarized the file.
 func Staple(ctx context.Context, opts *Options) error {
 	logger := opts.Logger
 	if logger == nil {
 		logger = hclog.NewNullLogger()
 	}
 
+	if err := workdir.Validate(opts.WorkDir); err != nil {
+		return err
+	}
+
+	if err := validateFile(workdir.Path(opts.WorkDir, opts.File)); err != nil {
+		return err
+	}
+
 	// Build our command
 	var cmd exec.Cmd
 	if opts.BaseCmd != nil {
@@ -47,9 +68,11 @@ func Staple(ctx context.Context, opts *Options) error {
 			return err
 		}
 
-		cmd = *(exec.CommandContext(ctx, path, filepath.Base(cmd.Path), "stapler", "staple", opts.File))
+		cmd = *(exec.CommandContext(ctx, path, "stapler", "staple", opts.File))
 	}
 
+	workdir.Apply(&cmd, opts.WorkDir)
+
 	// We store all output in out for logging and in case there is an error
 	var out bytes.Buffer
 	cmd.Stdout = &out
@@ -63,11 +86,82 @@ func Staple(ctx context.Context, opts *Options) error {
 	)
 
 	// Execute
-	if err := cmd.Run(); err != nil {
+	if err := exitcode.Run("stapler", cmd.Run(), out.String()); err != nil {
 		logger.Error("error stapling", "err", err, "output", out.String())
-		return fmt.Errorf("error stapling:\n\n%s", out.String())
+		return fmt.Errorf("error stapling: %w", err)
 	}
 
 	logger.Info("stapling complete", "file", opts.File)
 	return nil
 }
+
+// Validate checks whether a valid notarization ticket is already stapled
+// to the file. A nil error means the file is stapled.
+func Validate(ctx context.Context, opts *Options) error {
+	logger := opts.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+
+	if err := workdir.Validate(opts.WorkDir); err != nil {
+		return err
+	}
+
+	if err := validateFile(workdir.Path(opts.WorkDir, opts.File)); err != nil {
+		return err
+	}
+
+	// Build our command
+	var cmd exec.Cmd
+	if opts.BaseCmd != nil {
+		cmd = *opts.BaseCmd
+	}
+
+	// We only set the path if it isn't set. This lets the options set the
+	// path to the stapler binary that we use.
+	if cmd.Path == "" {
+		path, err := exec.LookPath("xcrun")
+		if err != nil {
+			return err
+		}
+
+		cmd = *(exec.CommandContext(ctx, path, "stapler", "validate", opts.File))
+	}
+
+	workdir.Apply(&cmd, opts.WorkDir)
+
+	// We store all output in out for logging and in case there is an error
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = cmd.Stdout
+
+	// Log what we're going to execute
+	logger.Info("executing stapler validation",
+		"file", opts.File,
+		"command_path", cmd.Path,
+		"command_args", cmd.Args,
+	)
+
+	// Execute
+	if err := cmd.Run(); err != nil {
+		logger.Info("file is not stapled", "err", err, "output", out.String())
+		return fmt.Errorf("error validating staple:\n\n%s", out.String())
+	}
+
+	logger.Info("file is stapled", "file", opts.File)
+	return nil
+}
+
+// validateFile checks that the file to staple is set and exists so that
+// we report a clear error rather than the output of stapler.
+func validateFile(file string) error {
+	if file == "" {
+		return errors.New("file to staple must be set")
+	}
+
+	if _, err := os.Stat(file); err != nil {
+		return fmt.Errorf("file to staple can't be read: %w", err)
+	}
+
+	return nil
+}